@@ -26,3 +26,92 @@ func TestString(t *testing.T) {
 		t.Errorf("program.String() wrong. got=%q", program.String())
 	}
 }
+
+func TestStringLiteralString(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"hello", `"hello"`},
+		{"a\nb\tc", `"a\nb\tc"`},
+		{`say "hi" \o/`, `"say \"hi\" \\o/"`},
+		{"\x01", `"\u{1}"`},
+		{"日本語", `"日本語"`},
+	}
+
+	for _, tt := range tests {
+		sl := &StringLiteral{Token: token.Token{Type: token.STRING, Literal: tt.value}, Value: tt.value}
+		if sl.String() != tt.expected {
+			t.Errorf("sl.String() wrong. expected=%s, got=%s", tt.expected, sl.String())
+		}
+	}
+}
+
+func TestSprint(t *testing.T) {
+	pos := func(col int) token.Position { return token.Position{Offset: col - 1, Line: 1, Column: col} }
+
+	program := &Program{
+		Statements: []Statement{
+			&LetStatement{
+				Token: token.Token{Type: token.LET, Literal: "let", Pos: pos(1), End: pos(4)},
+				Name: &Identifier{
+					Token: token.Token{Type: token.IDENT, Literal: "x", Pos: pos(5), End: pos(6)},
+					Value: "x",
+				},
+				Value: &PrefixExpression{
+					Token:    token.Token{Type: token.MINUS, Literal: "-", Pos: pos(9), End: pos(10)},
+					Operator: "-",
+					Right: &IntegerLiteral{
+						Token: token.Token{Type: token.INT, Literal: "5", Pos: pos(10), End: pos(11)},
+						Value: 5,
+					},
+				},
+			},
+		},
+	}
+
+	expected := `*ast.Program (1:1-1:11)
+  Statements:
+    0: *ast.LetStatement (1:1-1:11)
+      Doc: nil
+      Name: *ast.Identifier (1:5-1:6)
+        Value: "x"
+      Value: *ast.PrefixExpression (1:9-1:11)
+        Operator: "-"
+        Right: *ast.IntegerLiteral (1:10-1:11)
+          Value: 5
+`
+
+	if Sprint(program) != expected {
+		t.Errorf("Sprint(program) wrong.\nexpected=%q\ngot=     %q", expected, Sprint(program))
+	}
+}
+
+func TestCommentGroupText(t *testing.T) {
+	tests := []struct {
+		comments []string
+		expected string
+	}{
+		{[]string{"// hello"}, "hello\n"},
+		{[]string{"//hello", "//  indented"}, "hello\n indented\n"},
+		{[]string{"/* block */"}, "block\n"},
+		{[]string{"/*\n first\n second\n*/"}, "first\nsecond\n"},
+		{[]string{"//", "// text", "//"}, "text\n"},
+		{[]string{"/**/"}, ""},
+	}
+
+	for _, tt := range tests {
+		group := &CommentGroup{}
+		for _, text := range tt.comments {
+			group.List = append(group.List, &Comment{Text: text})
+		}
+		if group.Text() != tt.expected {
+			t.Errorf("Text() wrong for %q. expected=%q, got=%q", tt.comments, tt.expected, group.Text())
+		}
+	}
+
+	var empty *CommentGroup
+	if empty.Text() != "" {
+		t.Errorf("Text() of nil group wrong. got=%q", empty.Text())
+	}
+}
@@ -0,0 +1,59 @@
+package ast
+
+import (
+	"monkey/token"
+	"strings"
+)
+
+// "// ..." または "/* ... */" のコメント1つ
+// 構文解析には影響しないので Node ではないが，フォーマッタなどのために位置を保持する
+type Comment struct {
+	Token token.Token // COMMENT トークン
+	Text  string      // "//" や "/* */" を含めたコメントの文字列
+}
+
+func (c *Comment) Pos() token.Position { return c.Token.Pos }
+func (c *Comment) End() token.Position { return c.Token.End }
+
+// 空行を挟まずに連続したコメントのまとまり
+// let 文の直前にあるものはその文のドキュメントコメントになる
+type CommentGroup struct {
+	List []*Comment
+}
+
+func (g *CommentGroup) Pos() token.Position { return g.List[0].Pos() }
+func (g *CommentGroup) End() token.Position { return g.List[len(g.List)-1].End() }
+
+// コメントの記号を取り除いた本文を返す
+// "//" と "/*" "*/" を取り除き，各行の先頭の空白1つと前後の空行を削る
+func (g *CommentGroup) Text() string {
+	if g == nil {
+		return ""
+	}
+
+	var lines []string
+	for _, c := range g.List {
+		text := c.Text
+		if strings.HasPrefix(text, "//") {
+			text = text[2:]
+		} else {
+			text = strings.TrimSuffix(strings.TrimPrefix(text, "/*"), "*/")
+		}
+
+		for _, line := range strings.Split(text, "\n") {
+			line = strings.TrimRight(line, " \t\r")
+			lines = append(lines, strings.TrimPrefix(line, " "))
+		}
+	}
+
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
@@ -0,0 +1,11 @@
+package ast
+
+// Modify が各ノードに適用する関数
+// 受け取ったノードをそのまま返すか，置き換えるノードを返す
+type ModifierFunc func(Node) Node
+
+// 構文木を帰りがけ順にたどり，各ノードを modifier の結果で置き換える
+// マクロの展開で使う名前で，Rewrite と同じ動作をする
+func Modify(node Node, modifier ModifierFunc) Node {
+	return Rewrite(node, modifier)
+}
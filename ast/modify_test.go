@@ -0,0 +1,355 @@
+package ast
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestModify(t *testing.T) {
+	one := func() Expression { return &IntegerLiteral{Value: 1} }
+	two := func() Expression { return &IntegerLiteral{Value: 2} }
+
+	turnOneIntoTwo := func(node Node) Node {
+		integer, ok := node.(*IntegerLiteral)
+		if !ok {
+			return node
+		}
+
+		if integer.Value != 1 {
+			return integer
+		}
+
+		integer.Value = 2
+		return integer
+	}
+
+	tests := []struct {
+		input    Node
+		expected Node
+	}{
+		{
+			one(),
+			two(),
+		},
+		{
+			&Program{
+				Statements: []Statement{
+					&ExpressionStatement{Expression: one()},
+				},
+			},
+			&Program{
+				Statements: []Statement{
+					&ExpressionStatement{Expression: two()},
+				},
+			},
+		},
+		{
+			&InfixExpression{Left: one(), Operator: "+", Right: two()},
+			&InfixExpression{Left: two(), Operator: "+", Right: two()},
+		},
+		{
+			&InfixExpression{Left: two(), Operator: "+", Right: one()},
+			&InfixExpression{Left: two(), Operator: "+", Right: two()},
+		},
+		{
+			&PrefixExpression{Operator: "-", Right: one()},
+			&PrefixExpression{Operator: "-", Right: two()},
+		},
+		{
+			&AssignExpression{Target: &Identifier{Value: "x"}, Operator: "=", Value: one()},
+			&AssignExpression{Target: &Identifier{Value: "x"}, Operator: "=", Value: two()},
+		},
+		{
+			&IndexExpression{Left: one(), Index: one()},
+			&IndexExpression{Left: two(), Index: two()},
+		},
+		{
+			&SliceExpression{Left: one(), Low: one(), High: one()},
+			&SliceExpression{Left: two(), Low: two(), High: two()},
+		},
+		{
+			&SliceExpression{Left: one()},
+			&SliceExpression{Left: two()},
+		},
+		{
+			&IfExpression{
+				Condition: one(),
+				Consequence: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: one()},
+					},
+				},
+				Alternative: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: one()},
+					},
+				},
+			},
+			&IfExpression{
+				Condition: two(),
+				Consequence: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: two()},
+					},
+				},
+				Alternative: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: two()},
+					},
+				},
+			},
+		},
+		{
+			&IfExpression{
+				Condition:   one(),
+				Consequence: &BlockStatement{},
+			},
+			&IfExpression{
+				Condition:   two(),
+				Consequence: &BlockStatement{},
+			},
+		},
+		{
+			&ReturnStatement{ReturnValue: one()},
+			&ReturnStatement{ReturnValue: two()},
+		},
+		{
+			&LetStatement{Name: &Identifier{Value: "x"}, Value: one()},
+			&LetStatement{Name: &Identifier{Value: "x"}, Value: two()},
+		},
+		{
+			&WhileStatement{
+				Condition: one(),
+				Body: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: one()},
+					},
+				},
+			},
+			&WhileStatement{
+				Condition: two(),
+				Body: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: two()},
+					},
+				},
+			},
+		},
+		{
+			&ForStatement{
+				Variable: &Identifier{Value: "x"},
+				Iterable: one(),
+				Body: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: one()},
+					},
+				},
+			},
+			&ForStatement{
+				Variable: &Identifier{Value: "x"},
+				Iterable: two(),
+				Body: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: two()},
+					},
+				},
+			},
+		},
+		{
+			&FunctionLiteral{
+				Parameters: []*Identifier{},
+				Body: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: one()},
+					},
+				},
+			},
+			&FunctionLiteral{
+				Parameters: []*Identifier{},
+				Body: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: two()},
+					},
+				},
+			},
+		},
+		{
+			&MacroLiteral{
+				Parameters: []*Identifier{},
+				Body: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: one()},
+					},
+				},
+			},
+			&MacroLiteral{
+				Parameters: []*Identifier{},
+				Body: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: two()},
+					},
+				},
+			},
+		},
+		{
+			&CallExpression{Function: &Identifier{Value: "f"}, Arguments: []Expression{one(), one()}},
+			&CallExpression{Function: &Identifier{Value: "f"}, Arguments: []Expression{two(), two()}},
+		},
+		{
+			&ArrayLiteral{Elements: []Expression{one(), one()}},
+			&ArrayLiteral{Elements: []Expression{two(), two()}},
+		},
+		{
+			&HashLiteral{Pairs: []HashPair{{Key: one(), Value: one()}}},
+			&HashLiteral{Pairs: []HashPair{{Key: two(), Value: two()}}},
+		},
+	}
+
+	for _, tt := range tests {
+		modified := Modify(tt.input, turnOneIntoTwo)
+
+		if !reflect.DeepEqual(modified, tt.expected) {
+			t.Errorf("not equal. got=%#v, want=%#v", modified, tt.expected)
+		}
+	}
+}
+
+func TestModifyReplacesNode(t *testing.T) {
+	// 子ノードを別の種類のノードに置き換える
+	program := &Program{
+		Statements: []Statement{
+			&ExpressionStatement{Expression: &Identifier{Value: "x"}},
+		},
+	}
+
+	modified := Modify(program, func(node Node) Node {
+		if ident, ok := node.(*Identifier); ok && ident.Value == "x" {
+			return &IntegerLiteral{Value: 1}
+		}
+		return node
+	})
+
+	expected := &Program{
+		Statements: []Statement{
+			&ExpressionStatement{Expression: &IntegerLiteral{Value: 1}},
+		},
+	}
+	if !reflect.DeepEqual(modified, expected) {
+		t.Errorf("not equal. got=%#v, want=%#v", modified, expected)
+	}
+}
+
+func TestRewriteWrongType(t *testing.T) {
+	tests := []struct {
+		input       Node
+		replace     func(Node) Node
+		expectedMsg string
+	}{
+		{
+			&PrefixExpression{Operator: "-", Right: &Identifier{Value: "x"}},
+			func(node Node) Node {
+				if _, ok := node.(*Identifier); ok {
+					return &LetStatement{Name: &Identifier{Value: "y"}}
+				}
+				return node
+			},
+			"ast.Rewrite: *ast.PrefixExpression.Right: cannot use *ast.LetStatement as Expression",
+		},
+		{
+			&CallExpression{
+				Function:  &Identifier{Value: "f"},
+				Arguments: []Expression{&IntegerLiteral{Value: 1}, &IntegerLiteral{Value: 2}},
+			},
+			func(node Node) Node {
+				if integer, ok := node.(*IntegerLiteral); ok && integer.Value == 2 {
+					return nil
+				}
+				return node
+			},
+			"ast.Rewrite: *ast.CallExpression.Arguments[1]: cannot use <nil> as Expression",
+		},
+		{
+			&LetStatement{Name: &Identifier{Value: "x"}, Value: &IntegerLiteral{Value: 1}},
+			func(node Node) Node {
+				if _, ok := node.(*Identifier); ok {
+					return &IntegerLiteral{Value: 1}
+				}
+				return node
+			},
+			"ast.Rewrite: *ast.LetStatement.Name: cannot use *ast.IntegerLiteral as *ast.Identifier",
+		},
+		{
+			&IfExpression{Condition: &Boolean{Value: true}, Consequence: &BlockStatement{}},
+			func(node Node) Node {
+				if _, ok := node.(*BlockStatement); ok {
+					return &ExpressionStatement{}
+				}
+				return node
+			},
+			"ast.Rewrite: *ast.IfExpression.Consequence: cannot use *ast.ExpressionStatement as *ast.BlockStatement",
+		},
+		{
+			&Program{Statements: []Statement{&ExpressionStatement{Expression: &Identifier{Value: "x"}}}},
+			func(node Node) Node {
+				if _, ok := node.(*ExpressionStatement); ok {
+					return &Identifier{Value: "x"}
+				}
+				return node
+			},
+			"ast.Rewrite: *ast.Program.Statements[0]: cannot use *ast.Identifier as Statement",
+		},
+	}
+
+	for _, tt := range tests {
+		func() {
+			defer func() {
+				r := recover()
+				if r == nil {
+					t.Errorf("expected panic %q", tt.expectedMsg)
+					return
+				}
+				if r != tt.expectedMsg {
+					t.Errorf("wrong panic. want=%q, got=%q", tt.expectedMsg, r)
+				}
+			}()
+			Rewrite(tt.input, tt.replace)
+		}()
+	}
+}
+
+func TestCopy(t *testing.T) {
+	original := &Program{
+		Statements: []Statement{
+			&ExpressionStatement{
+				Expression: &IfExpression{
+					Condition: &IntegerLiteral{Value: 1},
+					Consequence: &BlockStatement{
+						Statements: []Statement{
+							&ExpressionStatement{Expression: &ArrayLiteral{Elements: []Expression{&IntegerLiteral{Value: 1}}}},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	copied := Copy(original)
+	if !reflect.DeepEqual(copied, original) {
+		t.Fatalf("copy not equal. got=%#v, want=%#v", copied, original)
+	}
+
+	// 複製を書き換えても元の構文木は変わらない
+	Modify(copied, func(node Node) Node {
+		if integer, ok := node.(*IntegerLiteral); ok {
+			integer.Value = 2
+		}
+		return node
+	})
+
+	if reflect.DeepEqual(copied, original) {
+		t.Errorf("original was modified through the copy. got=%#v", original)
+	}
+	if Copy(nil) != nil {
+		t.Errorf("Copy(nil) should be nil")
+	}
+}
@@ -0,0 +1,134 @@
+package ast
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"monkey/token"
+	"reflect"
+	"strings"
+)
+
+var (
+	nodeType     = reflect.TypeOf((*Node)(nil)).Elem()
+	tokenType    = reflect.TypeOf(token.Token{})
+	positionType = reflect.TypeOf(token.Position{})
+)
+
+// ノードの木構造を字下げして w に書き出す (デバッグ用)
+// 各ノードには型名と位置 "line:col-line:col" を付け，子ノードはフィールド名付きで1段深く字下げする
+// リフレクションでフィールドをたどるので，ノードの種類を増やしてもこの関数を変更する必要はない
+func Fprint(w io.Writer, node Node) error {
+	p := &printer{w: w}
+	p.printValue(reflect.ValueOf(node), 0)
+	p.newline()
+	return p.err
+}
+
+// Fprint の結果を文字列で返す
+func Sprint(node Node) string {
+	var out bytes.Buffer
+	Fprint(&out, node)
+	return out.String()
+}
+
+type printer struct {
+	w   io.Writer
+	err error
+}
+
+func (p *printer) printf(format string, a ...interface{}) {
+	if p.err != nil {
+		return
+	}
+	_, p.err = fmt.Fprintf(p.w, format, a...)
+}
+
+func (p *printer) newline() {
+	p.printf("\n")
+}
+
+func (p *printer) indent(depth int) {
+	p.printf("%s", strings.Repeat("  ", depth))
+}
+
+func (p *printer) printValue(v reflect.Value, depth int) {
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			p.printf("nil")
+			return
+		}
+		p.printValue(v.Elem(), depth)
+	case reflect.Ptr:
+		if v.IsNil() {
+			p.printf("nil")
+			return
+		}
+		node, isNode := v.Interface().(Node)
+		// *big.Int のようなノードでない値は，内部のフィールドではなく文字列表現を書き出す
+		if s, ok := v.Interface().(fmt.Stringer); ok && !isNode {
+			p.printf("%s", s)
+			return
+		}
+		p.printf("%s", v.Type())
+		if isNode {
+			p.printf(" (%s)", span(node))
+		}
+		p.printFields(v.Elem(), depth)
+	case reflect.Struct:
+		p.printf("%s", v.Type())
+		p.printFields(v, depth)
+	case reflect.Slice:
+		if v.Len() == 0 {
+			p.printf("[]")
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			p.newline()
+			p.indent(depth + 1)
+			p.printf("%d: ", i)
+			p.printValue(v.Index(i), depth+1)
+		}
+	case reflect.String:
+		p.printf("%q", v.String())
+	default:
+		p.printf("%v", v.Interface())
+	}
+}
+
+// 構造体のフィールドを1行ずつ書き出す
+// トークンや位置のフィールドはノードの位置として既に表示しているので省く
+func (p *printer) printFields(v reflect.Value, depth int) {
+	if v.Kind() != reflect.Struct {
+		return
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() || field.Type == tokenType || field.Type == positionType {
+			continue
+		}
+
+		p.newline()
+		p.indent(depth + 1)
+		// 空でないスライスは要素を次の行から並べるので，フィールド名の後に空白を置かない
+		f := v.Field(i)
+		if f.Kind() == reflect.Slice && f.Len() != 0 {
+			p.printf("%s:", field.Name)
+		} else {
+			p.printf("%s: ", field.Name)
+		}
+		p.printValue(f, depth+1)
+	}
+}
+
+// ノードの範囲を "line:col-line:col" の形式で返す
+func span(node Node) string {
+	pos, end := node.Pos(), node.End()
+	if !pos.IsValid() {
+		return "-"
+	}
+	return fmt.Sprintf("%d:%d-%d:%d", pos.Line, pos.Column, end.Line, end.Column)
+}
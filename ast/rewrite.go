@@ -0,0 +1,170 @@
+package ast
+
+import "fmt"
+
+// 構文木を帰りがけ順にたどり，各ノードを f(node) の結果で置き換える
+// 子ノードを置き換えてから親ノードに f を適用するので，f は置き換え済みの子を持つノードを受け取る
+// f はノードをそのまま返すか，置き換えるノードを返す．根のノードを置き換えた結果は戻り値で返す
+// ノードはその場で書き換えるので，元の構文木を残したい場合は Copy で複製しておくこと
+// 置き換え先の型がフィールドに合わない (式の位置に文を返した，nil を返したなど) 場合は，
+// 壊れた構文木を後で使って落ちるのを避けるため，ノードの型とフィールド名を示して panic する
+// 構文エラーで欠けた子ノード (nil) はそのままにする
+func Rewrite(node Node, f func(Node) Node) Node {
+	if node == nil {
+		return nil
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		rewriteStatements(n, "Statements", n.Statements, f)
+
+	case *ExpressionStatement:
+		n.Expression = rewriteExpr(n, "Expression", n.Expression, f)
+
+	case *LetStatement:
+		n.Name = rewriteIdent(n, "Name", n.Name, f)
+		n.Value = rewriteExpr(n, "Value", n.Value, f)
+
+	case *ReturnStatement:
+		n.ReturnValue = rewriteExpr(n, "ReturnValue", n.ReturnValue, f)
+
+	case *BlockStatement:
+		rewriteStatements(n, "Statements", n.Statements, f)
+
+	case *WhileStatement:
+		n.Condition = rewriteExpr(n, "Condition", n.Condition, f)
+		n.Body = rewriteBlock(n, "Body", n.Body, f)
+
+	case *ForStatement:
+		n.Variable = rewriteIdent(n, "Variable", n.Variable, f)
+		n.Iterable = rewriteExpr(n, "Iterable", n.Iterable, f)
+		n.Body = rewriteBlock(n, "Body", n.Body, f)
+
+	case *BreakStatement, *ContinueStatement:
+		// 子ノードを持たない
+
+	case *Identifier, *Boolean, *IntegerLiteral, *BigIntLiteral, *FloatLiteral, *StringLiteral:
+		// 子ノードを持たない
+
+	case *PrefixExpression:
+		n.Right = rewriteExpr(n, "Right", n.Right, f)
+
+	case *InfixExpression:
+		n.Left = rewriteExpr(n, "Left", n.Left, f)
+		n.Right = rewriteExpr(n, "Right", n.Right, f)
+
+	case *AssignExpression:
+		n.Target = rewriteExpr(n, "Target", n.Target, f)
+		n.Value = rewriteExpr(n, "Value", n.Value, f)
+
+	case *IfExpression:
+		n.Condition = rewriteExpr(n, "Condition", n.Condition, f)
+		n.Consequence = rewriteBlock(n, "Consequence", n.Consequence, f)
+		n.Alternative = rewriteBlock(n, "Alternative", n.Alternative, f)
+
+	case *FunctionLiteral:
+		rewriteIdents(n, "Parameters", n.Parameters, f)
+		n.Body = rewriteBlock(n, "Body", n.Body, f)
+
+	case *MacroLiteral:
+		rewriteIdents(n, "Parameters", n.Parameters, f)
+		n.Body = rewriteBlock(n, "Body", n.Body, f)
+
+	case *CallExpression:
+		n.Function = rewriteExpr(n, "Function", n.Function, f)
+		rewriteExprs(n, "Arguments", n.Arguments, f)
+
+	case *ArrayLiteral:
+		rewriteExprs(n, "Elements", n.Elements, f)
+
+	case *IndexExpression:
+		n.Left = rewriteExpr(n, "Left", n.Left, f)
+		n.Index = rewriteExpr(n, "Index", n.Index, f)
+
+	case *SliceExpression:
+		n.Left = rewriteExpr(n, "Left", n.Left, f)
+		n.Low = rewriteExpr(n, "Low", n.Low, f)
+		n.High = rewriteExpr(n, "High", n.High, f)
+
+	case *HashLiteral:
+		for i, pair := range n.Pairs {
+			n.Pairs[i] = HashPair{
+				Key:   rewriteExpr(n, fmt.Sprintf("Pairs[%d].Key", i), pair.Key, f),
+				Value: rewriteExpr(n, fmt.Sprintf("Pairs[%d].Value", i), pair.Value, f),
+			}
+		}
+
+	default:
+		panic(fmt.Sprintf("ast.Rewrite: unexpected node type %T", n))
+	}
+
+	return f(node)
+}
+
+// 置き換え先の型がフィールドに合わないときの panic
+func badReplacement(parent Node, field, want string, got Node) {
+	panic(fmt.Sprintf("ast.Rewrite: %T.%s: cannot use %T as %s", parent, field, got, want))
+}
+
+func rewriteExpr(parent Node, field string, e Expression, f func(Node) Node) Expression {
+	if e == nil {
+		return nil
+	}
+	replaced := Rewrite(e, f)
+	rewritten, ok := replaced.(Expression)
+	if !ok || rewritten == nil {
+		badReplacement(parent, field, "Expression", replaced)
+	}
+	return rewritten
+}
+
+// *BlockStatement の nil をインターフェースに入れると nil と判定できなくなるので，先に調べる
+func rewriteBlock(parent Node, field string, b *BlockStatement, f func(Node) Node) *BlockStatement {
+	if b == nil {
+		return nil
+	}
+	replaced := Rewrite(b, f)
+	rewritten, ok := replaced.(*BlockStatement)
+	if !ok || rewritten == nil {
+		badReplacement(parent, field, "*ast.BlockStatement", replaced)
+	}
+	return rewritten
+}
+
+func rewriteIdent(parent Node, field string, ident *Identifier, f func(Node) Node) *Identifier {
+	if ident == nil {
+		return nil
+	}
+	replaced := Rewrite(ident, f)
+	rewritten, ok := replaced.(*Identifier)
+	if !ok || rewritten == nil {
+		badReplacement(parent, field, "*ast.Identifier", replaced)
+	}
+	return rewritten
+}
+
+func rewriteExprs(parent Node, field string, list []Expression, f func(Node) Node) {
+	for i, e := range list {
+		list[i] = rewriteExpr(parent, fmt.Sprintf("%s[%d]", field, i), e, f)
+	}
+}
+
+func rewriteStatements(parent Node, field string, list []Statement, f func(Node) Node) {
+	for i, s := range list {
+		if s == nil {
+			continue
+		}
+		replaced := Rewrite(s, f)
+		rewritten, ok := replaced.(Statement)
+		if !ok || rewritten == nil {
+			badReplacement(parent, fmt.Sprintf("%s[%d]", field, i), "Statement", replaced)
+		}
+		list[i] = rewritten
+	}
+}
+
+func rewriteIdents(parent Node, field string, list []*Identifier, f func(Node) Node) {
+	for i, ident := range list {
+		list[i] = rewriteIdent(parent, fmt.Sprintf("%s[%d]", field, i), ident, f)
+	}
+}
@@ -0,0 +1,166 @@
+package ast
+
+import "fmt"
+
+// Walk が各ノードで呼び出す Visit メソッドを持つ
+// Visit が nil でない w を返すと，Walk はノードの子を w でたどり，最後に w.Visit(nil) を呼ぶ
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// 構文木を行きがけ順にたどる
+// まず v.Visit(node) を呼び，その結果 w が nil でなければ，子ノードをソースコードに書かれた順に w でたどる
+// 子をたどり終えたら w.Visit(nil) を呼ぶ
+// 構文エラーで欠けた子ノード (nil) は飛ばす
+func Walk(v Visitor, node Node) {
+	if node == nil {
+		return
+	}
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		walkStatements(v, n.Statements)
+
+	case *ExpressionStatement:
+		walkExpr(v, n.Expression)
+
+	case *LetStatement:
+		if n.Name != nil {
+			Walk(v, n.Name)
+		}
+		walkExpr(v, n.Value)
+
+	case *ReturnStatement:
+		walkExpr(v, n.ReturnValue)
+
+	case *BlockStatement:
+		walkStatements(v, n.Statements)
+
+	case *WhileStatement:
+		walkExpr(v, n.Condition)
+		if n.Body != nil {
+			Walk(v, n.Body)
+		}
+
+	case *ForStatement:
+		if n.Variable != nil {
+			Walk(v, n.Variable)
+		}
+		walkExpr(v, n.Iterable)
+		if n.Body != nil {
+			Walk(v, n.Body)
+		}
+
+	case *BreakStatement, *ContinueStatement:
+		// 子ノードを持たない
+
+	case *Identifier, *Boolean, *IntegerLiteral, *BigIntLiteral, *FloatLiteral, *StringLiteral:
+		// 子ノードを持たない
+
+	case *PrefixExpression:
+		walkExpr(v, n.Right)
+
+	case *InfixExpression:
+		walkExpr(v, n.Left)
+		walkExpr(v, n.Right)
+
+	case *AssignExpression:
+		walkExpr(v, n.Target)
+		walkExpr(v, n.Value)
+
+	case *IfExpression:
+		walkExpr(v, n.Condition)
+		if n.Consequence != nil {
+			Walk(v, n.Consequence)
+		}
+		if n.Alternative != nil {
+			Walk(v, n.Alternative)
+		}
+
+	case *FunctionLiteral:
+		walkIdents(v, n.Parameters)
+		if n.Body != nil {
+			Walk(v, n.Body)
+		}
+
+	case *MacroLiteral:
+		walkIdents(v, n.Parameters)
+		if n.Body != nil {
+			Walk(v, n.Body)
+		}
+
+	case *CallExpression:
+		walkExpr(v, n.Function)
+		walkExprs(v, n.Arguments)
+
+	case *ArrayLiteral:
+		walkExprs(v, n.Elements)
+
+	case *IndexExpression:
+		walkExpr(v, n.Left)
+		walkExpr(v, n.Index)
+
+	case *SliceExpression:
+		walkExpr(v, n.Left)
+		walkExpr(v, n.Low)
+		walkExpr(v, n.High)
+
+	case *HashLiteral:
+		for _, pair := range n.Pairs {
+			walkExpr(v, pair.Key)
+			walkExpr(v, pair.Value)
+		}
+
+	default:
+		panic(fmt.Sprintf("ast.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+func walkExpr(v Visitor, e Expression) {
+	if e != nil {
+		Walk(v, e)
+	}
+}
+
+func walkExprs(v Visitor, list []Expression) {
+	for _, e := range list {
+		walkExpr(v, e)
+	}
+}
+
+func walkStatements(v Visitor, list []Statement) {
+	for _, s := range list {
+		if s != nil {
+			Walk(v, s)
+		}
+	}
+}
+
+func walkIdents(v Visitor, list []*Identifier) {
+	for _, ident := range list {
+		if ident != nil {
+			Walk(v, ident)
+		}
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// 構文木を行きがけ順にたどり，各ノードで f(node) を呼ぶ
+// f が false を返すと，そのノードの子はたどらない
+// 子をたどり終えたノードごとに f(nil) を呼ぶ
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
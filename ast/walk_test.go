@@ -0,0 +1,344 @@
+package ast
+
+import (
+	goast "go/ast"
+	"go/parser"
+	"go/token"
+	"math/big"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// すべての種類のノードを1つずつ作る
+// 子ノードを持つフィールドはすべて埋めておき，Walk や Rewrite がたどり忘れたフィールドを検出できるようにする
+// ノードの種類を増やしたら，ここにも追加すること (TestAllNodeTypesCovered が検出する)
+func allNodes() []Node {
+	ident := func(name string) *Identifier { return &Identifier{Value: name} }
+	integer := func(v int64) *IntegerLiteral { return &IntegerLiteral{Value: v} }
+	block := func(e Expression) *BlockStatement {
+		return &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: e}}}
+	}
+
+	return []Node{
+		&Program{Statements: []Statement{
+			&ExpressionStatement{Expression: integer(1)},
+			&ExpressionStatement{Expression: integer(2)},
+		}},
+		&LetStatement{Name: ident("x"), Value: integer(1)},
+		&ReturnStatement{ReturnValue: integer(1)},
+		&WhileStatement{Condition: &Boolean{Value: true}, Body: block(integer(1))},
+		&ForStatement{Variable: ident("x"), Iterable: ident("xs"), Body: block(ident("x"))},
+		&BreakStatement{},
+		&ContinueStatement{},
+		&ExpressionStatement{Expression: integer(1)},
+		ident("x"),
+		&Boolean{Value: true},
+		integer(1),
+		&BigIntLiteral{Value: new(big.Int).Lsh(big.NewInt(1), 70)},
+		&FloatLiteral{Value: 1.5},
+		&StringLiteral{Value: "s"},
+		&PrefixExpression{Operator: "-", Right: integer(1)},
+		&InfixExpression{Left: integer(1), Operator: "+", Right: integer(2)},
+		&AssignExpression{Target: ident("x"), Operator: "+=", Value: integer(1)},
+		&IfExpression{Condition: ident("c"), Consequence: block(integer(1)), Alternative: block(integer(2))},
+		block(integer(1)),
+		&FunctionLiteral{Parameters: []*Identifier{ident("a"), ident("b")}, Body: block(ident("a"))},
+		&MacroLiteral{Parameters: []*Identifier{ident("a"), ident("b")}, Body: block(ident("a"))},
+		&CallExpression{Function: ident("f"), Arguments: []Expression{integer(1), integer(2)}},
+		&ArrayLiteral{Elements: []Expression{integer(1), integer(2)}},
+		&IndexExpression{Left: ident("xs"), Index: integer(0)},
+		&SliceExpression{Left: ident("xs"), Low: integer(0), High: integer(1)},
+		&HashLiteral{Pairs: []HashPair{
+			{Key: &StringLiteral{Value: "a"}, Value: integer(1)},
+			{Key: &StringLiteral{Value: "b"}, Value: integer(2)},
+		}},
+	}
+}
+
+// パッケージのソースコードから TokenLiteral メソッドを持つ型 (すべてのノードの種類) を集め，
+// allNodes がそのすべてを含んでいることを確かめる
+func TestAllNodeTypesCovered(t *testing.T) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, ".", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	declared := map[string]bool{}
+	for _, pkg := range pkgs {
+		for name, file := range pkg.Files {
+			if isTestFile(name) {
+				continue
+			}
+			for _, decl := range file.Decls {
+				if typeName, ok := nodeMethodReceiver(decl); ok {
+					declared[typeName] = true
+				}
+			}
+		}
+	}
+	if len(declared) == 0 {
+		t.Fatal("no node types found in package source")
+	}
+
+	covered := map[string]bool{}
+	for _, node := range allNodes() {
+		covered[reflect.TypeOf(node).Elem().Name()] = true
+	}
+
+	var missing []string
+	for name := range declared {
+		if !covered[name] {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	if len(missing) != 0 {
+		t.Errorf("allNodes is missing node types: %v", missing)
+	}
+}
+
+func TestWalkVisitsEveryChild(t *testing.T) {
+	for _, node := range allNodes() {
+		expected := childNodes(node)
+
+		var visited []Node
+		depth := 0
+		Inspect(node, func(n Node) bool {
+			if n == nil {
+				depth--
+				return false
+			}
+			if depth == 1 {
+				visited = append(visited, n)
+			}
+			depth++
+			return true
+		})
+
+		if depth != 0 {
+			t.Errorf("%T: Visit(nil) was not called once per visited node. depth=%d", node, depth)
+		}
+		if !sameNodes(visited, expected) {
+			t.Errorf("%T: wrong children visited.\nwant=%v\ngot =%v", node, expected, visited)
+		}
+	}
+}
+
+func TestWalkOrder(t *testing.T) {
+	program := &Program{Statements: []Statement{
+		&LetStatement{
+			Name: &Identifier{Value: "x"},
+			Value: &InfixExpression{
+				Left:     &IntegerLiteral{Value: 1},
+				Operator: "+",
+				Right:    &CallExpression{Function: &Identifier{Value: "f"}, Arguments: []Expression{&IntegerLiteral{Value: 2}}},
+			},
+		},
+	}}
+
+	var got []string
+	Inspect(program, func(n Node) bool {
+		if n != nil {
+			got = append(got, reflect.TypeOf(n).Elem().Name())
+		}
+		return true
+	})
+
+	expected := []string{
+		"Program", "LetStatement", "Identifier", "InfixExpression",
+		"IntegerLiteral", "CallExpression", "Identifier", "IntegerLiteral",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong order.\nwant=%v\ngot =%v", expected, got)
+	}
+}
+
+func TestInspectSkipsChildren(t *testing.T) {
+	fn := &FunctionLiteral{
+		Parameters: []*Identifier{{Value: "a"}},
+		Body: &BlockStatement{Statements: []Statement{
+			&ExpressionStatement{Expression: &Identifier{Value: "a"}},
+		}},
+	}
+	program := &Program{Statements: []Statement{
+		&ExpressionStatement{Expression: &Identifier{Value: "x"}},
+		&ExpressionStatement{Expression: fn},
+	}}
+
+	// 関数の中に入らずに識別子を集める
+	var idents []string
+	Inspect(program, func(n Node) bool {
+		if ident, ok := n.(*Identifier); ok {
+			idents = append(idents, ident.Value)
+		}
+		_, isFunction := n.(*FunctionLiteral)
+		return !isFunction
+	})
+
+	if !reflect.DeepEqual(idents, []string{"x"}) {
+		t.Errorf("wrong identifiers. want=[x], got=%v", idents)
+	}
+}
+
+func TestWalkSkipsMissingChildren(t *testing.T) {
+	// 構文エラーで欠けた子ノードや省略できる子ノードは飛ばす
+	nodes := []Node{
+		&LetStatement{Name: &Identifier{Value: "x"}},
+		&ReturnStatement{},
+		&IfExpression{Condition: &Boolean{Value: true}, Consequence: &BlockStatement{}},
+		&SliceExpression{Left: &Identifier{Value: "xs"}},
+		&ExpressionStatement{},
+	}
+
+	for _, node := range nodes {
+		count := 0
+		Inspect(node, func(n Node) bool {
+			if n != nil {
+				count++
+			}
+			return true
+		})
+
+		expected := 1 + len(childNodes(node))
+		if count != expected {
+			t.Errorf("%T: wrong number of visited nodes. want=%d, got=%d", node, expected, count)
+		}
+	}
+}
+
+func TestRewriteReplacesEveryChild(t *testing.T) {
+	for _, node := range allNodes() {
+		// すべてのノードを浅い複製に置き換え，結果の子がすべて置き換え後のノードになっていることを確かめる
+		replaced := map[Node]bool{}
+		rewritten := Rewrite(node, func(n Node) Node {
+			c := shallowCopy(n)
+			replaced[c] = true
+			return c
+		})
+
+		if !replaced[rewritten] {
+			t.Errorf("%T: root was not replaced", node)
+		}
+		if reflect.TypeOf(rewritten) != reflect.TypeOf(node) {
+			t.Errorf("%T: root changed type to %T", node, rewritten)
+		}
+
+		children := childNodes(rewritten)
+		if len(children) != len(childNodes(node)) {
+			t.Errorf("%T: wrong number of children after rewrite. want=%d, got=%d",
+				node, len(childNodes(node)), len(children))
+		}
+		for _, child := range children {
+			if !replaced[child] {
+				t.Errorf("%T: child %T was not replaced", node, child)
+			}
+		}
+	}
+}
+
+func TestRewriteIsPostOrder(t *testing.T) {
+	program := &Program{Statements: []Statement{
+		&ExpressionStatement{Expression: &InfixExpression{
+			Left:     &IntegerLiteral{Value: 1},
+			Operator: "+",
+			Right:    &IntegerLiteral{Value: 2},
+		}},
+	}}
+
+	// 子を先に置き換えるので，親は置き換え済みの子を見て畳み込める
+	folded := Rewrite(program, func(n Node) Node {
+		infix, ok := n.(*InfixExpression)
+		if !ok {
+			return n
+		}
+		left, lok := infix.Left.(*IntegerLiteral)
+		right, rok := infix.Right.(*IntegerLiteral)
+		if !lok || !rok || infix.Operator != "+" {
+			return n
+		}
+		return &IntegerLiteral{Value: left.Value + right.Value}
+	})
+
+	expected := &Program{Statements: []Statement{
+		&ExpressionStatement{Expression: &IntegerLiteral{Value: 3}},
+	}}
+	if !reflect.DeepEqual(folded, expected) {
+		t.Errorf("not equal. got=%#v, want=%#v", folded, expected)
+	}
+}
+
+func isTestFile(name string) bool {
+	return len(name) > len("_test.go") && name[len(name)-len("_test.go"):] == "_test.go"
+}
+
+// TokenLiteral メソッドの宣言なら，その受け手の型名を返す
+func nodeMethodReceiver(decl interface{}) (string, bool) {
+	fn, ok := decl.(*goast.FuncDecl)
+	if !ok || fn.Recv == nil || fn.Name.Name != "TokenLiteral" || len(fn.Recv.List) != 1 {
+		return "", false
+	}
+
+	recv := fn.Recv.List[0].Type
+	if star, ok := recv.(*goast.StarExpr); ok {
+		recv = star.X
+	}
+	ident, ok := recv.(*goast.Ident)
+	if !ok {
+		return "", false
+	}
+	return ident.Name, true
+}
+
+// ノードの直接の子ノードを，フィールドの順にリフレクションで集める
+// nil のフィールドは飛ばす．HashPair のようにノードでない構造体の中も調べる
+func childNodes(node Node) []Node {
+	var children []Node
+	collectNodes(reflect.ValueOf(node).Elem(), &children)
+	return children
+}
+
+func collectNodes(v reflect.Value, children *[]Node) {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			return
+		}
+		if v.Type().Implements(nodeType) {
+			*children = append(*children, v.Interface().(Node))
+		}
+
+	case reflect.Struct:
+		if v.Type() == tokenType || v.Type() == positionType {
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			collectNodes(v.Field(i), children)
+		}
+
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			collectNodes(v.Index(i), children)
+		}
+	}
+}
+
+func sameNodes(a, b []Node) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func shallowCopy(n Node) Node {
+	v := reflect.ValueOf(n)
+	c := reflect.New(v.Type().Elem())
+	c.Elem().Set(v.Elem())
+	return c.Interface().(Node)
+}
@@ -0,0 +1,298 @@
+package code
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// 仮想マシンが実行する命令列
+// 1つの命令は1バイトのオペコードと，それに続くビッグエンディアンのオペランドからなる
+type Instructions []byte
+
+// 命令列を "0000 OpConstant 1" のような形式で1行に1命令ずつ逆アセンブルする
+func (ins Instructions) String() string {
+	var out bytes.Buffer
+
+	i := 0
+	for i < len(ins) {
+		def, err := Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			i++
+			continue
+		}
+
+		operands, read := ReadOperands(def, ins[i+1:])
+
+		fmt.Fprintf(&out, "%04d %s\n", i, ins.fmtInstruction(def, operands))
+
+		i += 1 + read
+	}
+
+	return out.String()
+}
+
+func (ins Instructions) fmtInstruction(def *Definition, operands []int) string {
+	operandCount := len(def.OperandWidths)
+
+	if len(operands) != operandCount {
+		return fmt.Sprintf("ERROR: operand len %d does not match defined %d\n",
+			len(operands), operandCount)
+	}
+
+	switch operandCount {
+	case 0:
+		return def.Name
+	case 1:
+		return fmt.Sprintf("%s %d", def.Name, operands[0])
+	case 2:
+		return fmt.Sprintf("%s %d %d", def.Name, operands[0], operands[1])
+	}
+
+	return fmt.Sprintf("ERROR: unhandled operandCount for %s\n", def.Name)
+}
+
+type Opcode byte
+
+const (
+	// 定数プールの値をスタックに積む
+	OpConstant Opcode = iota
+	// スタックの先頭を捨てる (式文の終わり)
+	OpPop
+	// スタックの先頭を複製する (代入式の値を残したまま変数に格納するのに使う)
+	OpDup
+	// スタックの先頭の2つを複製する (複合代入で配列と添字を2回使うのに使う)
+	OpDup2
+
+	OpTrue
+	OpFalse
+	OpNull
+
+	// 中値演算子 (スタックから右辺，左辺の順に取り出して結果を積む)
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpMod
+	OpPow
+	OpBitAnd
+	OpBitOr
+	OpBitXor
+	OpShiftLeft
+	OpShiftRight
+	OpEqual
+	OpNotEqual
+	OpLessThan
+	OpGreaterThan
+	OpLessEqual
+	OpGreaterEqual
+
+	// 前置演算子
+	OpMinus
+	OpBang
+	OpBitNot
+
+	// 無条件ジャンプと，スタックの先頭が偽のときのジャンプ (先頭は取り除く)
+	OpJump
+	OpJumpNotTruthy
+
+	// グローバル変数の読み出し・let による定義・代入 (代入は未定義ならエラー)
+	OpGetGlobal
+	OpSetGlobal
+	OpAssignGlobal
+
+	// 局所変数の読み書き
+	OpGetLocal
+	OpSetLocal
+	// クロージャに捕捉された局所変数の読み書き．変数はセルに入れて関数と共有する
+	OpGetCell
+	OpSetCell
+	// 局所変数のセルを積む (クロージャを作る前に捕捉する変数を並べるのに使う)
+	OpLoadCell
+	// 局所変数の範囲を未定義に戻す (for文の繰り返しのたびに新しい変数を作るのに使う)
+	OpClearLocals
+
+	// 自由変数 (クロージャが捕捉した外側の変数) の読み書きと，そのセルを積む命令
+	OpGetFree
+	OpSetFree
+	OpLoadFree
+
+	OpGetBuiltin
+
+	OpArray
+	OpHash
+	// ハッシュのキーを評価した直後に，スタックの先頭の値がキーに使えるかを検査する (値は取り除かない)
+	OpHashKey
+	OpIndex
+	// container[index] = value (スタックに value を残す)
+	OpSetIndex
+	// スライス．オペランドは下限(1)と上限(2)のどちらを積んだかを表すビットの組
+	OpSlice
+
+	OpCall
+	OpReturnValue
+	OpReturn
+	OpClosure
+
+	// for文の反復子を作って局所変数に格納する命令と，
+	// その反復子の次の要素を積む命令 (要素がなければ2番目のオペランドの位置にジャンプする)
+	OpIter
+	OpIterNext
+
+	// ループの先頭でスタックの高さを局所変数に記録する命令と，
+	// break と continue で記録した高さまでスタックを戻す命令 (式の途中で抜けたときに積みかけの値を捨てる)
+	OpMarkStack
+	OpUnwindStack
+)
+
+// 命令の名前とオペランドのバイト数
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant: {"OpConstant", []int{2}},
+	OpPop:      {"OpPop", []int{}},
+	OpDup:      {"OpDup", []int{}},
+	OpDup2:     {"OpDup2", []int{}},
+
+	OpTrue:  {"OpTrue", []int{}},
+	OpFalse: {"OpFalse", []int{}},
+	OpNull:  {"OpNull", []int{}},
+
+	OpAdd:          {"OpAdd", []int{}},
+	OpSub:          {"OpSub", []int{}},
+	OpMul:          {"OpMul", []int{}},
+	OpDiv:          {"OpDiv", []int{}},
+	OpMod:          {"OpMod", []int{}},
+	OpPow:          {"OpPow", []int{}},
+	OpBitAnd:       {"OpBitAnd", []int{}},
+	OpBitOr:        {"OpBitOr", []int{}},
+	OpBitXor:       {"OpBitXor", []int{}},
+	OpShiftLeft:    {"OpShiftLeft", []int{}},
+	OpShiftRight:   {"OpShiftRight", []int{}},
+	OpEqual:        {"OpEqual", []int{}},
+	OpNotEqual:     {"OpNotEqual", []int{}},
+	OpLessThan:     {"OpLessThan", []int{}},
+	OpGreaterThan:  {"OpGreaterThan", []int{}},
+	OpLessEqual:    {"OpLessEqual", []int{}},
+	OpGreaterEqual: {"OpGreaterEqual", []int{}},
+
+	OpMinus:  {"OpMinus", []int{}},
+	OpBang:   {"OpBang", []int{}},
+	OpBitNot: {"OpBitNot", []int{}},
+
+	OpJump:          {"OpJump", []int{4}},
+	OpJumpNotTruthy: {"OpJumpNotTruthy", []int{4}},
+
+	OpGetGlobal:    {"OpGetGlobal", []int{2}},
+	OpSetGlobal:    {"OpSetGlobal", []int{2}},
+	OpAssignGlobal: {"OpAssignGlobal", []int{2}},
+
+	OpGetLocal:    {"OpGetLocal", []int{2}},
+	OpSetLocal:    {"OpSetLocal", []int{2}},
+	OpGetCell:     {"OpGetCell", []int{2}},
+	OpSetCell:     {"OpSetCell", []int{2}},
+	OpLoadCell:    {"OpLoadCell", []int{2}},
+	OpClearLocals: {"OpClearLocals", []int{2, 2}},
+
+	OpGetFree:  {"OpGetFree", []int{1}},
+	OpSetFree:  {"OpSetFree", []int{1}},
+	OpLoadFree: {"OpLoadFree", []int{1}},
+
+	OpGetBuiltin: {"OpGetBuiltin", []int{1}},
+
+	OpArray:    {"OpArray", []int{2}},
+	OpHash:     {"OpHash", []int{2}},
+	OpHashKey:  {"OpHashKey", []int{}},
+	OpIndex:    {"OpIndex", []int{}},
+	OpSetIndex: {"OpSetIndex", []int{}},
+	OpSlice:    {"OpSlice", []int{1}},
+
+	OpCall:        {"OpCall", []int{1}},
+	OpReturnValue: {"OpReturnValue", []int{}},
+	OpReturn:      {"OpReturn", []int{}},
+	OpClosure:     {"OpClosure", []int{2, 1}},
+
+	OpIter:     {"OpIter", []int{2}},
+	OpIterNext: {"OpIterNext", []int{2, 4}},
+
+	OpMarkStack:   {"OpMarkStack", []int{2}},
+	OpUnwindStack: {"OpUnwindStack", []int{2}},
+}
+
+// オペコードの定義を返す
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+
+	return def, nil
+}
+
+// オペコードとオペランドから1つの命令を作る
+// 定義されていないオペコードなら空の命令を返す
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	instructionLen := 1
+	for _, w := range def.OperandWidths {
+		instructionLen += w
+	}
+
+	instruction := make([]byte, instructionLen)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, o := range operands {
+		width := def.OperandWidths[i]
+		switch width {
+		case 4:
+			binary.BigEndian.PutUint32(instruction[offset:], uint32(o))
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 1:
+			instruction[offset] = byte(o)
+		}
+		offset += width
+	}
+
+	return instruction
+}
+
+// 命令のオペランドを読み取り，オペランドと読んだバイト数を返す (Make の逆)
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 4:
+			operands[i] = int(ReadUint32(ins[offset:]))
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		}
+
+		offset += width
+	}
+
+	return operands, offset
+}
+
+func ReadUint32(ins Instructions) uint32 { return binary.BigEndian.Uint32(ins) }
+func ReadUint16(ins Instructions) uint16 { return binary.BigEndian.Uint16(ins) }
+func ReadUint8(ins Instructions) uint8   { return uint8(ins[0]) }
+
+// オペランドが収まる最大の値を返す
+// コンパイラが定数や変数の数が上限を超えていないかを確かめるのに使う
+func MaxOperand(op Opcode, i int) int {
+	return 1<<(8*definitions[op].OperandWidths[i]) - 1
+}
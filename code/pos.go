@@ -0,0 +1,26 @@
+package code
+
+import (
+	"monkey/token"
+	"sort"
+)
+
+// 命令の位置と，その命令を生成したソースコード上の位置の組
+type PosEntry struct {
+	Offset int // 命令列の先頭からのバイトオフセット
+	Pos    token.Position
+}
+
+// 命令の位置からソースコード上の位置を引く表
+// 実行時エラーの位置を求めるのに使う．エントリは Offset の昇順に並べる
+type PosTable []PosEntry
+
+// offset の命令に対応するソースコード上の位置を返す
+// offset 以前で最後に記録された位置を返し，なければ不明な位置を返す
+func (t PosTable) Lookup(offset int) token.Position {
+	i := sort.Search(len(t), func(i int) bool { return t[i].Offset > offset })
+	if i == 0 {
+		return token.Position{}
+	}
+	return t[i-1].Pos
+}
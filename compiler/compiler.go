@@ -0,0 +1,922 @@
+package compiler
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/code"
+	"monkey/evaluator"
+	"monkey/object"
+	"strings"
+)
+
+// 中値演算子と前置演算子に対応する命令
+var infixOpcodes = map[string]code.Opcode{
+	"+":  code.OpAdd,
+	"-":  code.OpSub,
+	"*":  code.OpMul,
+	"/":  code.OpDiv,
+	"%":  code.OpMod,
+	"**": code.OpPow,
+	"&":  code.OpBitAnd,
+	"|":  code.OpBitOr,
+	"^":  code.OpBitXor,
+	"<<": code.OpShiftLeft,
+	">>": code.OpShiftRight,
+	"==": code.OpEqual,
+	"!=": code.OpNotEqual,
+	"<":  code.OpLessThan,
+	">":  code.OpGreaterThan,
+	"<=": code.OpLessEqual,
+	">=": code.OpGreaterEqual,
+}
+
+var prefixOpcodes = map[string]code.Opcode{
+	"-": code.OpMinus,
+	"!": code.OpBang,
+	"~": code.OpBitNot,
+}
+
+// 直前に出力した命令
+type EmittedInstruction struct {
+	Opcode   code.Opcode
+	Position int
+}
+
+// 関数リテラル1つ分(最も外側はプログラム本体)の出力先
+type CompilationScope struct {
+	instructions        code.Instructions
+	positions           code.PosTable
+	lastInstruction     EmittedInstruction
+	previousInstruction EmittedInstruction
+
+	// OpGetLocal と OpSetLocal を出力した位置
+	// 関数をコンパイルし終えた時点でクロージャに捕捉されていた変数の命令は，セルを使う命令に書き換える
+	localSites []int
+
+	// コンパイル中のループ (内側のループが末尾)
+	loops []*loop
+}
+
+// break と continue の飛び先を決めるためのループの情報
+type loop struct {
+	continueTarget int   // continue で飛ぶ位置
+	breaks         []int // break の OpJump の位置 (ループの出口が決まってから書き換える)
+	mark           int   // ループの先頭のスタックの高さを記録した局所変数の番号 (記録しないなら -1)
+}
+
+// 抽象構文木をたどってバイトコードを出力するコンパイラ
+type Compiler struct {
+	constants []object.Object
+
+	symbolTable *SymbolTable
+
+	scopes     []CompilationScope
+	scopeIndex int
+}
+
+// コンパイル結果
+type Bytecode struct {
+	Instructions code.Instructions
+	Constants    []object.Object
+	Positions    code.PosTable // 実行時エラーの位置を求めるための表
+
+	// for文の本体などのブロックで定義した変数のために，プログラム本体で使う局所変数の数と名前
+	NumLocals  int
+	LocalNames []string
+
+	// グローバル変数の名前 (添字はグローバル変数の番号)．エラーメッセージに使う
+	GlobalNames []string
+}
+
+// 組み込み関数を定義したグローバルの表を作る
+// 組み込み関数の番号は evaluator.BuiltinNames の順番で，仮想マシンも同じ順番で組み込み関数を並べる
+func NewGlobalSymbolTable() *SymbolTable {
+	symbolTable := NewSymbolTable()
+	for i, name := range evaluator.BuiltinNames() {
+		symbolTable.DefineBuiltin(i, name)
+	}
+	return symbolTable
+}
+
+// Compiler のコンストラクタ
+func New() *Compiler {
+	mainScope := CompilationScope{}
+
+	return &Compiler{
+		constants:   []object.Object{},
+		symbolTable: NewGlobalSymbolTable(),
+		scopes:      []CompilationScope{mainScope},
+		scopeIndex:  0,
+	}
+}
+
+// REPL のように，前回までのグローバル変数と定数を引き継いでコンパイルするためのコンストラクタ
+func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
+	compiler := New()
+	compiler.symbolTable = s
+	compiler.constants = constants
+
+	// ブロックの変数は前回のプログラム本体で使い終わっているので，番号を振り直す
+	s.numLocals = 0
+	s.localNames = nil
+	s.captured = make(map[int]bool)
+
+	return compiler
+}
+
+func (c *Compiler) Compile(node ast.Node) error {
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+
+	case *ast.ExpressionStatement:
+		if err := c.Compile(node.Expression); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+
+	case *ast.LetStatement:
+		return c.compileLetStatement(node)
+
+	case *ast.ReturnStatement:
+		if err := c.Compile(node.ReturnValue); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+
+	case *ast.WhileStatement:
+		return c.compileWhileStatement(node)
+
+	case *ast.ForStatement:
+		return c.compileForStatement(node)
+
+	case *ast.BreakStatement:
+		l := c.currentLoop()
+		c.unwindStack(l)
+		l.breaks = append(l.breaks, c.emit(code.OpJump, 9999))
+
+	case *ast.ContinueStatement:
+		l := c.currentLoop()
+		c.unwindStack(l)
+		c.emit(code.OpJump, l.continueTarget)
+
+	case *ast.IntegerLiteral:
+		integer := &object.Integer{Value: node.Value}
+		return c.emitConstant(node, integer)
+
+	case *ast.BigIntLiteral:
+		return c.emitConstant(node, &object.BigInt{Value: node.Value})
+
+	case *ast.FloatLiteral:
+		float := &object.Float{Value: node.Value}
+		return c.emitConstant(node, float)
+
+	case *ast.StringLiteral:
+		str := &object.String{Value: node.Value}
+		return c.emitConstant(node, str)
+
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+
+	case *ast.PrefixExpression:
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+
+		op, ok := prefixOpcodes[node.Operator]
+		if !ok {
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+		c.emitAt(node, op)
+
+	case *ast.InfixExpression:
+		if node.Operator == "&&" || node.Operator == "||" {
+			return c.compileLogicalExpression(node)
+		}
+
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+
+		op, ok := infixOpcodes[node.Operator]
+		if !ok {
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+		c.emitAt(node, op)
+
+	case *ast.AssignExpression:
+		return c.compileAssignExpression(node)
+
+	case *ast.IfExpression:
+		return c.compileIfExpression(node)
+
+	case *ast.Identifier:
+		return c.loadSymbol(node, c.resolve(node.Value))
+
+	case *ast.FunctionLiteral:
+		return c.compileFunctionLiteral(node, "")
+
+	case *ast.MacroLiteral:
+		// マクロは実行前に展開して取り除くので，ここに残るのは最上位の let 以外で定義したものだけ
+		return fmt.Errorf("%s: macro must be defined by a top-level let statement", node.Pos())
+
+	case *ast.CallExpression:
+		// quote は引数を評価しない特殊形式で，構文木を値として扱う仮想マシンでは実行できない
+		if ident, ok := node.Function.(*ast.Identifier); ok && (ident.Value == "quote" || ident.Value == "unquote") {
+			return fmt.Errorf("%s: %s is not supported by the vm engine", node.Pos(), ident.Value)
+		}
+
+		if err := c.Compile(node.Function); err != nil {
+			return err
+		}
+
+		if len(node.Arguments) > code.MaxOperand(code.OpCall, 0) {
+			return fmt.Errorf("%s: too many arguments in call", node.Pos())
+		}
+		for _, a := range node.Arguments {
+			if err := c.Compile(a); err != nil {
+				return err
+			}
+		}
+
+		c.emitAt(node, code.OpCall, len(node.Arguments))
+
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			if err := c.Compile(el); err != nil {
+				return err
+			}
+		}
+
+		c.emit(code.OpArray, len(node.Elements))
+
+	case *ast.HashLiteral:
+		// キーと値はソースコードに書かれた順に評価する
+		// 評価器と同じく，キーに使えない値はその値を評価する前にキーの位置でエラーにする
+		for _, pair := range node.Pairs {
+			if err := c.Compile(pair.Key); err != nil {
+				return err
+			}
+			c.emitAt(pair.Key, code.OpHashKey)
+			if err := c.Compile(pair.Value); err != nil {
+				return err
+			}
+		}
+
+		c.emitAt(node, code.OpHash, len(node.Pairs)*2)
+
+	case *ast.IndexExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Index); err != nil {
+			return err
+		}
+
+		c.emitAt(node, code.OpIndex)
+
+	case *ast.SliceExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+
+		// 省略されていない境界だけをスタックに積み，どちらを積んだかをオペランドで伝える
+		flags := 0
+		if node.Low != nil {
+			if err := c.Compile(node.Low); err != nil {
+				return err
+			}
+			flags |= 1
+		}
+		if node.High != nil {
+			if err := c.Compile(node.High); err != nil {
+				return err
+			}
+			flags |= 2
+		}
+
+		c.emitAt(node, code.OpSlice, flags)
+
+	default:
+		return fmt.Errorf("%s: cannot compile %T", node.Pos(), node)
+	}
+
+	return nil
+}
+
+// let文
+// 関数リテラルは自分自身を再帰呼び出しできるように，名前を先に定義してからコンパイルする
+// それ以外の値は，let x = x + 1 の右辺の x が外側の x を指すように，右辺をコンパイルしてから名前を定義する
+func (c *Compiler) compileLetStatement(node *ast.LetStatement) error {
+	if fn, ok := node.Value.(*ast.FunctionLiteral); ok {
+		symbol := c.symbolTable.Define(node.Name.Value)
+		if err := c.compileFunctionLiteral(fn, node.Name.Value); err != nil {
+			return err
+		}
+		return c.storeSymbol(node.Name, symbol)
+	}
+
+	if err := c.Compile(node.Value); err != nil {
+		return err
+	}
+	return c.storeSymbol(node.Name, c.symbolTable.Define(node.Name.Value))
+}
+
+// 代入式
+// 代入した値を式の値としてスタックに残す
+func (c *Compiler) compileAssignExpression(node *ast.AssignExpression) error {
+	var op code.Opcode
+	if node.Operator != "=" {
+		var ok bool
+		op, ok = infixOpcodes[strings.TrimSuffix(node.Operator, "=")]
+		if !ok {
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+	}
+
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		symbol := c.resolve(target.Value)
+		if symbol.Scope == BuiltinScope {
+			return fmt.Errorf("%s: cannot assign to undeclared identifier: %s", target.Pos(), target.Value)
+		}
+
+		// 複合代入では，右辺より先に代入先の今の値を読む
+		if node.Operator != "=" {
+			if err := c.loadSymbol(target, symbol); err != nil {
+				return err
+			}
+		}
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		if node.Operator != "=" {
+			c.emitAt(node, op)
+		}
+
+		c.emit(code.OpDup)
+		if err := c.assignSymbol(target, symbol); err != nil {
+			return err
+		}
+
+	case *ast.IndexExpression:
+		if err := c.Compile(target.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(target.Index); err != nil {
+			return err
+		}
+
+		if node.Operator != "=" {
+			c.emit(code.OpDup2)
+			c.emitAt(target, code.OpIndex)
+		}
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		if node.Operator != "=" {
+			c.emitAt(node, op)
+		}
+
+		c.emitAt(target, code.OpSetIndex)
+
+	default:
+		return fmt.Errorf("%s: cannot assign to %s", node.Pos(), node.Target)
+	}
+
+	return nil
+}
+
+// 論理演算子 && と ||
+// 評価器と同じく，左辺だけで結果が決まれば右辺を評価せず，結果は真偽値にする
+func (c *Compiler) compileLogicalExpression(node *ast.InfixExpression) error {
+	if err := c.Compile(node.Left); err != nil {
+		return err
+	}
+
+	var toFalse, toEnd []int
+	if node.Operator == "&&" {
+		toFalse = append(toFalse, c.emit(code.OpJumpNotTruthy, 9999))
+	} else {
+		toRight := c.emit(code.OpJumpNotTruthy, 9999)
+		c.emit(code.OpTrue)
+		toEnd = append(toEnd, c.emit(code.OpJump, 9999))
+		c.changeOperand(toRight, len(c.currentInstructions()))
+	}
+
+	if err := c.Compile(node.Right); err != nil {
+		return err
+	}
+	toFalse = append(toFalse, c.emit(code.OpJumpNotTruthy, 9999))
+	c.emit(code.OpTrue)
+	toEnd = append(toEnd, c.emit(code.OpJump, 9999))
+
+	for _, pos := range toFalse {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+	c.emit(code.OpFalse)
+
+	for _, pos := range toEnd {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+	return nil
+}
+
+func (c *Compiler) compileIfExpression(node *ast.IfExpression) error {
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+
+	// 後で書き換えるために，とりあえず 9999 でジャンプ先を埋めておく
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	if err := c.compileBlockValue(node.Consequence); err != nil {
+		return err
+	}
+
+	jumpPos := c.emit(code.OpJump, 9999)
+
+	c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+
+	if node.Alternative == nil {
+		c.emit(code.OpNull)
+	} else if err := c.compileBlockValue(node.Alternative); err != nil {
+		return err
+	}
+
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+
+	return nil
+}
+
+// 値を持つブロック (if式の本体) をコンパイルする
+// 最後の文が式文ならその値を残し，そうでなければ null を残す
+func (c *Compiler) compileBlockValue(block *ast.BlockStatement) error {
+	if err := c.Compile(block); err != nil {
+		return err
+	}
+
+	if endsWithExpression(block) {
+		c.removeLastPop()
+	} else {
+		c.emit(code.OpNull)
+	}
+	return nil
+}
+
+func endsWithExpression(block *ast.BlockStatement) bool {
+	if n := len(block.Statements); n > 0 {
+		_, ok := block.Statements[n-1].(*ast.ExpressionStatement)
+		return ok
+	}
+	return false
+}
+
+// while文
+// 条件が偽になるか break するまで本体を繰り返す．continue は条件の評価に戻る
+func (c *Compiler) compileWhileStatement(node *ast.WhileStatement) error {
+	l := c.enterLoop(node.Body)
+	head := len(c.currentInstructions())
+	l.continueTarget = head
+
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+	exit := c.emit(code.OpJumpNotTruthy, 9999)
+
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+	c.emit(code.OpJump, head)
+	c.leaveLoop()
+
+	end := len(c.currentInstructions())
+	c.changeOperand(exit, end)
+	for _, pos := range l.breaks {
+		c.changeOperand(pos, end)
+	}
+	return nil
+}
+
+// for文
+// 反復子をブロックの名前のない局所変数に置き，要素がなくなるか break するまで本体を繰り返す
+// 繰り返しのたびにブロックの変数を未定義に戻すので，本体で作ったクロージャは評価器と同じくその回の変数を捕捉する
+func (c *Compiler) compileForStatement(node *ast.ForStatement) error {
+	if err := c.Compile(node.Iterable); err != nil {
+		return err
+	}
+
+	c.symbolTable = NewBlockSymbolTable(c.symbolTable)
+	iter := c.symbolTable.DefineHidden()
+	c.emitAt(node.Iterable, code.OpIter, iter.Index)
+
+	// スタックの高さを記録する変数は，繰り返しのたびに未定義に戻さないようにブロックの変数より先に割り当てる
+	l := c.enterLoop(node.Body)
+
+	// ブロックの変数の数は本体をコンパイルするまで分からないので，後で書き換える
+	firstLocal := c.symbolTable.NumLocals()
+	head := c.emit(code.OpClearLocals, firstLocal, 0)
+	l.continueTarget = head
+	next := c.emit(code.OpIterNext, iter.Index, 9999)
+	if err := c.storeSymbol(node.Variable, c.symbolTable.Define(node.Variable.Value)); err != nil {
+		return err
+	}
+
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+	c.emit(code.OpJump, head)
+	c.leaveLoop()
+
+	end := len(c.currentInstructions())
+	c.replaceInstruction(next, code.Make(code.OpIterNext, iter.Index, end))
+	for _, pos := range l.breaks {
+		c.changeOperand(pos, end)
+	}
+
+	numLocals := c.symbolTable.NumLocals() - firstLocal
+	c.replaceInstruction(head, code.Make(code.OpClearLocals, firstLocal, numLocals))
+
+	c.symbolTable = c.symbolTable.Outer
+	return nil
+}
+
+// ループに入る
+// 本体に break か continue があれば，ループの先頭のスタックの高さを名前のない局所変数に記録する命令を出力する
+// [1, if (c) { continue; } else { 2 }] のように式の途中で抜けると，積みかけの値がスタックに残るため
+// continue の飛び先は呼び出し側で設定する
+func (c *Compiler) enterLoop(body *ast.BlockStatement) *loop {
+	l := &loop{mark: -1}
+	if hasLoopExit(body) {
+		l.mark = c.symbolTable.DefineHidden().Index
+		c.emit(code.OpMarkStack, l.mark)
+	}
+	c.scopes[c.scopeIndex].loops = append(c.scopes[c.scopeIndex].loops, l)
+	return l
+}
+
+// break と continue の前に，スタックをループの先頭の高さまで戻す命令を出力する
+func (c *Compiler) unwindStack(l *loop) {
+	if l.mark >= 0 {
+		c.emit(code.OpUnwindStack, l.mark)
+	}
+}
+
+// ループの本体に，そのループを抜ける break か continue があるかどうか
+// 内側のループと関数リテラルの中のものは，そのループや関数のものなので数えない
+func hasLoopExit(body *ast.BlockStatement) bool {
+	found := false
+	ast.Inspect(body, func(node ast.Node) bool {
+		switch node.(type) {
+		case *ast.BreakStatement, *ast.ContinueStatement:
+			found = true
+		case *ast.WhileStatement, *ast.ForStatement, *ast.FunctionLiteral, *ast.MacroLiteral:
+			return false
+		}
+		return !found
+	})
+	return found
+}
+
+func (c *Compiler) leaveLoop() {
+	loops := c.scopes[c.scopeIndex].loops
+	c.scopes[c.scopeIndex].loops = loops[:len(loops)-1]
+}
+
+// 最も内側のループ
+// ループの外の break と continue は構文解析でエラーにしているので，ここでは必ずループの中にいる
+func (c *Compiler) currentLoop() *loop {
+	loops := c.scopes[c.scopeIndex].loops
+	return loops[len(loops)-1]
+}
+
+// 関数リテラル
+// 本体を新しいスコープでコンパイルし，捕捉した変数のセルを積んでからクロージャを作る命令を出力する
+func (c *Compiler) compileFunctionLiteral(node *ast.FunctionLiteral, name string) error {
+	c.enterScope()
+
+	for _, p := range node.Parameters {
+		c.symbolTable.Define(p.Value)
+	}
+
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+
+	// 最後の式文の値を返り値にする
+	if endsWithExpression(node.Body) {
+		c.replaceLastPopWithReturn()
+	}
+	if !c.lastInstructionIs(code.OpReturnValue) {
+		c.emit(code.OpReturn)
+	}
+
+	freeSymbols := c.symbolTable.FreeSymbols
+	if len(freeSymbols) > code.MaxOperand(code.OpClosure, 1) {
+		return fmt.Errorf("%s: too many free variables in function", node.Pos())
+	}
+	freeNames := make([]string, len(freeSymbols))
+	for i, s := range freeSymbols {
+		freeNames[i] = s.Name
+	}
+	numLocals := c.symbolTable.NumLocals()
+	localNames := c.symbolTable.LocalNames()
+	instructions, positions := c.leaveScope()
+
+	compiledFn := &object.CompiledFunction{
+		Instructions:  instructions,
+		Positions:     positions,
+		NumLocals:     numLocals,
+		NumParameters: len(node.Parameters),
+		Name:          name,
+		LocalNames:    localNames,
+		FreeNames:     freeNames,
+		Literal:       node,
+	}
+
+	// 捕捉した変数のセルを外側のスコープで積む
+	for _, s := range freeSymbols {
+		switch s.Scope {
+		case LocalScope:
+			c.emit(code.OpLoadCell, s.Index)
+		case FreeScope:
+			c.emit(code.OpLoadFree, s.Index)
+		}
+	}
+
+	fnIndex, err := c.addConstant(node, compiledFn)
+	if err != nil {
+		return err
+	}
+	c.emit(code.OpClosure, fnIndex, len(freeSymbols))
+
+	return nil
+}
+
+// 名前を解決する
+// どこにも定義されていない名前はグローバル変数として定義しておく
+// 後で定義されるグローバル変数(相互再帰する関数など)を参照でき，実行時までに定義されなければ仮想マシンがエラーにする
+func (c *Compiler) resolve(name string) Symbol {
+	symbol, ok := c.symbolTable.Resolve(name)
+	if !ok {
+		global := c.symbolTable
+		for global.Outer != nil {
+			global = global.Outer
+		}
+		symbol = global.Define(name)
+	}
+	return symbol
+}
+
+// 変数の番号が，読み書きする命令のオペランドの幅に収まるかどうかを調べる
+// 収まらない番号を書き込むと切り詰められて別の変数を指してしまうので，コンパイルエラーにする
+func checkSymbolIndex(node ast.Node, s Symbol) error {
+	switch s.Scope {
+	case GlobalScope:
+		if s.Index > code.MaxOperand(code.OpGetGlobal, 0) {
+			return fmt.Errorf("%s: too many global variables", node.Pos())
+		}
+	case LocalScope:
+		if s.Index > code.MaxOperand(code.OpGetLocal, 0) {
+			return fmt.Errorf("%s: too many local variables", node.Pos())
+		}
+	case FreeScope:
+		if s.Index > code.MaxOperand(code.OpGetFree, 0) {
+			return fmt.Errorf("%s: too many free variables in function", node.Pos())
+		}
+	}
+	return nil
+}
+
+// 変数の値をスタックに積む
+func (c *Compiler) loadSymbol(node ast.Node, s Symbol) error {
+	if err := checkSymbolIndex(node, s); err != nil {
+		return err
+	}
+
+	switch s.Scope {
+	case GlobalScope:
+		c.emitAt(node, code.OpGetGlobal, s.Index)
+	case LocalScope:
+		c.emitLocal(node, code.OpGetLocal, s.Index)
+	case BuiltinScope:
+		c.emit(code.OpGetBuiltin, s.Index)
+	case FreeScope:
+		c.emitAt(node, code.OpGetFree, s.Index)
+	}
+	return nil
+}
+
+// let で定義した変数に，スタックの先頭の値を格納する
+func (c *Compiler) storeSymbol(node ast.Node, s Symbol) error {
+	if err := checkSymbolIndex(node, s); err != nil {
+		return err
+	}
+
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpSetGlobal, s.Index)
+	case LocalScope:
+		c.emitLocal(node, code.OpSetLocal, s.Index)
+	}
+	return nil
+}
+
+// 既存の変数に，スタックの先頭の値を代入する
+func (c *Compiler) assignSymbol(node ast.Node, s Symbol) error {
+	if err := checkSymbolIndex(node, s); err != nil {
+		return err
+	}
+
+	switch s.Scope {
+	case GlobalScope:
+		c.emitAt(node, code.OpAssignGlobal, s.Index)
+	case LocalScope:
+		c.emitLocal(node, code.OpSetLocal, s.Index)
+	case FreeScope:
+		c.emit(code.OpSetFree, s.Index)
+	}
+	return nil
+}
+
+// 局所変数を読み書きする命令を出力し，後でセルを使う命令に書き換えられるように位置を覚えておく
+func (c *Compiler) emitLocal(node ast.Node, op code.Opcode, index int) {
+	pos := c.emitAt(node, op, index)
+	c.scopes[c.scopeIndex].localSites = append(c.scopes[c.scopeIndex].localSites, pos)
+}
+
+// クロージャに捕捉された局所変数を読み書きする命令を，セルを使う命令に書き換える
+// どちらの命令もオペランドの幅が同じなので，オペコードだけを置き換えればよい
+func (c *Compiler) patchCapturedLocals() {
+	ins := c.currentInstructions()
+	for _, pos := range c.scopes[c.scopeIndex].localSites {
+		if !c.symbolTable.Captured(int(code.ReadUint16(ins[pos+1:]))) {
+			continue
+		}
+		switch code.Opcode(ins[pos]) {
+		case code.OpGetLocal:
+			ins[pos] = byte(code.OpGetCell)
+		case code.OpSetLocal:
+			ins[pos] = byte(code.OpSetCell)
+		}
+	}
+}
+
+func (c *Compiler) emitConstant(node ast.Node, obj object.Object) error {
+	index, err := c.addConstant(node, obj)
+	if err != nil {
+		return err
+	}
+	c.emit(code.OpConstant, index)
+	return nil
+}
+
+// 定数プールに値を追加して，その番号を返す
+func (c *Compiler) addConstant(node ast.Node, obj object.Object) (int, error) {
+	if len(c.constants) > code.MaxOperand(code.OpConstant, 0) {
+		return 0, fmt.Errorf("%s: too many constants", node.Pos())
+	}
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1, nil
+}
+
+// 命令を出力して，その位置を返す
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	ins := code.Make(op, operands...)
+	pos := c.addInstruction(ins)
+
+	c.setLastInstruction(op, pos)
+
+	return pos
+}
+
+// 実行時エラーになりうる命令を出力する
+// 命令の位置とノードの位置を対応表に記録し，エラーの位置を評価器と同じノードの位置にする
+func (c *Compiler) emitAt(node ast.Node, op code.Opcode, operands ...int) int {
+	pos := len(c.currentInstructions())
+
+	positions := c.scopes[c.scopeIndex].positions
+	if n := len(positions); n == 0 || positions[n-1].Pos != node.Pos() {
+		c.scopes[c.scopeIndex].positions = append(positions, code.PosEntry{Offset: pos, Pos: node.Pos()})
+	}
+
+	return c.emit(op, operands...)
+}
+
+func (c *Compiler) addInstruction(ins []byte) int {
+	posNewInstruction := len(c.currentInstructions())
+	updatedInstructions := append(c.currentInstructions(), ins...)
+
+	c.scopes[c.scopeIndex].instructions = updatedInstructions
+
+	return posNewInstruction
+}
+
+func (c *Compiler) setLastInstruction(op code.Opcode, pos int) {
+	previous := c.scopes[c.scopeIndex].lastInstruction
+	last := EmittedInstruction{Opcode: op, Position: pos}
+
+	c.scopes[c.scopeIndex].previousInstruction = previous
+	c.scopes[c.scopeIndex].lastInstruction = last
+}
+
+func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
+	if len(c.currentInstructions()) == 0 {
+		return false
+	}
+
+	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
+}
+
+func (c *Compiler) removeLastPop() {
+	last := c.scopes[c.scopeIndex].lastInstruction
+	previous := c.scopes[c.scopeIndex].previousInstruction
+
+	old := c.currentInstructions()
+	new := old[:last.Position]
+
+	c.scopes[c.scopeIndex].instructions = new
+	c.scopes[c.scopeIndex].lastInstruction = previous
+}
+
+func (c *Compiler) replaceLastPopWithReturn() {
+	lastPos := c.scopes[c.scopeIndex].lastInstruction.Position
+	c.replaceInstruction(lastPos, code.Make(code.OpReturnValue))
+
+	c.scopes[c.scopeIndex].lastInstruction.Opcode = code.OpReturnValue
+}
+
+func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
+	ins := c.currentInstructions()
+
+	for i := 0; i < len(newInstruction); i++ {
+		ins[pos+i] = newInstruction[i]
+	}
+}
+
+// 命令のオペランドを書き換える (ジャンプ先を後から決めるのに使う)
+func (c *Compiler) changeOperand(opPos int, operand int) {
+	op := code.Opcode(c.currentInstructions()[opPos])
+	newInstruction := code.Make(op, operand)
+
+	c.replaceInstruction(opPos, newInstruction)
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+// 関数リテラルのスコープに入る
+func (c *Compiler) enterScope() {
+	scope := CompilationScope{}
+	c.scopes = append(c.scopes, scope)
+	c.scopeIndex++
+
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+// 関数リテラルのスコープを抜けて，その命令列と位置の表を返す
+func (c *Compiler) leaveScope() (code.Instructions, code.PosTable) {
+	c.patchCapturedLocals()
+
+	scope := c.scopes[c.scopeIndex]
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+
+	c.symbolTable = c.symbolTable.Outer
+
+	return scope.instructions, scope.positions
+}
+
+// コンパイル結果を返す
+func (c *Compiler) Bytecode() *Bytecode {
+	c.patchCapturedLocals()
+
+	return &Bytecode{
+		Instructions: c.currentInstructions(),
+		Constants:    c.constants,
+		Positions:    c.scopes[c.scopeIndex].positions,
+		NumLocals:    c.symbolTable.NumLocals(),
+		LocalNames:   c.symbolTable.LocalNames(),
+		GlobalNames:  c.symbolTable.GlobalNames(),
+	}
+}
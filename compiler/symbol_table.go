@@ -0,0 +1,160 @@
+package compiler
+
+type SymbolScope string
+
+const (
+	GlobalScope  SymbolScope = "GLOBAL"
+	LocalScope   SymbolScope = "LOCAL"
+	BuiltinScope SymbolScope = "BUILTIN"
+	FreeScope    SymbolScope = "FREE"
+)
+
+// 名前を解決した結果
+// Index はスコープごとの番号 (グローバル変数の番号，局所変数の番号など)
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+}
+
+// 名前と変数の対応表
+// グローバルに1つ，関数リテラルごとに1つ作り，Outer で外側の表をたどる
+// for文の本体のようなブロックのスコープはブロックの表で表す．ブロックの変数は，それを含む関数(最も外側ならメイン)の局所変数になる
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	// 外側の関数から捕捉した変数．添字が自由変数の番号で，値は外側の表で解決した結果
+	FreeSymbols []Symbol
+
+	store map[string]Symbol
+	block bool
+
+	numDefinitions int      // グローバル変数の数 (グローバルの表だけで使う)
+	globalNames    []string // グローバル変数の名前 (グローバルの表だけで使う)
+
+	numLocals  int          // 局所変数の数 (グローバルの表では，メインで使うブロックの変数の数)
+	localNames []string     // 局所変数の名前
+	captured   map[int]bool // クロージャに捕捉された局所変数の番号
+}
+
+// グローバルの表を作る
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{
+		store:    make(map[string]Symbol),
+		captured: make(map[int]bool),
+	}
+}
+
+// 関数リテラルの表を作る
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+// ブロックの表を作る
+// ブロックで定義した名前はブロックの外からは見えないが，変数の番号は外側の関数の表から割り当てる
+func NewBlockSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewEnclosedSymbolTable(outer)
+	s.block = true
+	return s
+}
+
+// 局所変数の番号を割り当てる表 (ブロックなら，それを含む関数またはグローバルの表)
+func (s *SymbolTable) frame() *SymbolTable {
+	for s.block {
+		s = s.Outer
+	}
+	return s
+}
+
+// 名前を定義する
+// 同じ表で定義済みの変数なら同じ変数を返すので，let で定義し直しても同じ変数を書き換える
+func (s *SymbolTable) Define(name string) Symbol {
+	if symbol, ok := s.store[name]; ok && (symbol.Scope == GlobalScope || symbol.Scope == LocalScope) {
+		return symbol
+	}
+
+	var symbol Symbol
+	if s.Outer == nil {
+		symbol = Symbol{Name: name, Scope: GlobalScope, Index: s.numDefinitions}
+		s.numDefinitions++
+		s.globalNames = append(s.globalNames, name)
+	} else {
+		frame := s.frame()
+		symbol = Symbol{Name: name, Scope: LocalScope, Index: frame.numLocals}
+		frame.numLocals++
+		frame.localNames = append(frame.localNames, name)
+	}
+
+	s.store[name] = symbol
+	return symbol
+}
+
+// 名前を持たない局所変数を定義する (for文の反復子を置くのに使う)
+func (s *SymbolTable) DefineHidden() Symbol {
+	frame := s.frame()
+	symbol := Symbol{Scope: LocalScope, Index: frame.numLocals}
+	frame.numLocals++
+	frame.localNames = append(frame.localNames, "")
+	return symbol
+}
+
+// 組み込み関数の名前を定義する
+func (s *SymbolTable) DefineBuiltin(index int, name string) Symbol {
+	symbol := Symbol{Name: name, Scope: BuiltinScope, Index: index}
+	s.store[name] = symbol
+	return symbol
+}
+
+// 外側の関数の変数を自由変数として定義する
+// 捕捉された局所変数はセルに入れる必要があるので，その変数を持つ表に印を付けておく
+func (s *SymbolTable) defineFree(original Symbol) Symbol {
+	if original.Scope == LocalScope {
+		s.Outer.frame().captured[original.Index] = true
+	}
+
+	s.FreeSymbols = append(s.FreeSymbols, original)
+
+	symbol := Symbol{Name: original.Name, Scope: FreeScope, Index: len(s.FreeSymbols) - 1}
+	s.store[original.Name] = symbol
+	return symbol
+}
+
+// 名前を解決する
+// 見つからなければ外側の表を順にたどる．外側の関数の局所変数は，自由変数として捕捉する
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	symbol, ok := s.store[name]
+	if ok || s.Outer == nil {
+		return symbol, ok
+	}
+
+	symbol, ok = s.Outer.Resolve(name)
+	if !ok {
+		return symbol, ok
+	}
+
+	// ブロックは外側と同じ関数の中にあるので，外側の局所変数をそのまま使える
+	if s.block || symbol.Scope == GlobalScope || symbol.Scope == BuiltinScope {
+		return symbol, ok
+	}
+
+	return s.defineFree(symbol), true
+}
+
+// 局所変数の数
+func (s *SymbolTable) NumLocals() int { return s.frame().numLocals }
+
+// 局所変数の名前 (添字は局所変数の番号)
+func (s *SymbolTable) LocalNames() []string { return s.frame().localNames }
+
+// グローバル変数の名前 (添字はグローバル変数の番号)
+func (s *SymbolTable) GlobalNames() []string {
+	for s.Outer != nil {
+		s = s.Outer
+	}
+	return s.globalNames
+}
+
+// 局所変数がクロージャに捕捉されたかどうか
+func (s *SymbolTable) Captured(index int) bool { return s.frame().captured[index] }
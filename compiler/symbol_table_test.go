@@ -0,0 +1,162 @@
+package compiler
+
+import "testing"
+
+func TestDefine(t *testing.T) {
+	expected := map[string]Symbol{
+		"a": {Name: "a", Scope: GlobalScope, Index: 0},
+		"b": {Name: "b", Scope: GlobalScope, Index: 1},
+		"c": {Name: "c", Scope: LocalScope, Index: 0},
+		"d": {Name: "d", Scope: LocalScope, Index: 1},
+		"e": {Name: "e", Scope: LocalScope, Index: 0},
+		"f": {Name: "f", Scope: LocalScope, Index: 1},
+	}
+
+	global := NewSymbolTable()
+	if a := global.Define("a"); a != expected["a"] {
+		t.Errorf("expected a=%+v, got=%+v", expected["a"], a)
+	}
+	if b := global.Define("b"); b != expected["b"] {
+		t.Errorf("expected b=%+v, got=%+v", expected["b"], b)
+	}
+
+	firstLocal := NewEnclosedSymbolTable(global)
+	if c := firstLocal.Define("c"); c != expected["c"] {
+		t.Errorf("expected c=%+v, got=%+v", expected["c"], c)
+	}
+	if d := firstLocal.Define("d"); d != expected["d"] {
+		t.Errorf("expected d=%+v, got=%+v", expected["d"], d)
+	}
+
+	secondLocal := NewEnclosedSymbolTable(firstLocal)
+	if e := secondLocal.Define("e"); e != expected["e"] {
+		t.Errorf("expected e=%+v, got=%+v", expected["e"], e)
+	}
+	if f := secondLocal.Define("f"); f != expected["f"] {
+		t.Errorf("expected f=%+v, got=%+v", expected["f"], f)
+	}
+
+	// 同じ表で定義し直しても同じ変数になる
+	if a := global.Define("a"); a != expected["a"] {
+		t.Errorf("redefining a changed the symbol. got=%+v", a)
+	}
+	if names := global.GlobalNames(); len(names) != 2 {
+		t.Errorf("wrong global names. got=%v", names)
+	}
+}
+
+func TestResolveLocalAndBuiltin(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+	global.DefineBuiltin(0, "len")
+
+	local := NewEnclosedSymbolTable(global)
+	local.Define("c")
+
+	expected := []Symbol{
+		{Name: "a", Scope: GlobalScope, Index: 0},
+		{Name: "len", Scope: BuiltinScope, Index: 0},
+		{Name: "c", Scope: LocalScope, Index: 0},
+	}
+
+	for _, sym := range expected {
+		result, ok := local.Resolve(sym.Name)
+		if !ok {
+			t.Errorf("name %s not resolvable", sym.Name)
+			continue
+		}
+		if result != sym {
+			t.Errorf("expected %s to resolve to %+v, got=%+v", sym.Name, sym, result)
+		}
+	}
+
+	if len(local.FreeSymbols) != 0 {
+		t.Errorf("globals and builtins must not be free. got=%+v", local.FreeSymbols)
+	}
+}
+
+func TestResolveFree(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	firstLocal := NewEnclosedSymbolTable(global)
+	firstLocal.Define("b")
+
+	secondLocal := NewEnclosedSymbolTable(firstLocal)
+	secondLocal.Define("c")
+
+	thirdLocal := NewEnclosedSymbolTable(secondLocal)
+
+	tests := []struct {
+		name     string
+		expected Symbol
+	}{
+		{"a", Symbol{Name: "a", Scope: GlobalScope, Index: 0}},
+		{"b", Symbol{Name: "b", Scope: FreeScope, Index: 0}},
+		{"c", Symbol{Name: "c", Scope: FreeScope, Index: 1}},
+	}
+
+	for _, tt := range tests {
+		result, ok := thirdLocal.Resolve(tt.name)
+		if !ok {
+			t.Errorf("name %s not resolvable", tt.name)
+			continue
+		}
+		if result != tt.expected {
+			t.Errorf("expected %s to resolve to %+v, got=%+v", tt.name, tt.expected, result)
+		}
+	}
+
+	// b は2段外側の変数なので，間の関数も自由変数として捕捉する
+	expectedFree := []Symbol{
+		{Name: "b", Scope: FreeScope, Index: 0},
+		{Name: "c", Scope: LocalScope, Index: 0},
+	}
+	if len(thirdLocal.FreeSymbols) != len(expectedFree) {
+		t.Fatalf("wrong number of free symbols. got=%+v", thirdLocal.FreeSymbols)
+	}
+	for i, sym := range expectedFree {
+		if thirdLocal.FreeSymbols[i] != sym {
+			t.Errorf("wrong free symbol %d. expected=%+v, got=%+v", i, sym, thirdLocal.FreeSymbols[i])
+		}
+	}
+
+	if !firstLocal.Captured(0) || !secondLocal.Captured(0) {
+		t.Errorf("captured locals are not marked")
+	}
+
+	if _, ok := thirdLocal.Resolve("d"); ok {
+		t.Errorf("undefined name d resolved")
+	}
+}
+
+func TestBlockSymbolTable(t *testing.T) {
+	global := NewSymbolTable()
+	fn := NewEnclosedSymbolTable(global)
+	fn.Define("x")
+
+	block := NewBlockSymbolTable(fn)
+	y := block.Define("y")
+	if y != (Symbol{Name: "y", Scope: LocalScope, Index: 1}) {
+		t.Errorf("block variable must be a local of the function. got=%+v", y)
+	}
+
+	// ブロックから外側の局所変数はそのまま見える
+	x, ok := block.Resolve("x")
+	if !ok || x != (Symbol{Name: "x", Scope: LocalScope, Index: 0}) {
+		t.Errorf("x resolved wrongly. got=%+v (%t)", x, ok)
+	}
+
+	if _, ok := fn.Resolve("y"); ok {
+		t.Errorf("block variable y is visible outside the block")
+	}
+	if fn.NumLocals() != 2 || block.NumLocals() != 2 {
+		t.Errorf("wrong number of locals. got=%d", fn.NumLocals())
+	}
+
+	// プログラム本体のブロックの変数は，グローバルの表の局所変数になる
+	mainBlock := NewBlockSymbolTable(global)
+	if z := mainBlock.Define("z"); z.Scope != LocalScope || z.Index != 0 {
+		t.Errorf("block variable in main must be local. got=%+v", z)
+	}
+}
@@ -0,0 +1,328 @@
+package conformance
+
+import (
+	"bytes"
+	"monkey/ast"
+	"monkey/compiler"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/vm"
+	"reflect"
+	"testing"
+)
+
+// 2つのエンジンの名前と実行方法
+var engines = []struct {
+	name string
+	run  func(t *testing.T, input string) object.Object
+}{
+	{"eval", runEval},
+	{"vm", runVM},
+}
+
+// 値の Inspect か，エラーなら "ERROR: " に続けてメッセージを期待する
+func TestConformance(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// 数値と演算子
+		{"5 + 5 * 2 - 10 / 2", "10"},
+		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", "50"},
+		{"7 % 3; -7 % 3", "-1"},
+		{"2 ** 10", "1024"},
+		{"9223372036854775807 + 1", "9223372036854775808"},
+		{"-9223372036854775807 - 1 - 1", "-9223372036854775809"},
+		{"2 ** 100 - 2 ** 100 + 1", "1"},
+		{"[9223372036854775808, -9223372036854775808, type(-9223372036854775808)]",
+			"[9223372036854775808, -9223372036854775808, INTEGER]"},
+		{"1.5 + 1", "2.5"},
+		{"10 / 4.0", "2.5"},
+		{"0.1 + 0.2", "0.30000000000000004"},
+		{"6 & 3 | 8 ^ 1", "11"},
+		{"1 << 62 >> 60", "4"},
+		{"~0", "-1"},
+		{"5 / 0", "ERROR: division by zero: 5 / 0"},
+		{"1 << -1", "ERROR: negative shift count: -1"},
+		{"1 << 9223372036854775807", "ERROR: shift count too large: 9223372036854775807"},
+		{"4 ** 9223372036854775807", "ERROR: exponent too large: 4 ** 9223372036854775807"},
+		{"-true", "ERROR: unknown operator: -BOOLEAN"},
+		{"5 + true", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+
+		// 真偽値と比較
+		{"1 < 2 == true", "true"},
+		{"1 <= 1.0", "true"},
+		{"!5", "false"},
+		{"!!0", "true"},
+		{`"a" < "b"`, "true"},
+		{"true && 1", "true"},
+		{"false && 1 / 0", "false"},
+		{"true || 1 / 0", "true"},
+		{"null || false", "ERROR: identifier not found: null"},
+		{"if (false) { 1 } || 2", "true"},
+
+		// 条件式
+		{"if (1 > 2) { 10 }", "null"},
+		{"if (1 < 2) { 10 } else { 20 }", "10"},
+		{"if (0) { 1 } else { 2 }", "1"},
+		{"if (true) { }", "null"},
+		{"if (true) { let a = 1; }", "null"},
+
+		// 文字列
+		{`"Hello" + " " + "World!"`, "Hello World!"},
+		{`"a" == "a"`, "true"},
+		{`"Hello" - "World"`, "ERROR: unknown operator: STRING - STRING"},
+
+		// 配列とハッシュ
+		{"[1, 2 * 2, 3 + 3]", "[1, 4, 6]"},
+		{"[1, 2, 3][-1]", "3"},
+		{"[1, 2, 3][3]", "ERROR: index out of range: index 3, length 3"},
+		{"[1, 2, 3, 4][1:3]", "[2, 3]"},
+		{"[1, 2, 3, 4][:-1]", "[1, 2, 3]"},
+		{"[1, 2, 3, 4][2:]", "[3, 4]"},
+		{"[1, 2, 3, 4][:]", "[1, 2, 3, 4]"},
+		{"[1, 2][2:1]", "ERROR: slice bounds out of range: [2:1] with length 2"},
+		{`{"b": 1, "a": 2, 3: true}`, "{b: 1, a: 2, 3: true}"},
+		{`{"one": 1}["one"]`, "1"},
+		{`{"one": 1}["two"]`, "null"},
+		{`{[1]: 1}`, "ERROR: unusable as hash key: ARRAY"},
+		{`{"a": 1}[fn(x) { x }]`, "ERROR: unusable as hash key: FUNCTION"},
+		{"1[0]", "ERROR: index operator not supported: INTEGER"},
+
+		// 変数と関数
+		{"let a = 5; let b = a * 2; a + b", "15"},
+		{"let a = 1; let a = a + 1; a", "2"},
+		{"foobar", "ERROR: identifier not found: foobar"},
+		{"let f = fn(x) { x * 2 }; f(3)", "6"},
+		{"fn(x) { x * 2 }", "fn(x) {\n(x * 2)\n}"},
+		{"fn() { return 1; 2 }()", "1"},
+		{"fn() { }()", "null"},
+		{"fn() { let a = 1; }()", "null"},
+		{"let f = fn(x, y) { x + y }; f(1)", "ERROR: wrong number of arguments: want=2, got=1"},
+		{"let x = 5; x(1)", "ERROR: not a function: INTEGER"},
+		{"let f = fn() { g() }; let g = fn() { 42 }; f()", "42"},
+		{"let f = fn() { g() }; f()", "ERROR: identifier not found: g"},
+		{"return 10; 9", "10"},
+		{"if (true) { if (true) { return 10; } return 1; }", "10"},
+
+		// クロージャと再帰
+		{"let newAdder = fn(x) { fn(y) { x + y } }; let addTwo = newAdder(2); addTwo(3)", "5"},
+		{"let a = fn(x) { fn(y) { fn(z) { x + y + z } } }; a(1)(2)(3)", "6"},
+		{"let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(15)", "610"},
+		{"let counter = fn() { let c = 0; fn() { c += 1 } }; let next = counter(); next(); next(); next()", "3"},
+		{"let x = 1; let f = fn() { x }; x = 2; f()", "2"},
+		{"let f = fn() { let x = 1; let g = fn() { x }; x = 2; g() }; f()", "2"},
+		{"let x = 1; let f = fn(x) { x = 5; x }; f(0) + x", "6"},
+		{"let f = fn() { let x = 1; if (true) { let x = 2; } x }; f()", "2"},
+
+		{"let r = fn(n) { if (n == 0) { 0 } else { 1 + r(n - 1) } }; r(20000)", "20000"},
+
+		// 代入
+		{"let x = 10; x += 5; x -= 3; x *= 2; x", "24"},
+		{"let a = 1; let b = 2; a = b = 3; a + b", "6"},
+		{`let s = "foo"; s += "bar"`, "foobar"},
+		{"let arr = [1, 2, 3]; arr[-1] = 10; arr", "[1, 2, 10]"},
+		{"let arr = [1, 2, 3]; arr[1] += 5", "7"},
+		{`let h = {"a": 1}; h["a"] *= 10; h["b"] = 2; h`, "{a: 10, b: 2}"},
+		{"let m = [[1, 2], [3, 4]]; m[1][0] = 30; m", "[[1, 2], [30, 4]]"},
+		{"x = 1", "ERROR: cannot assign to undeclared identifier: x"},
+		{"x += 1", "ERROR: identifier not found: x"},
+		{"let f = fn() { y = 1 }; f()", "ERROR: cannot assign to undeclared identifier: y"},
+		{`let s = "abc"; s[0] = "x"`, "ERROR: index assignment not supported: STRING"},
+
+		// ループ
+		{"let i = 0; while (i < 10) { i += 1; } i", "10"},
+		{"let s = 0; let i = 0; while (true) { i += 1; if (i > 5) { break; } s += i; } s", "15"},
+		{"let s = 0; let i = 0; while (i < 10) { i += 1; if (i % 2 == 1) { continue; } s += i; } s", "30"},
+		{"let s = 0; for (x in [1, 2, 3]) { s += x; } s", "6"},
+		{`let k = ""; for (c in {"a": 1, "b": 2}) { k += c; } k`, "ab"},
+		{`let out = ""; for (c in "héllo") { out = c + out; } out`, "olléh"},
+		{"let s = 0; for (i in range(10, 0, -2)) { s += i; } s", "30"},
+		{"let n = 0; for (i in range(3)) { for (j in range(3)) { if (j == 1) { break; } n += 1; } } n", "3"},
+		{"let s = 0; for (i in range(10)) { if (i % 2 == 0) { continue; } s += i; } s", "25"},
+		{"let fs = []; for (i in range(3)) { fs = push(fs, fn() { i }); } [fs[0](), fs[1](), fs[2]()]", "[0, 1, 2]"},
+		{"let i = 100; for (i in range(3)) { let j = i; } i", "100"},
+		{"let f = fn(xs) { for (x in xs) { if (x > 2) { return x; } } -1 }; [f([1, 5, 3]), f([1])]", "[5, -1]"},
+		{"let f = fn() { let i = 0; while (true) { i += 1; if (i == 3) { return i; } } }; f()", "3"},
+		{"let h = {}; for (i in range(3)) { h[i] = i * i; } h", "{0: 0, 1: 1, 2: 4}"},
+		{"for (x in 5) { }", "ERROR: cannot iterate over INTEGER"},
+		{"let f = fn() { let x = if (true) { return 1; } else { 2 }; x + 10 }; f()", "1"},
+		{"let s = 0; for (i in range(5)) { s += if (i % 2 == 0) { continue; } else { i }; } s", "4"},
+		{"let n = 0; for (i in range(100000)) { n += 1; let a = [1, if (true) { continue; } else { 0 }]; } n", "100000"},
+
+		// 組み込み関数
+		{`len("four") + len([1, 2]) + len({"a": 1}) + len(range(5))`, "12"},
+		{`let n = 0; for (c in "héllo") { n += 1; } [len("héllo"), n]`, "[5, 5]"},
+		{"len(1)", "ERROR: argument to `len` not supported, got INTEGER"},
+		{`len("one", "two")`, "ERROR: wrong number of arguments to `len`: want=1, got=2"},
+		{"[first([1, 2]), last([1, 2]), rest([1, 2]), push([1], 2)]", "[1, 2, [2], [1, 2]]"},
+		{"first([])", "null"},
+		{`[type(1), type(1.0), type(""), type(fn() {}), type(len), type(range(1))]`,
+			"[INTEGER, FLOAT, STRING, FUNCTION, BUILTIN, RANGE]"},
+		{`str(1) + str([1, "a"])`, "1[1, a]"},
+		{`int("42") + int(3.9)`, "45"},
+		{"range(0, 10, 0)", "ERROR: `range` step must not be zero"},
+		{"len(range(-9223372036854775807 - 1, 9223372036854775807))", "ERROR: `range` has too many elements: range(-9223372036854775808, 9223372036854775807, 1)"},
+		{"len(range(-9223372036854775807 - 1, -1))", "9223372036854775807"},
+		{"len", "builtin function len"},
+		{"let len = fn(x) { 42 }; len([])", "42"},
+
+		// マクロ (実行前に構文木の段階で展開する)
+		{"let unless = macro(c, a, b) { quote(if (!(unquote(c))) { unquote(a) } else { unquote(b) }) }; unless(1 > 2, 10, 20)", "10"},
+		{"let twice = macro(x) { quote(unquote(x) + unquote(x)) }; let f = fn(n) { twice(n * 2) }; f(3)", "12"},
+		{`let m = macro() { quote(unquote([1, {"a": 9223372036854775807 + 1}])) }; m()`, "[1, {a: 9223372036854775808}]"},
+		{"let m = macro() { quote(unquote(if (false) { 1 })) }; m()", "null"},
+	}
+
+	for _, tt := range tests {
+		for _, engine := range engines {
+			result := engine.run(t, tt.input)
+			// 評価器は let 文などの値を持たない文で終わるブロックを nil とし，式の中では null として扱う
+			if result == nil {
+				result = object.NULL
+			}
+
+			got := result.Inspect()
+			if errObj, ok := result.(*object.Error); ok {
+				got = "ERROR: " + errObj.Message
+			}
+			if got != tt.expected {
+				t.Errorf("[%s] %q: wrong result.\nwant=%q\ngot =%q", engine.name, tt.input, tt.expected, got)
+			}
+		}
+	}
+}
+
+// エラーの種類・位置・スタックトレースも2つのエンジンで一致する
+func TestErrorDetails(t *testing.T) {
+	tests := []string{
+		"5 + true",
+		"let a = 1;\n  -true",
+		"let a = 1;\nlet b = a + c;",
+		"let x = 1;\nlen(x)",
+		"[1, 2][5]",
+		"[1, 2][\"a\":]",
+		"let f = fn(x, y) { x };\nf(1)",
+		"let f = fn() { 1 };\nf()(2)",
+		"let inner = fn(x) { x + true };\nlet outer = fn(x) { inner(x) };\nfn() { outer(1) }();",
+		"let f = fn(n) { if (n == 0) { push(1, 2) } else { f(n - 1) } };\nf(3)",
+		"let f = fn() { range(1, 2, 0) };\nlet g = fn() { f() };\ng()",
+		"let arr = [];\nfor (i in range(3)) {\n  arr[i] = i;\n}",
+		"let h = {};\nh[[1]] += 1",
+		"for (x in true) { }",
+		"let x = 1;\nwhile (true) {\n  x += \"a\";\n}",
+		"let f = fn() { let a = 1; if (false) { let b = 2; } b };\nf()",
+		"let r = fn(n) { r(n + 1) };\nr(0)",
+		"puts({[1]: 2})",
+		"let h = {\n  \"a\": 1,\n  fn() { 1 }: 2\n};",
+	}
+
+	for _, input := range tests {
+		var results []*object.Error
+		for _, engine := range engines {
+			result := engine.run(t, input)
+			errObj, ok := result.(*object.Error)
+			if !ok {
+				t.Errorf("[%s] %q: no error object returned. got=%T (%+v)", engine.name, input, result, result)
+				continue
+			}
+			results = append(results, errObj)
+		}
+		if len(results) != len(engines) {
+			continue
+		}
+
+		want, got := results[0], results[1]
+		if want.Kind != got.Kind || want.Message != got.Message {
+			t.Errorf("%q: engines disagree on error.\neval=%s: %s\nvm  =%s: %s",
+				input, want.Kind, want.Message, got.Kind, got.Message)
+		}
+		if want.Pos != got.Pos {
+			t.Errorf("%q: engines disagree on error position. eval=%s, vm=%s", input, want.Pos, got.Pos)
+		}
+		if !reflect.DeepEqual(want.Stack, got.Stack) {
+			t.Errorf("%q: engines disagree on stack trace. eval=%v, vm=%v", input, want.Stack, got.Stack)
+		}
+	}
+}
+
+// puts の出力も2つのエンジンで一致する
+func TestPutsOutput(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`
+let greet = fn(name) { puts("hello, " + name) };
+for (name in ["a", "b"]) { greet(name) }
+puts(1, [2], {3: 4});
+0`, "hello, a\nhello, b\n1\n[2]\n{3: 4}\n"},
+		// 式の途中の break と continue は値にならず，すぐにループへ伝わる
+		{`let i = 0; while (true) { i += 1; let x = if (i > 3) { break; } else { i }; puts(x); }`,
+			"1\n2\n3\n"},
+		{`for (i in range(5)) { puts([i, if (i == 2) { continue; } else { i }]); }`,
+			"[0, 0]\n[1, 1]\n[3, 3]\n[4, 4]\n"},
+		// キーに使えない値があれば，その値を評価する前にエラーになる
+		{`{1: puts("v"), [1]: puts("w")}`, "v\n"},
+	}
+
+	for _, tt := range tests {
+		for _, engine := range engines {
+			var out bytes.Buffer
+			evaluator.SetOutput(&out)
+			engine.run(t, tt.input)
+
+			if out.String() != tt.expected {
+				t.Errorf("[%s] wrong output for %q.\nwant=%q\ngot =%q", engine.name, tt.input, tt.expected, out.String())
+			}
+		}
+	}
+}
+
+func runEval(t *testing.T, input string) object.Object {
+	t.Helper()
+
+	program := parse(t, input)
+	return evaluator.Eval(program, object.NewEnvironment())
+}
+
+func runVM(t *testing.T, input string) object.Object {
+	t.Helper()
+
+	program := parse(t, input)
+
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error for %q: %s", input, err)
+	}
+
+	machine := vm.New(comp.Bytecode())
+	if err := machine.Run(); err != nil {
+		errObj, ok := err.(*object.Error)
+		if !ok {
+			t.Fatalf("vm error for %q is not *object.Error: %s", input, err)
+		}
+		return errObj
+	}
+
+	return machine.LastPoppedStackElem()
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Diagnostics()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Diagnostics())
+	}
+
+	macroEnv := object.NewEnvironment()
+	evaluator.DefineMacros(program, macroEnv)
+	if _, err := evaluator.ExpandMacros(program, macroEnv); err != nil {
+		t.Fatalf("macro expansion error for %q: %s", input, err.Message)
+	}
+	return program
+}
@@ -0,0 +1,7 @@
+// Package conformance は，評価器(evaluator)とバイトコードの仮想マシン(vm)が
+// 同じプログラムに対して同じ結果を返すことを確かめるテストをまとめたもの
+//
+// テストの表のプログラムを両方のエンジンで実行し，最後の式文の値の Inspect か，
+// 実行時エラーの種類・メッセージ・スタックトレースを比べる
+// 新しい言語機能を追加するときは，ここにも例を追加すること
+package conformance
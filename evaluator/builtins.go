@@ -0,0 +1,307 @@
+package evaluator
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"monkey/object"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// 組み込み関数の登録先
+// 識別子が環境に見つからなかったときに，ここから探す
+var builtins = map[string]*object.Builtin{}
+
+// puts の出力先
+var output io.Writer = os.Stdout
+
+func init() {
+	RegisterBuiltin("len", builtinLen)
+	RegisterBuiltin("first", builtinFirst)
+	RegisterBuiltin("last", builtinLast)
+	RegisterBuiltin("rest", builtinRest)
+	RegisterBuiltin("push", builtinPush)
+	RegisterBuiltin("puts", builtinPuts)
+	RegisterBuiltin("type", builtinType)
+	RegisterBuiltin("str", builtinStr)
+	RegisterBuiltin("int", builtinInt)
+	RegisterBuiltin("float", builtinFloat)
+	RegisterBuiltin("range", builtinRange)
+}
+
+// Goの関数を組み込み関数として登録し，Monkeyから name で呼び出せるようにする
+// 同じ名前で登録し直すと上書きする
+func RegisterBuiltin(name string, fn object.BuiltinFunction) {
+	builtins[name] = &object.Builtin{Name: name, Fn: fn}
+}
+
+// 名前に対応する組み込み関数を返す
+func LookupBuiltin(name string) (*object.Builtin, bool) {
+	builtin, ok := builtins[name]
+	return builtin, ok
+}
+
+// 登録されている組み込み関数の名前を辞書順で返す
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// puts の出力先を変更する
+func SetOutput(w io.Writer) {
+	output = w
+}
+
+// 組み込み関数のエラー
+// 位置は呼び出し側(applyFunction)で呼び出し式の位置が入る
+func newBuiltinError(kind object.ErrorKind, format string, a ...interface{}) *object.Error {
+	return &object.Error{Kind: kind, Message: fmt.Sprintf(format, a...)}
+}
+
+// 引数の数を確認する
+func checkArity(name string, args []object.Object, want int) *object.Error {
+	if len(args) != want {
+		return newBuiltinError(object.ARITY_MISMATCH,
+			"wrong number of arguments to `%s`: want=%d, got=%d", name, want, len(args))
+	}
+	return nil
+}
+
+// 引数が配列であることを確認する
+func arrayArg(name string, arg object.Object) (*object.Array, *object.Error) {
+	array, ok := arg.(*object.Array)
+	if !ok {
+		return nil, newBuiltinError(object.TYPE_MISMATCH,
+			"argument to `%s` must be ARRAY, got %s", name, arg.Type())
+	}
+	return array, nil
+}
+
+// len(x): 文字列・配列・ハッシュの長さ
+// 文字列の長さは，for 文で1文字ずつ取り出すのと同じくバイト数ではなく文字(ルーン)の数にする
+func builtinLen(args ...object.Object) object.Object {
+	if err := checkArity("len", args, 1); err != nil {
+		return err
+	}
+
+	switch arg := args[0].(type) {
+	case *object.String:
+		return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
+	case *object.Array:
+		return &object.Integer{Value: int64(len(arg.Elements))}
+	case *object.Hash:
+		return &object.Integer{Value: int64(len(arg.Pairs))}
+	case *object.Range:
+		return &object.Integer{Value: arg.Len()}
+	default:
+		return newBuiltinError(object.TYPE_MISMATCH,
+			"argument to `len` not supported, got %s", args[0].Type())
+	}
+}
+
+// first(arr): 先頭の要素 (空なら null)
+func builtinFirst(args ...object.Object) object.Object {
+	if err := checkArity("first", args, 1); err != nil {
+		return err
+	}
+	array, err := arrayArg("first", args[0])
+	if err != nil {
+		return err
+	}
+
+	if len(array.Elements) > 0 {
+		return array.Elements[0]
+	}
+	return object.NULL
+}
+
+// last(arr): 最後の要素 (空なら null)
+func builtinLast(args ...object.Object) object.Object {
+	if err := checkArity("last", args, 1); err != nil {
+		return err
+	}
+	array, err := arrayArg("last", args[0])
+	if err != nil {
+		return err
+	}
+
+	if length := len(array.Elements); length > 0 {
+		return array.Elements[length-1]
+	}
+	return object.NULL
+}
+
+// rest(arr): 先頭以外の要素を持つ新しい配列 (空なら null)
+func builtinRest(args ...object.Object) object.Object {
+	if err := checkArity("rest", args, 1); err != nil {
+		return err
+	}
+	array, err := arrayArg("rest", args[0])
+	if err != nil {
+		return err
+	}
+
+	length := len(array.Elements)
+	if length > 0 {
+		newElements := make([]object.Object, length-1)
+		copy(newElements, array.Elements[1:length])
+		return &object.Array{Elements: newElements}
+	}
+	return object.NULL
+}
+
+// push(arr, x): 末尾に x を追加した新しい配列 (元の配列は変更しない)
+func builtinPush(args ...object.Object) object.Object {
+	if err := checkArity("push", args, 2); err != nil {
+		return err
+	}
+	array, err := arrayArg("push", args[0])
+	if err != nil {
+		return err
+	}
+
+	length := len(array.Elements)
+	newElements := make([]object.Object, length+1)
+	copy(newElements, array.Elements)
+	newElements[length] = args[1]
+
+	return &object.Array{Elements: newElements}
+}
+
+// puts(x, ...): 引数を1行ずつ出力する
+func builtinPuts(args ...object.Object) object.Object {
+	for _, arg := range args {
+		fmt.Fprintln(output, arg.Inspect())
+	}
+
+	return object.NULL
+}
+
+// type(x): 型名を文字列で返す (例: "INTEGER")
+func builtinType(args ...object.Object) object.Object {
+	if err := checkArity("type", args, 1); err != nil {
+		return err
+	}
+
+	return &object.String{Value: string(args[0].Type())}
+}
+
+// str(x): 値を文字列に変換する
+func builtinStr(args ...object.Object) object.Object {
+	if err := checkArity("str", args, 1); err != nil {
+		return err
+	}
+
+	if s, ok := args[0].(*object.String); ok {
+		return s
+	}
+	return &object.String{Value: args[0].Inspect()}
+}
+
+// int(x): 文字列・真偽値・浮動小数点数を整数に変換する
+// 浮動小数点数は0の方向に切り捨てる．int64 に収まらない値は BigInt になる
+func builtinInt(args ...object.Object) object.Object {
+	if err := checkArity("int", args, 1); err != nil {
+		return err
+	}
+
+	switch arg := args[0].(type) {
+	case *object.Integer, *object.BigInt:
+		return arg
+	case *object.Float:
+		if math.IsNaN(arg.Value) || math.IsInf(arg.Value, 0) {
+			return newBuiltinError(object.TYPE_MISMATCH,
+				"cannot convert %s to INTEGER", arg.Inspect())
+		}
+		value, _ := big.NewFloat(arg.Value).Int(nil)
+		return normalizeBigInt(value)
+	case *object.Boolean:
+		if arg.Value {
+			return &object.Integer{Value: 1}
+		}
+		return &object.Integer{Value: 0}
+	case *object.String:
+		value, ok := new(big.Int).SetString(strings.TrimSpace(arg.Value), 10)
+		if !ok {
+			return newBuiltinError(object.TYPE_MISMATCH,
+				"cannot convert %q to INTEGER", arg.Value)
+		}
+		return normalizeBigInt(value)
+	default:
+		return newBuiltinError(object.TYPE_MISMATCH,
+			"argument to `int` not supported, got %s", args[0].Type())
+	}
+}
+
+// float(x): 整数・文字列を浮動小数点数に変換する
+// float64 で表せない大きさの BigInt は ±Inf になる
+func builtinFloat(args ...object.Object) object.Object {
+	if err := checkArity("float", args, 1); err != nil {
+		return err
+	}
+
+	switch arg := args[0].(type) {
+	case *object.Float:
+		return arg
+	case *object.Integer, *object.BigInt:
+		return &object.Float{Value: toFloat(arg)}
+	case *object.String:
+		value, err := strconv.ParseFloat(strings.TrimSpace(arg.Value), 64)
+		if err != nil {
+			return newBuiltinError(object.TYPE_MISMATCH,
+				"cannot convert %q to FLOAT", arg.Value)
+		}
+		return &object.Float{Value: value}
+	default:
+		return newBuiltinError(object.TYPE_MISMATCH,
+			"argument to `float` not supported, got %s", args[0].Type())
+	}
+}
+
+// range(stop), range(start, stop), range(start, stop, step): start から stop の手前まで step ずつ進む整数の列
+// start の既定値は 0，step の既定値は 1．for 文で反復できる
+func builtinRange(args ...object.Object) object.Object {
+	if len(args) < 1 || len(args) > 3 {
+		return newBuiltinError(object.ARITY_MISMATCH,
+			"wrong number of arguments to `range`: want=1 to 3, got=%d", len(args))
+	}
+
+	bounds := make([]int64, len(args))
+	for i, arg := range args {
+		integer, ok := arg.(*object.Integer)
+		if !ok {
+			return newBuiltinError(object.TYPE_MISMATCH,
+				"arguments to `range` must be INTEGER, got %s", arg.Type())
+		}
+		bounds[i] = integer.Value
+	}
+
+	r := &object.Range{Step: 1}
+	switch len(bounds) {
+	case 1:
+		r.Stop = bounds[0]
+	case 2:
+		r.Start, r.Stop = bounds[0], bounds[1]
+	case 3:
+		r.Start, r.Stop, r.Step = bounds[0], bounds[1], bounds[2]
+	}
+
+	if r.Step == 0 {
+		return newBuiltinError(object.OUT_OF_RANGE, "`range` step must not be zero")
+	}
+	if !r.LenFits() {
+		return newBuiltinError(object.OUT_OF_RANGE,
+			"`range` has too many elements: %s", r.Inspect())
+	}
+
+	return r
+}
@@ -1,29 +1,1141 @@
 package evaluator
 
 import (
+	"fmt"
+	"math"
+	"math/big"
 	"monkey/ast"
 	"monkey/object"
+	"strings"
 )
 
-func Eval(node ast.Node) object.Object {
+// 関数呼び出しの深さの上限
+// 評価器は Go の再帰で関数を呼び出すので，Go のスタックを使い切る前に Monkey のエラーにする
+// バイトコードの仮想マシンも同じ上限を使う
+const MaxCallDepth = 1 << 16
+
+// 評価中の関数呼び出しの深さ
+var callDepth int
+
+func Eval(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
+	// 文
 	case *ast.Program:
-		return evalStatements(node.Statements)
+		return evalProgram(node, env)
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression)
+		return Eval(node.Expression, env)
+	case *ast.BlockStatement:
+		return evalBlockStatement(node, env)
+	case *ast.ReturnStatement:
+		val := Eval(node.ReturnValue, env)
+		if isAbrupt(val) {
+			return val
+		}
+		return &object.ReturnValue{Value: val}
+	case *ast.LetStatement:
+		val := Eval(node.Value, env)
+		if isAbrupt(val) {
+			return val
+		}
+		if val == nil {
+			val = object.NULL
+		}
+		// スタックトレースに表示できるように，束縛した名前を関数に覚えさせる
+		if fn, ok := val.(*object.Function); ok && fn.Name == "" {
+			fn.Name = node.Name.Value
+		}
+		env.Set(node.Name.Value, val)
+	case *ast.WhileStatement:
+		return evalWhileStatement(node, env)
+	case *ast.ForStatement:
+		return evalForStatement(node, env)
+	case *ast.BreakStatement:
+		return object.BREAK
+	case *ast.ContinueStatement:
+		return object.CONTINUE
+
+	// 式
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
+	case *ast.BigIntLiteral:
+		return &object.BigInt{Value: node.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
+	case *ast.Boolean:
+		return nativeBoolToBooleanObject(node.Value)
+	case *ast.StringLiteral:
+		return &object.String{Value: node.Value}
+	case *ast.PrefixExpression:
+		right := Eval(node.Right, env)
+		if isAbrupt(right) {
+			return right
+		}
+		return withPos(evalPrefixExpression(node.Operator, right), node)
+	case *ast.InfixExpression:
+		if node.Operator == "&&" || node.Operator == "||" {
+			return evalLogicalExpression(node, env)
+		}
+		left := Eval(node.Left, env)
+		if isAbrupt(left) {
+			return left
+		}
+		right := Eval(node.Right, env)
+		if isAbrupt(right) {
+			return right
+		}
+		return withPos(evalInfixExpression(node.Operator, left, right), node)
+	case *ast.AssignExpression:
+		return evalAssignExpression(node, env)
+	case *ast.IfExpression:
+		return evalIfExpression(node, env)
+	case *ast.Identifier:
+		return evalIdentifier(node, env)
+	case *ast.FunctionLiteral:
+		// 関数リテラルを評価した時点の環境を関数オブジェクトに閉じ込める
+		return &object.Function{Parameters: node.Parameters, Body: node.Body, Env: env}
+	case *ast.MacroLiteral:
+		return &object.Macro{Parameters: node.Parameters, Body: node.Body, Env: env}
+	case *ast.CallExpression:
+		// quote は引数を評価しない特別な形式
+		if isCallTo(node, "quote") {
+			return quote(node, env)
+		}
+		function := Eval(node.Function, env)
+		if isAbrupt(function) {
+			return function
+		}
+		args := evalExpressions(node.Arguments, env)
+		if len(args) == 1 && isAbrupt(args[0]) {
+			return args[0]
+		}
+		return applyFunction(node, function, args)
+	case *ast.ArrayLiteral:
+		elements := evalExpressions(node.Elements, env)
+		if len(elements) == 1 && isAbrupt(elements[0]) {
+			return elements[0]
+		}
+		return &object.Array{Elements: elements}
+	case *ast.IndexExpression:
+		left := Eval(node.Left, env)
+		if isAbrupt(left) {
+			return left
+		}
+		index := Eval(node.Index, env)
+		if isAbrupt(index) {
+			return index
+		}
+		return withPos(evalIndexExpression(left, index), node)
+	case *ast.SliceExpression:
+		return evalSliceExpression(node, env)
+	case *ast.HashLiteral:
+		return evalHashLiteral(node, env)
 	}
 
 	return nil
 }
 
-func evalStatements(stmts []ast.Statement) object.Object {
+// プログラム全体の評価
+// return文に到達したらそれ以降の文は評価せず，包んでいた値を取り出して返す
+// エラーが発生した場合もそこで評価を打ち切る
+func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	var result object.Object
 
-	for _, statement := range stmts {
-		result = Eval(statement)
+	for _, statement := range program.Statements {
+		result = Eval(statement, env)
+
+		switch result := result.(type) {
+		case *object.ReturnValue:
+			return result.Value
+		case *object.Error:
+			return result
+		}
+	}
+
+	return result
+}
+
+// ブロック文の評価
+// ネストしたブロックの内側でreturnされた場合に外側のブロックの評価も打ち切れるように，
+// ここでは ReturnValue を取り出さずにそのまま返す (break と continue も同様)
+func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+	// 空のブロック "{}" は null と評価する
+	var result object.Object = object.NULL
+
+	for _, statement := range block.Statements {
+		result = Eval(statement, env)
+
+		if isAbrupt(result) {
+			return result
+		}
+	}
+
+	return result
+}
+
+// while文の評価
+// 再帰と違って Go のスタックを消費しないので，繰り返しの回数に上限はない
+// let文と同じく値を持たないので nil を返す (return やエラーで抜けた場合はそれを返す)
+func evalWhileStatement(node *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		condition := Eval(node.Condition, env)
+		if isAbrupt(condition) {
+			return condition
+		}
+		if !isTruthy(condition) {
+			return nil
+		}
+
+		if result, done := evalLoopBody(node.Body, env); done {
+			return result
+		}
+	}
+}
+
+// for文の評価
+// 繰り返しのたびに新しい環境を作ってループ変数を束縛するので，本体で作ったクロージャはその回の値を覚える
+func evalForStatement(node *ast.ForStatement, env *object.Environment) object.Object {
+	iterable := Eval(node.Iterable, env)
+	if isAbrupt(iterable) {
+		return iterable
+	}
+
+	iter, ok := object.NewIterator(iterable)
+	if !ok {
+		return newError(node.Iterable, object.TYPE_MISMATCH, "cannot iterate over %s", typeOf(iterable))
+	}
+
+	for {
+		item, ok := iter.Next()
+		if !ok {
+			return nil
+		}
+
+		loopEnv := object.NewEnclosedEnvironment(env)
+		loopEnv.Set(node.Variable.Value, item)
+
+		if result, done := evalLoopBody(node.Body, loopEnv); done {
+			return result
+		}
+	}
+}
+
+// ループの本体を1回評価する
+// break・return・エラーでループを終えるなら done を true にして，ループの値として返すものを result に入れる
+// break と continue はここで取り除くので，外側のループには伝わらない
+func evalLoopBody(body *ast.BlockStatement, env *object.Environment) (result object.Object, done bool) {
+	switch result := evalBlockStatement(body, env).(type) {
+	case *object.Break:
+		return nil, true
+	case *object.ReturnValue, *object.Error:
+		return result, true
+	default:
+		return nil, false
+	}
+}
+
+// Goのbool値を対応するシングルトンのBooleanオブジェクトに変換する
+func nativeBoolToBooleanObject(input bool) *object.Boolean {
+	if input {
+		return object.TRUE
+	}
+	return object.FALSE
+}
+
+func evalPrefixExpression(operator string, right object.Object) object.Object {
+	switch operator {
+	case "!":
+		return evalBangOperatorExpression(right)
+	case "-":
+		return evalMinusPrefixOperatorExpression(right)
+	case "~":
+		return evalBitwiseNotExpression(right)
+	default:
+		return newOperatorError(object.UNKNOWN_OPERATOR,
+			"unknown operator: %s%s", operator, typeOf(right))
+	}
+}
+
+// 前置演算子"!"の評価
+// false と null だけが偽とみなされ，それ以外の値はすべて真として扱う
+func evalBangOperatorExpression(right object.Object) object.Object {
+	switch right {
+	case object.TRUE:
+		return object.FALSE
+	case object.FALSE:
+		return object.TRUE
+	case object.NULL:
+		return object.TRUE
+	default:
+		return object.FALSE
+	}
+}
+
+// 前置演算子"-"の評価
+// 整数と浮動小数点数以外に"-"を付けた場合はエラーとする
+func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
+	switch right := right.(type) {
+	case *object.Integer:
+		// -(-9223372036854775808) は int64 に収まらない
+		if right.Value == math.MinInt64 {
+			return normalizeBigInt(new(big.Int).Neg(toBigInt(right)))
+		}
+		return &object.Integer{Value: -right.Value}
+	case *object.BigInt:
+		return normalizeBigInt(new(big.Int).Neg(right.Value))
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
+		return newOperatorError(object.UNKNOWN_OPERATOR,
+			"unknown operator: -%s", typeOf(right))
+	}
+}
+
+// 前置演算子"~"の評価 (ビット反転)
+// 整数以外に"~"を付けた場合はエラーとする
+func evalBitwiseNotExpression(right object.Object) object.Object {
+	switch right := right.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: ^right.Value}
+	case *object.BigInt:
+		return normalizeBigInt(new(big.Int).Not(right.Value))
+	default:
+		return newOperatorError(object.UNKNOWN_OPERATOR,
+			"unknown operator: ~%s", typeOf(right))
+	}
+}
+
+// 論理演算子 "&&" と "||" の評価
+// 左辺だけで結果が決まる場合(false && ..., true || ...)は右辺を評価しない
+// 結果は真偽値で，左辺と右辺は "!" や if と同じく false と null だけを偽とみなす
+func evalLogicalExpression(node *ast.InfixExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isAbrupt(left) {
+		return left
+	}
+
+	if node.Operator == "&&" && !isTruthy(left) {
+		return object.FALSE
+	}
+	if node.Operator == "||" && isTruthy(left) {
+		return object.TRUE
+	}
+
+	right := Eval(node.Right, env)
+	if isAbrupt(right) {
+		return right
+	}
+	return nativeBoolToBooleanObject(isTruthy(right))
+}
+
+func evalInfixExpression(
+	operator string,
+	left, right object.Object,
+) object.Object {
+
+	switch {
+	case left == nil || right == nil:
+		return newOperatorError(object.TYPE_MISMATCH,
+			"type mismatch: %s %s %s", typeOf(left), operator, typeOf(right))
+	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
+		return evalIntegerInfixExpression(operator, left, right)
+	case isInteger(left) && isInteger(right):
+		return evalBigIntInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(operator, left, right)
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		return evalStringInfixExpression(operator, left, right)
+	// true, false はシングルトンなので，ポインタの比較で等価性を判定できる
+	case operator == "==":
+		return nativeBoolToBooleanObject(left == right)
+	case operator == "!=":
+		return nativeBoolToBooleanObject(left != right)
+	case left.Type() != right.Type():
+		return newOperatorError(object.TYPE_MISMATCH,
+			"type mismatch: %s %s %s", left.Type(), operator, right.Type())
+	default:
+		return newOperatorError(object.UNKNOWN_OPERATOR,
+			"unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func evalIntegerInfixExpression(
+	operator string,
+	left, right object.Object,
+) object.Object {
+	leftVal := left.(*object.Integer).Value
+	rightVal := right.(*object.Integer).Value
+
+	// 結果が int64 に収まらない場合は BigInt で計算し直す
+	switch operator {
+	case "+":
+		sum := leftVal + rightVal
+		if (leftVal > 0 && rightVal > 0 && sum < 0) || (leftVal < 0 && rightVal < 0 && sum >= 0) {
+			return evalBigIntInfixExpression(operator, left, right)
+		}
+		return &object.Integer{Value: sum}
+	case "-":
+		diff := leftVal - rightVal
+		if (leftVal >= 0 && rightVal < 0 && diff < 0) || (leftVal < 0 && rightVal > 0 && diff >= 0) {
+			return evalBigIntInfixExpression(operator, left, right)
+		}
+		return &object.Integer{Value: diff}
+	case "*":
+		product := leftVal * rightVal
+		if leftVal != 0 && (product/leftVal != rightVal || (leftVal == -1 && rightVal == math.MinInt64)) {
+			return evalBigIntInfixExpression(operator, left, right)
+		}
+		return &object.Integer{Value: product}
+	case "/":
+		// 0除算でGoがpanicしないようにエラーを返す
+		if rightVal == 0 {
+			return newOperatorError(object.DIVISION_BY_ZERO, "division by zero: %d / 0", leftVal)
+		}
+		if leftVal == math.MinInt64 && rightVal == -1 {
+			return evalBigIntInfixExpression(operator, left, right)
+		}
+		return &object.Integer{Value: leftVal / rightVal}
+	case "%":
+		if rightVal == 0 {
+			return newOperatorError(object.DIVISION_BY_ZERO, "division by zero: %d %% 0", leftVal)
+		}
+		return &object.Integer{Value: leftVal % rightVal}
+	case "&":
+		return &object.Integer{Value: leftVal & rightVal}
+	case "|":
+		return &object.Integer{Value: leftVal | rightVal}
+	case "^":
+		return &object.Integer{Value: leftVal ^ rightVal}
+	case "<<", ">>":
+		return evalShiftExpression(operator, left, right)
+	case "**":
+		return evalPowerExpression(operator, left, right)
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newOperatorError(object.UNKNOWN_OPERATOR,
+			"unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// シフトやべき乗の結果として作る整数のビット数の上限
+// これを超える計算はメモリを使い果たすおそれがあるのでエラーにする
+const maxIntegerBits = 1 << 20
+
+// 整数のシフト演算 "<<" と ">>"
+// "<<" の結果が int64 に収まらない場合は BigInt になる．">>" は符号を保つ算術シフト
+// シフト量は0以上でなければならない
+func evalShiftExpression(
+	operator string,
+	left, right object.Object,
+) object.Object {
+	count := toBigInt(right)
+	if count.Sign() < 0 {
+		return newOperatorError(object.OUT_OF_RANGE, "negative shift count: %s", count)
+	}
+
+	if operator == ">>" {
+		if !count.IsInt64() || count.Int64() > maxIntegerBits {
+			// 上限のビット数より大きく右シフトすると，値によらず 0 か -1 になる
+			count = big.NewInt(maxIntegerBits)
+		}
+		return normalizeBigInt(new(big.Int).Rsh(toBigInt(left), uint(count.Int64())))
+	}
+
+	// 足し算はシフト量が大きいと int64 で桁あふれするので，引き算で比べる
+	value := toBigInt(left)
+	if !count.IsInt64() || count.Int64() > maxIntegerBits-int64(value.BitLen()) {
+		return newOperatorError(object.OUT_OF_RANGE, "shift count too large: %s", count)
+	}
+	return normalizeBigInt(new(big.Int).Lsh(value, uint(count.Int64())))
+}
+
+// べき乗 "**"
+// 整数同士で指数が0以上なら結果も整数(必要なら BigInt)になり，
+// 指数が負の場合やどちらかが浮動小数点数の場合は math.Pow で浮動小数点数として計算する
+func evalPowerExpression(
+	operator string,
+	left, right object.Object,
+) object.Object {
+	if !isInteger(left) || !isInteger(right) || toBigInt(right).Sign() < 0 {
+		return &object.Float{Value: math.Pow(toFloat(left), toFloat(right))}
+	}
+
+	base := toBigInt(left)
+	exp := toBigInt(right)
+
+	// 0, 1, -1 は指数がどれだけ大きくても結果が決まっている
+	if base.CmpAbs(big.NewInt(1)) <= 0 {
+		switch {
+		case exp.Sign() == 0:
+			return &object.Integer{Value: 1}
+		case base.Sign() < 0 && exp.Bit(0) == 1:
+			return &object.Integer{Value: -1}
+		case base.Sign() < 0:
+			return &object.Integer{Value: 1}
+		default:
+			return &object.Integer{Value: base.Int64()}
+		}
+	}
+
+	// ここでは |base| >= 2 なので base.BitLen()-1 は1以上
+	// 掛け算は指数が大きいと int64 で桁あふれするので，割り算で比べる
+	if !exp.IsInt64() || exp.Int64() > maxIntegerBits/int64(base.BitLen()-1) {
+		return newOperatorError(object.OUT_OF_RANGE, "exponent too large: %s ** %s", base, exp)
+	}
+	return normalizeBigInt(new(big.Int).Exp(base, exp, nil))
+}
+
+// 少なくとも一方が BigInt の整数同士の演算
+// math/big で計算し，結果が int64 に収まれば Integer に戻す
+// "/" は Integer と同じく0の方向に切り捨てる
+func evalBigIntInfixExpression(
+	operator string,
+	left, right object.Object,
+) object.Object {
+	leftVal := toBigInt(left)
+	rightVal := toBigInt(right)
+
+	switch operator {
+	case "+":
+		return normalizeBigInt(new(big.Int).Add(leftVal, rightVal))
+	case "-":
+		return normalizeBigInt(new(big.Int).Sub(leftVal, rightVal))
+	case "*":
+		return normalizeBigInt(new(big.Int).Mul(leftVal, rightVal))
+	case "/":
+		if rightVal.Sign() == 0 {
+			return newOperatorError(object.DIVISION_BY_ZERO, "division by zero: %s / 0", leftVal)
+		}
+		return normalizeBigInt(new(big.Int).Quo(leftVal, rightVal))
+	case "%":
+		if rightVal.Sign() == 0 {
+			return newOperatorError(object.DIVISION_BY_ZERO, "division by zero: %s %% 0", leftVal)
+		}
+		return normalizeBigInt(new(big.Int).Rem(leftVal, rightVal))
+	case "&":
+		return normalizeBigInt(new(big.Int).And(leftVal, rightVal))
+	case "|":
+		return normalizeBigInt(new(big.Int).Or(leftVal, rightVal))
+	case "^":
+		return normalizeBigInt(new(big.Int).Xor(leftVal, rightVal))
+	case "<<", ">>":
+		return evalShiftExpression(operator, left, right)
+	case "**":
+		return evalPowerExpression(operator, left, right)
+	case "<":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) < 0)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) > 0)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) <= 0)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) >= 0)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) == 0)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) != 0)
+	default:
+		return newOperatorError(object.UNKNOWN_OPERATOR,
+			"unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// 整数(Integer または BigInt)かどうか
+func isInteger(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Integer, *object.BigInt:
+		return true
+	}
+	return false
+}
+
+// 整数の値を *big.Int に変換する
+// BigInt の値は共有されているので，書き換えずに新しい値を作って使うこと
+func toBigInt(obj object.Object) *big.Int {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return big.NewInt(obj.Value)
+	case *object.BigInt:
+		return obj.Value
+	}
+	return new(big.Int)
+}
+
+// 計算結果が int64 に収まれば Integer に，収まらなければ BigInt にする
+func normalizeBigInt(v *big.Int) object.Object {
+	if v.IsInt64() {
+		return &object.Integer{Value: v.Int64()}
+	}
+	return &object.BigInt{Value: v}
+}
+
+// 浮動小数点数を含む数値同士の演算
+// 整数と浮動小数点数の組み合わせでは，整数を浮動小数点数に変換してから計算し，結果は浮動小数点数になる
+// 演算は IEEE 754 に従うので，0 での除算はエラーにならず +Inf, -Inf, NaN になる
+// NaN はどの値とも(NaN 自身とも)等しくなく，大小の比較はすべて false になる
+func evalFloatInfixExpression(
+	operator string,
+	left, right object.Object,
+) object.Object {
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
+
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
+	case "/":
+		return &object.Float{Value: leftVal / rightVal}
+	case "%":
+		return &object.Float{Value: math.Mod(leftVal, rightVal)}
+	case "**":
+		return &object.Float{Value: math.Pow(leftVal, rightVal)}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newOperatorError(object.UNKNOWN_OPERATOR,
+			"unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// 整数または浮動小数点数かどうか
+func isNumber(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Integer, *object.BigInt, *object.Float:
+		return true
+	}
+	return false
+}
+
+// 整数または浮動小数点数の値を float64 に変換する
+// float64 で表せない大きさの BigInt は ±Inf になる
+func toFloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.BigInt:
+		f, _ := new(big.Float).SetInt(obj.Value).Float64()
+		return f
+	case *object.Float:
+		return obj.Value
+	}
+	return 0
+}
+
+// 文字列同士の演算
+// "+" は連結，比較演算子はバイト列の辞書順で比較する
+func evalStringInfixExpression(
+	operator string,
+	left, right object.Object,
+) object.Object {
+	leftVal := left.(*object.String).Value
+	rightVal := right.(*object.String).Value
+
+	switch operator {
+	case "+":
+		return &object.String{Value: leftVal + rightVal}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newOperatorError(object.UNKNOWN_OPERATOR,
+			"unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// if式の評価
+// 条件が偽で else がない場合は null を返す
+func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+	condition := Eval(ie.Condition, env)
+	if isAbrupt(condition) {
+		return condition
+	}
+
+	if isTruthy(condition) {
+		return Eval(ie.Consequence, env)
+	} else if ie.Alternative != nil {
+		return Eval(ie.Alternative, env)
+	} else {
+		return object.NULL
+	}
+}
+
+// Monkeyの真偽値判定 (truthy)
+// null と false 以外はすべて真とみなす (0 も真)
+func isTruthy(obj object.Object) bool {
+	switch obj {
+	case nil, object.NULL, object.FALSE:
+		return false
+	default:
+		return true
+	}
+}
+
+// 識別子の評価
+// 環境に見つからなければ組み込み関数から探す (同名の変数があれば変数が優先される)
+func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
+	if val, ok := env.Get(node.Value); ok {
+		return val
+	}
+
+	if builtin, ok := builtins[node.Value]; ok {
+		return builtin
+	}
+
+	return newError(node, object.UNBOUND_IDENTIFIER, "identifier not found: %s", node.Value)
+}
+
+// 添字式の評価
+func evalIndexExpression(left, index object.Object) object.Object {
+	switch left := left.(type) {
+	case *object.Array:
+		return evalArrayIndexExpression(left, index)
+	case *object.Hash:
+		return evalHashIndexExpression(left, index)
+	default:
+		return newOperatorError(object.UNKNOWN_OPERATOR, "index operator not supported: %s", typeOf(left))
+	}
+}
+
+// 配列の添字アクセス
+// 負の添字は末尾から数える (arr[-1] は最後の要素)
+func evalArrayIndexExpression(array *object.Array, index object.Object) object.Object {
+	i, err := arrayIndex(array, index)
+	if err != nil {
+		return err
+	}
+
+	return array.Elements[i]
+}
+
+// 配列の添字を要素の位置に変換する
+// 負の添字は末尾からの位置に直し，範囲外ならエラーを返す
+func arrayIndex(array *object.Array, index object.Object) (int64, *object.Error) {
+	idx, ok := index.(*object.Integer)
+	if !ok {
+		return 0, newOperatorError(object.TYPE_MISMATCH, "array index must be INTEGER, got %s", typeOf(index))
+	}
+
+	length := int64(len(array.Elements))
+	i := idx.Value
+	if i < 0 {
+		i += length
+	}
+	if i < 0 || i >= length {
+		return 0, newOperatorError(object.INDEX_OUT_OF_RANGE,
+			"index out of range: index %d, length %d", idx.Value, length)
+	}
+
+	return i, nil
+}
+
+// ハッシュの添字アクセス
+// キーが存在しない場合は null を返す
+func evalHashIndexExpression(hash *object.Hash, index object.Object) object.Object {
+	key, ok := index.(object.Hashable)
+	if !ok {
+		return newOperatorError(object.UNHASHABLE_KEY, "unusable as hash key: %s", typeOf(index))
+	}
+
+	value, ok := hash.Get(key)
+	if !ok {
+		return object.NULL
+	}
+
+	return value
+}
+
+// 代入式の評価
+// x += 1 のような複合代入は，代入先の今の値を右辺より先に読み，右辺と中値演算子で計算してから代入する
+// 式の値は代入した値になるので a = b = 1 のように連ねて書ける
+func evalAssignExpression(node *ast.AssignExpression, env *object.Environment) object.Object {
+	// 添字式の代入先は，配列やハッシュと添字を右辺より先に評価する
+	var container, index object.Object
+	if target, ok := node.Target.(*ast.IndexExpression); ok {
+		container = Eval(target.Left, env)
+		if isAbrupt(container) {
+			return container
+		}
+		index = Eval(target.Index, env)
+		if isAbrupt(index) {
+			return index
+		}
+	}
+
+	var current object.Object
+	if node.Operator != "=" {
+		switch target := node.Target.(type) {
+		case *ast.Identifier:
+			current = evalIdentifier(target, env)
+		case *ast.IndexExpression:
+			current = withPos(evalIndexExpression(container, index), target)
+		}
+		if isAbrupt(current) {
+			return current
+		}
+	}
+
+	val := Eval(node.Value, env)
+	if isAbrupt(val) {
+		return val
+	}
+	if val == nil {
+		val = object.NULL
+	}
+
+	if current != nil {
+		val = withPos(evalInfixExpression(strings.TrimSuffix(node.Operator, "="), current, val), node)
+		if isAbrupt(val) {
+			return val
+		}
+	}
+
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		if _, ok := env.Assign(target.Value, val); !ok {
+			return newError(target, object.UNBOUND_IDENTIFIER,
+				"cannot assign to undeclared identifier: %s", target.Value)
+		}
+	case *ast.IndexExpression:
+		if err := evalIndexAssignment(container, index, val); err != nil {
+			return withPos(err, target)
+		}
+	}
+
+	return val
+}
+
+// 配列やハッシュの要素への代入
+// 配列は範囲内の添字にしか代入できない．ハッシュはキーがなければ追加する
+func evalIndexAssignment(container, index, val object.Object) *object.Error {
+	switch container := container.(type) {
+	case *object.Array:
+		i, err := arrayIndex(container, index)
+		if err != nil {
+			return err
+		}
+		container.Elements[i] = val
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newOperatorError(object.UNHASHABLE_KEY, "unusable as hash key: %s", typeOf(index))
+		}
+		container.Set(key, val)
+	default:
+		return newOperatorError(object.UNKNOWN_OPERATOR, "index assignment not supported: %s", typeOf(container))
+	}
+	return nil
+}
+
+// ハッシュリテラルの評価
+// キーと値はソースコードに書かれた順に評価する
+func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+	hash := object.NewHash()
+
+	for _, pair := range node.Pairs {
+		key := Eval(pair.Key, env)
+		if isAbrupt(key) {
+			return key
+		}
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return newError(pair.Key, object.UNHASHABLE_KEY, "unusable as hash key: %s", typeOf(key))
+		}
+
+		value := Eval(pair.Value, env)
+		if isAbrupt(value) {
+			return value
+		}
+
+		hash.Set(hashKey, value)
+	}
+
+	return hash
+}
+
+// スライス式の評価
+// 配列と境界を左から順に評価してからスライスを作る (省略した境界は nil として渡す)
+func evalSliceExpression(node *ast.SliceExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isAbrupt(left) {
+		return left
+	}
+
+	var low, high object.Object
+	if node.Low != nil {
+		low = Eval(node.Low, env)
+		if isAbrupt(low) {
+			return low
+		}
+	}
+	if node.High != nil {
+		high = Eval(node.High, env)
+		if isAbrupt(high) {
+			return high
+		}
+	}
+
+	return withPos(evalSlice(left, low, high), node)
+}
+
+// arr[low:high] は low 番目から high-1 番目までの要素を持つ新しい配列を返す
+// low が nil なら先頭から，high が nil なら末尾までとなる．負の境界は末尾から数える
+func evalSlice(left, low, high object.Object) object.Object {
+	array, ok := left.(*object.Array)
+	if !ok {
+		return newOperatorError(object.UNKNOWN_OPERATOR, "slice operator not supported: %s", typeOf(left))
+	}
+
+	length := int64(len(array.Elements))
+	lo, hi := int64(0), length
+
+	if low != nil {
+		val, err := sliceBound(low, length)
+		if err != nil {
+			return err
+		}
+		lo = val
+	}
+	if high != nil {
+		val, err := sliceBound(high, length)
+		if err != nil {
+			return err
+		}
+		hi = val
+	}
+
+	if lo < 0 || hi > length || lo > hi {
+		return newOperatorError(object.INDEX_OUT_OF_RANGE,
+			"slice bounds out of range: [%d:%d] with length %d", lo, hi, length)
+	}
+
+	// 元の配列と要素の並びを共有しないようにコピーする
+	elements := make([]object.Object, hi-lo)
+	copy(elements, array.Elements[lo:hi])
+
+	return &object.Array{Elements: elements}
+}
+
+// スライスの境界の負の値を末尾からの位置に直して返す
+func sliceBound(bound object.Object, length int64) (int64, *object.Error) {
+	integer, ok := bound.(*object.Integer)
+	if !ok {
+		return 0, newOperatorError(object.TYPE_MISMATCH, "slice index must be INTEGER, got %s", typeOf(bound))
+	}
+
+	if integer.Value < 0 {
+		return integer.Value + length, nil
+	}
+	return integer.Value, nil
+}
+
+// 関数呼び出しの引数を左から順に評価する
+// 途中でエラーが発生したり break などで抜けたりしたら，残りの引数は評価せずにそれだけを返す
+func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
+	var result []object.Object
+
+	for _, e := range exps {
+		evaluated := Eval(e, env)
+		if isAbrupt(evaluated) {
+			return []object.Object{evaluated}
+		}
+		if evaluated == nil {
+			evaluated = object.NULL
+		}
+		result = append(result, evaluated)
 	}
 
 	return result
 }
+
+// 関数の適用
+// 関数が定義された環境を外側に持つ新しい環境で本体を評価することで，クロージャとして振る舞う
+func applyFunction(node *ast.CallExpression, fn object.Object, args []object.Object) object.Object {
+	if builtin, ok := fn.(*object.Builtin); ok {
+		return applyBuiltin(node, builtin, args)
+	}
+
+	function, ok := fn.(*object.Function)
+	if !ok {
+		return newError(node, object.TYPE_MISMATCH, "not a function: %s", typeOf(fn))
+	}
+
+	if len(args) != len(function.Parameters) {
+		err := newError(node, object.ARITY_MISMATCH,
+			"wrong number of arguments: want=%d, got=%d", len(function.Parameters), len(args))
+		return pushStackFrame(err, function)
+	}
+
+	if callDepth >= MaxCallDepth {
+		return newError(node, object.STACK_OVERFLOW, "maximum call depth exceeded")
+	}
+	callDepth++
+	defer func() { callDepth-- }()
+
+	extendedEnv := extendFunctionEnv(function, args)
+	evaluated := Eval(function.Body, extendedEnv)
+	if err, ok := evaluated.(*object.Error); ok {
+		return pushStackFrame(err, function)
+	}
+	return unwrapReturnValue(evaluated)
+}
+
+// 組み込み関数の適用
+// 組み込み関数はノードを知らないので，返ってきたエラーに呼び出し式の位置と関数名を付ける
+func applyBuiltin(node *ast.CallExpression, builtin *object.Builtin, args []object.Object) object.Object {
+	result := builtin.Fn(args...)
+
+	if err, ok := result.(*object.Error); ok {
+		if !err.Pos.IsValid() {
+			err.Pos = node.Pos()
+		}
+		err.Stack = append(err.Stack, builtin.Name)
+		return err
+	}
+	if result == nil {
+		return object.NULL
+	}
+
+	return result
+}
+
+// 仮引数に実引数を束縛した環境を作る
+func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+	env := object.NewEnclosedEnvironment(fn.Env)
+
+	for paramIdx, param := range fn.Parameters {
+		env.Set(param.Value, args[paramIdx])
+	}
+
+	return env
+}
+
+// 関数本体で return された値を取り出す
+// ここで取り出しておかないと，呼び出し元の評価まで打ち切られてしまう
+func unwrapReturnValue(obj object.Object) object.Object {
+	if returnValue, ok := obj.(*object.ReturnValue); ok {
+		return returnValue.Value
+	}
+
+	return obj
+}
+
+// エラーが関数から抜け出るたびに，その関数名をスタックに積む
+func pushStackFrame(err *object.Error, fn *object.Function) *object.Error {
+	name := fn.Name
+	if name == "" {
+		name = "<anonymous>"
+	}
+	err.Stack = append(err.Stack, name)
+	return err
+}
+
+// エラーオブジェクトを作る
+// 位置にはエラーの原因となったノードの先頭を使う
+func newError(node ast.Node, kind object.ErrorKind, format string, a ...interface{}) *object.Error {
+	err := newOperatorError(kind, format, a...)
+	err.Pos = node.Pos()
+	return err
+}
+
+// 位置を持たないエラーオブジェクトを作る
+// 演算子の適用のようにノードを知らない処理で使い，位置は呼び出し側が withPos で入れる
+func newOperatorError(kind object.ErrorKind, format string, a ...interface{}) *object.Error {
+	return &object.Error{Kind: kind, Message: fmt.Sprintf(format, a...)}
+}
+
+// 結果がまだ位置を持たないエラーなら，ノードの位置を入れる
+func withPos(obj object.Object, node ast.Node) object.Object {
+	if err, ok := obj.(*object.Error); ok && !err.Pos.IsValid() {
+		err.Pos = node.Pos()
+	}
+	return obj
+}
+
+// 評価を打ち切って外側へ伝える値 (エラー・return・break・continue) かどうか
+// 式の途中でこれらが現れたら，残りの部分式は評価せずにそのまま返す
+func isAbrupt(obj object.Object) bool {
+	if obj == nil {
+		return false
+	}
+	switch obj.Type() {
+	case object.ERROR_OBJ, object.RETURN_VALUE_OBJ, object.BREAK_OBJ, object.CONTINUE_OBJ:
+		return true
+	}
+	return false
+}
+
+// エラーメッセージ用に型名を返す (nil の場合も落ちないようにする)
+func typeOf(obj object.Object) object.ObjectType {
+	if obj == nil {
+		return object.NULL_OBJ
+	}
+	return obj.Type()
+}
+
+// 以下は評価器と同じ規則で値を計算する関数で，バイトコードの仮想マシン(vm パッケージ)と共有する
+// 返すエラーは位置を持たないので，呼び出し側で位置を入れること
+
+// 前置演算子 (!, -, ~) を値に適用する
+func PrefixOperator(operator string, right object.Object) object.Object {
+	return evalPrefixExpression(operator, right)
+}
+
+// 中値演算子を値に適用する
+// 短絡評価する && と || は含まない
+func InfixOperator(operator string, left, right object.Object) object.Object {
+	return evalInfixExpression(operator, left, right)
+}
+
+// 添字演算子 left[index] を適用する
+func IndexOperator(left, index object.Object) object.Object {
+	return evalIndexExpression(left, index)
+}
+
+// 配列やハッシュの要素 left[index] に値を代入する
+func IndexAssign(left, index, value object.Object) *object.Error {
+	return evalIndexAssignment(left, index, value)
+}
+
+// スライス left[low:high] を作る (省略した境界は nil)
+func SliceOperator(left, low, high object.Object) object.Object {
+	return evalSlice(left, low, high)
+}
+
+// Monkeyの真偽値判定 (null と false 以外はすべて真)
+func IsTruthy(obj object.Object) bool {
+	return isTruthy(obj)
+}
@@ -1,6 +1,8 @@
 package evaluator
 
 import (
+	"bytes"
+	"math"
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
@@ -14,6 +16,19 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}{
 		{"5", 5},
 		{"10", 10},
+		{"-5", -5},
+		{"-10", -10},
+		{"5 + 5 + 5 + 5 - 10", 10},
+		{"2 * 2 * 2 * 2 * 2", 32},
+		{"-50 + 100 + -50", 0},
+		{"5 * 2 + 10", 20},
+		{"5 + 2 * 10", 25},
+		{"20 + 2 * -10", 0},
+		{"50 / 2 * 2 + 10", 60},
+		{"2 * (5 + 10)", 30},
+		{"3 * 3 * 3 + 10", 37},
+		{"3 * (3 * 3) + 10", 37},
+		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
 	}
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
@@ -21,12 +36,1216 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14", 3.14},
+		{"-2.5", -2.5},
+		{"1e3", 1000},
+		{"1.5e-3", 0.0015},
+		{"1_000.5", 1000.5},
+		{"0.1 + 0.2", 0.30000000000000004},
+		{"1.5 * 2", 3},
+		{"2 * 1.5", 3},
+		{"7 / 2.0", 3.5},
+		{"1 + 0.5", 1.5},
+		{"10 - 0.25", 9.75},
+		{"-(1.5 + 1)", -2.5},
+		{"1.0 / 0", math.Inf(1)},
+		{"-1 / 0.0", math.Inf(-1)},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testFloatObject(t, evaluated, tt.expected)
+	}
+}
+
+// NaN は自身とも等しくないので，値ではなく math.IsNaN で確かめる
+func TestEvalFloatNaN(t *testing.T) {
+	evaluated := testEval("0.0 / 0")
+	result, ok := evaluated.(*object.Float)
+	if !ok || !math.IsNaN(result.Value) {
+		t.Fatalf("0.0 / 0 is not NaN. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"let nan = 0.0 / 0; nan == nan", false},
+		{"let nan = 0.0 / 0; nan != nan", true},
+		{"let nan = 0.0 / 0; nan < 1", false},
+		{"let nan = 0.0 / 0; nan > 1", false},
+		{"1.0 / 0 > 1e308", true},
+	}
+
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestFloatInspectRoundTrips(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1.0", "1.0"},
+		{"2.50", "2.5"},
+		{"0.1 + 0.2", "0.30000000000000004"},
+		{"1e21", "1e+21"},
+		{"1e-9", "1e-09"},
+		{"-0.0", "-0.0"},
+		{"100000.0", "100000.0"},
+		{"1.0 / 3", "0.3333333333333333"},
+		{"1.0 / 0", "+Inf"},
+		{"0.0 / 0", "NaN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("Inspect() wrong for %s. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+			continue
+		}
+
+		f := evaluated.(*object.Float).Value
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			continue
+		}
+		if reparsed := testEval(evaluated.Inspect()); !testFloatObject(t, reparsed, f) {
+			t.Errorf("Inspect() of %s does not round-trip", tt.input)
+		}
+	}
+}
+
+func TestBigIntPromotion(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775807 + 1", "9223372036854775808"},
+		{"9223372036854775808", "9223372036854775808"},
+		{"0x1_0000_0000_0000_0000", "18446744073709551616"},
+		{"-9223372036854775807 - 2", "-9223372036854775809"},
+		{"0 - 9223372036854775807 - 1 - 1", "-9223372036854775809"},
+		{"4611686018427387904 * 2", "9223372036854775808"},
+		{"-9223372036854775807 - 1 - 0", "-9223372036854775808"},
+		{"-(-9223372036854775807 - 1)", "9223372036854775808"},
+		{"(-9223372036854775807 - 1) / -1", "9223372036854775808"},
+		{"(-9223372036854775807 - 1) * -1", "9223372036854775808"},
+		{"let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(30)",
+			"265252859812191058636308480000000"},
+		{"(9223372036854775807 + 1) * (9223372036854775807 + 1)", "85070591730234615865843651857942052864"},
+		{"(9223372036854775807 + 10) / 3", "3074457345618258605"},
+		{"-(9223372036854775807 + 1) / 7", "-1317624576693539401"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%s, got=%v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestBigIntDemotion(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"(9223372036854775807 + 1) - 1", 9223372036854775807},
+		{"(9223372036854775807 + 1) - (9223372036854775807 + 1)", 0},
+		{"(9223372036854775807 * 4) / 4", 9223372036854775807},
+		{"-(-(9223372036854775807 + 1)) - 2", 9223372036854775806},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestBigIntOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let big = 9223372036854775807 + 1; big > 9223372036854775807", true},
+		{"let big = 9223372036854775807 + 1; big < 1", false},
+		{"let big = 9223372036854775807 + 1; big == 9223372036854775807 + 1", true},
+		{"let big = 9223372036854775807 + 1; big != big * 2", true},
+		{"let big = 9223372036854775807 + 1; big == 1", false},
+		{"let big = 9223372036854775807 + 1; big / 0", "division by zero: 9223372036854775808 / 0"},
+		{"let big = 9223372036854775807 + 1; big / 2.0", 4611686018427387904.0},
+		{"let big = 9223372036854775807 + 1; big > 1.5", true},
+		{"let big = 9223372036854775807 + 1; type(big)", "BIGINT"},
+		{"let big = 9223372036854775807 + 1; {big: 1}[big * 1]", 1},
+		{"let big = 9223372036854775807 + 1; big + true", "type mismatch: BIGINT + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case float64:
+			testFloatObject(t, evaluated, expected)
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				if errObj.Message != expected {
+					t.Errorf("wrong error message for %s. expected=%q, got=%q", tt.input, expected, errObj.Message)
+				}
+			} else {
+				testStringObject(t, evaluated, expected)
+			}
+		}
+	}
+}
+
+func TestArithmeticAndBitwiseOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"7 % 3", 7 % 3},
+		{"-7 % 3", -7 % 3},
+		{"7 % -3", 7 % -3},
+		{"7 % 0", "division by zero: 7 % 0"},
+		{"7.5 % 2", 1.5},
+		{"-7.5 % 2", -1.5},
+		{"6 & 3", 6 & 3},
+		{"6 | 3", 6 | 3},
+		{"6 ^ 3", 6 ^ 3},
+		{"~5", ^5},
+		{"~-1", 0},
+		{"1 << 10", 1024},
+		{"-8 >> 1", -4},
+		{"1 >> 100", 0},
+		{"-1 >> 100", -1},
+		{"1 << -1", "negative shift count: -1"},
+		{"1 << 2000000", "shift count too large: 2000000"},
+		{"1 << 9223372036854775807", "shift count too large: 9223372036854775807"},
+		{"(1 << 64) << 9223372036854775807", "shift count too large: 9223372036854775807"},
+		{"1 << 9223372036854775808", "shift count too large: 9223372036854775808"},
+		{"str(1 << 64)", "18446744073709551616"},
+		{"(1 << 64) >> 63", 2},
+		{"str((1 << 64) | 1)", "18446744073709551617"},
+		{"((1 << 64) + 5) & 7", 5},
+		{"((1 << 64) + 5) % 4", 1},
+		{"str(~(1 << 64))", "-18446744073709551617"},
+		{"2 ** 10", 1024},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", -4},
+		{"(-2) ** 3", -8},
+		{"0 ** 0", 1},
+		{"(-1) ** 1000000000001", -1},
+		{"2 ** -1", 0.5},
+		{"2.0 ** 0.5", math.Sqrt2},
+		{"4 ** 0.5", 2.0},
+		{"str(2 ** 100)", "1267650600228229401496703205376"},
+		{"str(10 ** 30 / 10 ** 29)", "10"},
+		{"2 ** 10000000", "exponent too large: 2 ** 10000000"},
+		{"4 ** 9223372036854775807", "exponent too large: 4 ** 9223372036854775807"},
+		{"(1 << 64) ** 9223372036854775807", "exponent too large: 18446744073709551616 ** 9223372036854775807"},
+		{"(-3) ** 4611686018427387904", "exponent too large: -3 ** 4611686018427387904"},
+		{"1.5 & 1", "unknown operator: FLOAT & INTEGER"},
+		{"~1.5", "unknown operator: ~FLOAT"},
+		{`"a" % "b"`, "unknown operator: STRING % STRING"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case float64:
+			testFloatObject(t, evaluated, expected)
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				if errObj.Message != expected {
+					t.Errorf("wrong error message for %s. expected=%q, got=%q", tt.input, expected, errObj.Message)
+				}
+			} else {
+				testStringObject(t, evaluated, expected)
+			}
+		}
+	}
+}
+
+func TestComparisonAndLogicalOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"1 <= 1", true},
+		{"1 <= 0", false},
+		{"1 >= 2", false},
+		{"2 >= 2", true},
+		{"1.5 <= 1", false},
+		{"1 >= 0.5", true},
+		{`"abc" <= "abd"`, true},
+		{`"b" >= "a"`, true},
+		{"(1 << 64) >= (1 << 64)", true},
+		{"(1 << 64) <= 1", false},
+		{"true && true", true},
+		{"true && false", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 && 0", true},
+		{"null_value() || 1", true},
+		{"false && undefined", false},
+		{"true || undefined", true},
+		{"let x = 5; x > 1 && x < 10", true},
+		{"let x = 5; x < 1 || x > 10", false},
+	}
+
+	for _, tt := range tests {
+		input := "let null_value = fn() { if (false) { 1 } }; " + tt.input
+		testBooleanObject(t, testEval(input), tt.expected)
+	}
+
+	// 左辺で結果が決まらなければ右辺を評価するので，右辺のエラーはそのまま返る
+	testErrorObject(t, testEval("true && undefined"), object.UNBOUND_IDENTIFIER, "identifier not found: undefined")
+	testErrorObject(t, testEval("false || undefined"), object.UNBOUND_IDENTIFIER, "identifier not found: undefined")
+}
+
+func TestEvalBooleanExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"true", true},
+		{"false", false},
+		{"1 < 2", true},
+		{"1 > 2", false},
+		{"1 < 1", false},
+		{"1 > 1", false},
+		{"1 == 1", true},
+		{"1 != 1", false},
+		{"1 == 2", false},
+		{"1 != 2", true},
+		{"true == true", true},
+		{"false == false", true},
+		{"true == false", false},
+		{"true != false", true},
+		{"false != true", true},
+		{"(1 < 2) == true", true},
+		{"(1 < 2) == false", false},
+		{"(1 > 2) == true", false},
+		{"(1 > 2) == false", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestBangOperator(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"!true", false},
+		{"!false", true},
+		{"!5", false},
+		{"!!true", true},
+		{"!!false", false},
+		{"!!5", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestIfElseExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"if (true) { 10 }", 10},
+		{"if (false) { 10 }", nil},
+		{"if (1) { 10 }", 10},
+		{"if (1 < 2) { 10 }", 10},
+		{"if (1 > 2) { 10 }", nil},
+		{"if (1 > 2) { 10 } else { 20 }", 20},
+		{"if (1 < 2) { 10 } else { 20 }", 10},
+		{"if (true) { }", nil},
+		{"if (if (false) { 1 }) { 10 } else { 20 }", 20},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestReturnStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"return 10;", 10},
+		{"return 10; 9;", 10},
+		{"return 2 * 5; 9;", 10},
+		{"9; return 2 * 5; 9;", 10},
+		{"if (10 > 1) { return 10; }", 10},
+		{
+			`
+if (10 > 1) {
+  if (10 > 1) {
+    return 10;
+  }
+
+  return 1;
+}
+`,
+			10,
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestErrorHandling(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedKind    object.ErrorKind
+		expectedMessage string
+	}{
+		{"5 + true;", object.TYPE_MISMATCH, "type mismatch: INTEGER + BOOLEAN"},
+		{"5 + true; 5;", object.TYPE_MISMATCH, "type mismatch: INTEGER + BOOLEAN"},
+		{"-true", object.UNKNOWN_OPERATOR, "unknown operator: -BOOLEAN"},
+		{"true + false;", object.UNKNOWN_OPERATOR, "unknown operator: BOOLEAN + BOOLEAN"},
+		{"5; true + false; 5", object.UNKNOWN_OPERATOR, "unknown operator: BOOLEAN + BOOLEAN"},
+		{"if (10 > 1) { true + false; }", object.UNKNOWN_OPERATOR, "unknown operator: BOOLEAN + BOOLEAN"},
+		{
+			`
+if (10 > 1) {
+  if (10 > 1) {
+    return true + false;
+  }
+
+  return 1;
+}
+`,
+			object.UNKNOWN_OPERATOR,
+			"unknown operator: BOOLEAN + BOOLEAN",
+		},
+		{"foobar", object.UNBOUND_IDENTIFIER, "identifier not found: foobar"},
+		{"5 / 0", object.DIVISION_BY_ZERO, "division by zero: 5 / 0"},
+		{"let f = fn(x, y) { x + y }; f(1);", object.ARITY_MISMATCH, "wrong number of arguments: want=2, got=1"},
+		{"let x = 5; x(1);", object.TYPE_MISMATCH, "not a function: INTEGER"},
+		// 引数の評価中のエラーで呼び出しが打ち切られる
+		{"let f = fn(x, y) { x }; f(1, -true);", object.UNKNOWN_OPERATOR, "unknown operator: -BOOLEAN"},
+		{"let x = foo; 5", object.UNBOUND_IDENTIFIER, "identifier not found: foo"},
+		{`"Hello" - "World"`, object.UNKNOWN_OPERATOR, "unknown operator: STRING - STRING"},
+		{`"Hello" + 1`, object.TYPE_MISMATCH, "type mismatch: STRING + INTEGER"},
+		{`{"name": "Monkey"}[fn(x) { x }];`, object.UNHASHABLE_KEY, "unusable as hash key: FUNCTION"},
+		{`{fn(x) { x }: 1}`, object.UNHASHABLE_KEY, "unusable as hash key: FUNCTION"},
+		{`{[1]: 1}`, object.UNHASHABLE_KEY, "unusable as hash key: ARRAY"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Kind != tt.expectedKind {
+			t.Errorf("wrong error kind. expected=%q, got=%q", tt.expectedKind, errObj.Kind)
+		}
+
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q",
+				tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestErrorPosition(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedLine   int
+		expectedColumn int
+	}{
+		{"5 + true", 1, 1},
+		{"let a = 1;\n  -true", 2, 3},
+		{"let a = 1;\nlet b = a + c;", 2, 13},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Pos.Line != tt.expectedLine || errObj.Pos.Column != tt.expectedColumn {
+			t.Errorf("wrong error position for %q. expected=%d:%d, got=%s",
+				tt.input, tt.expectedLine, tt.expectedColumn, errObj.Pos)
+		}
+	}
+}
+
+func TestErrorStackTrace(t *testing.T) {
+	input := `
+let inner = fn(x) { x + true };
+let outer = fn(x) { inner(x) };
+fn() { outer(1) }();`
+
+	evaluated := testEval(input)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+
+	expected := []string{"inner", "outer", "<anonymous>"}
+	if len(errObj.Stack) != len(expected) {
+		t.Fatalf("wrong stack length. expected=%v, got=%v", expected, errObj.Stack)
+	}
+	for i, name := range expected {
+		if errObj.Stack[i] != name {
+			t.Errorf("wrong stack frame %d. expected=%q, got=%q", i, name, errObj.Stack[i])
+		}
+	}
+}
+
+func TestMaxCallDepth(t *testing.T) {
+	// Go のスタックを使い切る前に Monkey のエラーになる
+	evaluated := testEval("let r = fn(n) { r(n + 1) };\nr(0)")
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	if errObj.Kind != object.STACK_OVERFLOW || errObj.Message != "maximum call depth exceeded" {
+		t.Errorf("wrong error. got=%s %q", errObj.Kind, errObj.Message)
+	}
+	if len(errObj.Stack) != MaxCallDepth {
+		t.Errorf("wrong stack length. want=%d, got=%d", MaxCallDepth, len(errObj.Stack))
+	}
+
+	// エラーで抜けた後は呼び出しの深さが元に戻っている
+	testIntegerObject(t, testEval("let r = fn(n) { if (n == 0) { 0 } else { 1 + r(n - 1) } }; r(100)"), 100)
+}
+
+func TestStringLiteral(t *testing.T) {
+	input := `"Hello World!"`
+
+	evaluated := testEval(input)
+	testStringObject(t, evaluated, "Hello World!")
+}
+
+func TestStringConcatenation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"Hello" + " " + "World!"`, "Hello World!"},
+		{`"a\tb" + "\n"`, "a\tb\n"},
+		{`let greet = fn(name) { "Hello, " + name }; greet("Monkey")`, "Hello, Monkey"},
+		{`let 挨拶 = fn(名前) { "こんにちは、" + 名前 }; 挨拶("猿")`, "こんにちは、猿"},
+	}
+
+	for _, tt := range tests {
+		testStringObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestStringComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`"a" == "a"`, true},
+		{`"a" == "b"`, false},
+		{`"a" != "b"`, true},
+		{`"a" != "a"`, false},
+		{`"a" < "b"`, true},
+		{`"b" < "a"`, false},
+		{`"abc" > "abd"`, false},
+		{`"ab" < "abc"`, true},
+		{`"" < "a"`, true},
+		{`"a" + "b" == "ab"`, true},
+	}
+
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestArrayLiterals(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if len(result.Elements) != 3 {
+		t.Fatalf("array has wrong num of elements. got=%d",
+			len(result.Elements))
+	}
+
+	testIntegerObject(t, result.Elements[0], 1)
+	testIntegerObject(t, result.Elements[1], 4)
+	testIntegerObject(t, result.Elements[2], 6)
+}
+
+func TestArrayIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3][0]", 1},
+		{"[1, 2, 3][1]", 2},
+		{"[1, 2, 3][2]", 3},
+		{"let i = 0; [1][i];", 1},
+		{"[1, 2, 3][1 + 1];", 3},
+		{"let myArray = [1, 2, 3]; myArray[2];", 3},
+		{"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];", 6},
+		{"let myArray = [1, 2, 3]; let i = myArray[0]; myArray[i]", 2},
+		{"[1, 2, 3][-1]", 3},
+		{"[1, 2, 3][-3]", 1},
+		{"[[1, 2], [3, 4]][1][0]", 3},
+		{"[1, 2, 3][3]", "index out of range: index 3, length 3"},
+		{"[1, 2, 3][-4]", "index out of range: index -4, length 3"},
+		{"[][0]", "index out of range: index 0, length 0"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testErrorObject(t, evaluated, object.INDEX_OUT_OF_RANGE, expected)
+		}
+	}
+}
+
+func TestArraySliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3, 4][1:3]", "[2, 3]"},
+		{"[1, 2, 3, 4][1:]", "[2, 3, 4]"},
+		{"[1, 2, 3, 4][:2]", "[1, 2]"},
+		{"[1, 2, 3, 4][:]", "[1, 2, 3, 4]"},
+		{"[1, 2, 3, 4][-2:]", "[3, 4]"},
+		{"[1, 2, 3, 4][:-1]", "[1, 2, 3]"},
+		{"[1, 2, 3, 4][2:2]", "[]"},
+		{"[1, 2, 3, 4][4:]", "[]"},
+		{"[1, 2, 3][1:5]", object.INDEX_OUT_OF_RANGE},
+		{"[1, 2, 3][2:1]", object.INDEX_OUT_OF_RANGE},
+		{"[1, 2, 3][-5:]", object.INDEX_OUT_OF_RANGE},
+		{"[1, 2, 3][true:]", object.TYPE_MISMATCH},
+		{"5[1:]", object.UNKNOWN_OPERATOR},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case string:
+			array, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("object is not Array for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if array.Inspect() != expected {
+				t.Errorf("wrong slice for %q. expected=%s, got=%s", tt.input, expected, array.Inspect())
+			}
+		case object.ErrorKind:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("no error object returned for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Kind != expected {
+				t.Errorf("wrong error kind for %q. expected=%q, got=%q", tt.input, expected, errObj.Kind)
+			}
+		}
+	}
+}
+
+func TestSliceReturnsNewArray(t *testing.T) {
+	input := "let a = [1, 2, 3]; let b = a[:]; [a, b]"
+
+	evaluated := testEval(input)
+	pair, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	a := pair.Elements[0].(*object.Array)
+	b := pair.Elements[1].(*object.Array)
+	if a == b {
+		t.Fatalf("slice returned the same array")
+	}
+	b.Elements[0] = &object.Integer{Value: 100}
+	testIntegerObject(t, a.Elements[0], 1)
+}
+
+func TestHashLiterals(t *testing.T) {
+	input := `let two = "two";
+	{
+		"one": 10 - 9,
+		two: 1 + 1,
+		"thr" + "ee": 6 / 2,
+		4: 4,
+		true: 5,
+		false: 6
+	}`
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Hash)
+	if !ok {
+		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := map[object.HashKey]int64{
+		(&object.String{Value: "one"}).HashKey():   1,
+		(&object.String{Value: "two"}).HashKey():   2,
+		(&object.String{Value: "three"}).HashKey(): 3,
+		(&object.Integer{Value: 4}).HashKey():      4,
+		object.TRUE.HashKey():                      5,
+		object.FALSE.HashKey():                     6,
+	}
+
+	if len(result.Pairs) != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
+	}
+
+	for expectedKey, expectedValue := range expected {
+		pair, ok := result.Pairs[expectedKey]
+		if !ok {
+			t.Errorf("no pair for given key in Pairs")
+		}
+
+		testIntegerObject(t, pair.Value, expectedValue)
+	}
+}
+
+func TestHashInsertionOrder(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"b": 1, "a": 2, 3: 3, true: 4}`, "{b: 1, a: 2, 3: 3, true: 4}"},
+		// 同じキーを再び書いた場合は値だけが更新され，位置は最初のまま
+		{`{"x": 1, "y": 2, "x": 3}`, "{x: 3, y: 2}"},
+		{`{}`, "{}"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong order for %s. expected=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestHashIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`{"foo": 5}["foo"]`, 5},
+		{`{"foo": 5}["bar"]`, nil},
+		{`let key = "foo"; {"foo": 5}[key]`, 5},
+		{`{}["foo"]`, nil},
+		{`{5: 5}[5]`, 5},
+		{`{true: 5}[true]`, 5},
+		{`{false: 5}[false]`, 5},
+		{`{1: 5}[true]`, nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len("")`, 0},
+		{`len("four")`, 4},
+		{`len("hello world")`, 11},
+		{`len("héllo")`, 5},
+		{`len("日本語")`, 3},
+		{`len([1, 2, 3])`, 3},
+		{`len([])`, 0},
+		{`len({"a": 1, "b": 2})`, 2},
+		{`len(1)`, "argument to `len` not supported, got INTEGER"},
+		{`len("one", "two")`, "wrong number of arguments to `len`: want=1, got=2"},
+		{`first([1, 2, 3])`, 1},
+		{`first([])`, nil},
+		{`first(1)`, "argument to `first` must be ARRAY, got INTEGER"},
+		{`last([1, 2, 3])`, 3},
+		{`last([])`, nil},
+		{`last(1)`, "argument to `last` must be ARRAY, got INTEGER"},
+		{`rest([1, 2, 3])`, "[2, 3]"},
+		{`rest([1])`, "[]"},
+		{`rest([])`, nil},
+		{`push([], 1)`, "[1]"},
+		{`push([1, 2], [3])`, "[1, 2, [3]]"},
+		{`push(1, 1)`, "argument to `push` must be ARRAY, got INTEGER"},
+		{`push([1])`, "wrong number of arguments to `push`: want=2, got=1"},
+		{`type(1)`, "INTEGER"},
+		{`type("a")`, "STRING"},
+		{`type(true)`, "BOOLEAN"},
+		{`type([])`, "ARRAY"},
+		{`type({})`, "HASH"},
+		{`type(fn() {})`, "FUNCTION"},
+		{`type(len)`, "BUILTIN"},
+		{`type(puts(""))`, "NULL"},
+		{`str(10)`, "10"},
+		{`str(true)`, "true"},
+		{`str("s")`, "s"},
+		{`str([1, "a"])`, "[1, a]"},
+		{`"n=" + str(1 + 2)`, "n=3"},
+		{`int("42")`, 42},
+		{`int(" -7 ")`, -7},
+		{`int(true)`, 1},
+		{`int(false)`, 0},
+		{`int(5)`, 5},
+		{`int("abc")`, `cannot convert "abc" to INTEGER`},
+		{`int([])`, "argument to `int` not supported, got ARRAY"},
+		{`int(3.9)`, 3},
+		{`int(-3.9)`, -3},
+		{`int(0.0 / 0)`, "cannot convert NaN to INTEGER"},
+		{`str(int(1e19))`, "10000000000000000000"},
+		{`int(1.0 / 0)`, "cannot convert +Inf to INTEGER"},
+		{`type(int("123456789012345678901234567890"))`, "BIGINT"},
+		{`str(float(int("100000000000000000000")))`, "1e+20"},
+		{`type(1.5)`, "FLOAT"},
+		{`str(2.50)`, "2.5"},
+		{`str(float(3))`, "3.0"},
+		{`str(float(" 1e3 "))`, "1000.0"},
+		{`float("x")`, `cannot convert "x" to FLOAT`},
+		{`float([])`, "argument to `float` not supported, got ARRAY"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				if errObj.Message != expected {
+					t.Errorf("wrong error message for %s. expected=%q, got=%q",
+						tt.input, expected, errObj.Message)
+				}
+				continue
+			}
+			if evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %s. expected=%q, got=%q (%T)",
+					tt.input, expected, evaluated.Inspect(), evaluated)
+			}
+		}
+	}
+}
+
+func TestBuiltinErrorHasCallPosition(t *testing.T) {
+	evaluated := testEval("let x = 1;\nlen(x)")
+
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	if errObj.Kind != object.TYPE_MISMATCH {
+		t.Errorf("wrong error kind. got=%q", errObj.Kind)
+	}
+	if errObj.Pos.Line != 2 || errObj.Pos.Column != 1 {
+		t.Errorf("wrong error position. got=%s", errObj.Pos)
+	}
+	if len(errObj.Stack) != 1 || errObj.Stack[0] != "len" {
+		t.Errorf("wrong stack. got=%v", errObj.Stack)
+	}
+}
+
+func TestBuiltinsCanBeShadowed(t *testing.T) {
+	testIntegerObject(t, testEval("let len = fn(x) { 42 }; len([1])"), 42)
+}
+
+func TestRegisterBuiltin(t *testing.T) {
+	RegisterBuiltin("double", func(args ...object.Object) object.Object {
+		return &object.Integer{Value: args[0].(*object.Integer).Value * 2}
+	})
+	defer delete(builtins, "double")
+
+	testIntegerObject(t, testEval("double(21)"), 42)
+}
+
+func TestPuts(t *testing.T) {
+	var buf bytes.Buffer
+	prev := output
+	SetOutput(&buf)
+	defer SetOutput(prev)
+
+	testNullObject(t, testEval(`puts("hello", 1, [true])`))
+
+	if buf.String() != "hello\n1\n[true]\n" {
+		t.Errorf("wrong output. got=%q", buf.String())
+	}
+}
+
+func TestLetStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let a = 5; a;", 5},
+		{"let a = 5 * 5; a;", 25},
+		{"let a = 5; let b = a; b;", 5},
+		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestFunctionObject(t *testing.T) {
+	input := "fn(x) { x + 2; };"
+
+	evaluated := testEval(input)
+	fn, ok := evaluated.(*object.Function)
+	if !ok {
+		t.Fatalf("object is not Function. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if len(fn.Parameters) != 1 {
+		t.Fatalf("function has wrong parameters. Parameters=%+v",
+			fn.Parameters)
+	}
+
+	if fn.Parameters[0].String() != "x" {
+		t.Fatalf("parameter is not 'x'. got=%q", fn.Parameters[0])
+	}
+
+	expectedBody := "(x + 2)"
+
+	if fn.Body.String() != expectedBody {
+		t.Fatalf("body is not %q. got=%q", expectedBody, fn.Body.String())
+	}
+}
+
+func TestFunctionApplication(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let identity = fn(x) { x; }; identity(5);", 5},
+		{"let identity = fn(x) { return x; }; identity(5);", 5},
+		{"let double = fn(x) { x * 2; }; double(5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5, 5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
+		{"fn(x) { x; }(5)", 5},
+		{"let f = fn() { return 1; 2; }; f() + 10;", 11},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestClosures(t *testing.T) {
+	input := `
+let newAdder = fn(x) {
+  fn(y) { x + y };
+};
+
+let addTwo = newAdder(2);
+addTwo(2);`
+
+	testIntegerObject(t, testEval(input), 4)
+}
+
+func TestHigherOrderFunctions(t *testing.T) {
+	input := `
+let add = fn(a, b) { a + b };
+let applyFunc = fn(a, b, func) { func(a, b) };
+applyFunc(2, 2, add);`
+
+	testIntegerObject(t, testEval(input), 4)
+}
+
+func TestRecursiveFunction(t *testing.T) {
+	input := `
+let fib = fn(n) {
+  if (n < 2) { return n; }
+  fib(n - 1) + fib(n - 2);
+};
+fib(15);`
+
+	testIntegerObject(t, testEval(input), 610)
+}
+
+func TestLexicalScoping(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		// 引数の束縛は外側の同名の変数を上書きしない
+		{"let x = 1; let f = fn(x) { x * 10 }; f(5); x;", 1},
+		// 関数内の let は呼び出し元の環境に漏れない
+		{"let x = 1; let f = fn() { let x = 2; x }; f() + x;", 3},
+		// 定義時の環境を参照する (呼び出し時の環境ではない)
+		{"let x = 1; let f = fn() { x }; let g = fn(x) { f() }; g(100);", 1},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let x = 1; x = 2; x;", 2},
+		{"let x = 1; x = x + 1;", 2},
+		{"let a = 1; let b = 2; a = b = 3; a + b;", 6},
+		{"let x = 10; x += 5; x;", 15},
+		{"let x = 10; x -= 5; x;", 5},
+		{"let x = 10; x *= 5; x;", 50},
+		{"let x = 10; x /= 5; x;", 2},
+		{"let x = 10; x %= 4; x;", 2},
+		{"let x = 2; x **= 10; x;", 1024},
+		{"let x = 12; x &= 10; x;", 8},
+		{"let x = 12; x |= 3; x;", 15},
+		{"let x = 12; x ^= 4; x;", 8},
+		{"let x = 1; x <<= 4; x;", 16},
+		{"let x = 16; x >>= 2; x;", 4},
+		{`let s = "foo"; s += "bar"; s;`, "foobar"},
+		{"let x = 1.5; x *= 2; x;", 3.0},
+		// 関数の中からでも外側の束縛を書き換えられる
+		{"let n = 0; let inc = fn() { n += 1 }; inc(); inc(); n;", 2},
+		{"let counter = fn() { let c = 0; fn() { c = c + 1; c } }; let next = counter(); next(); next(); next();", 3},
+		// 最も内側の束縛を書き換える (外側の同名の変数はそのまま)
+		{"let x = 1; let f = fn(x) { x = 5; x }; f(0) + x;", 6},
+		{"let arr = [1, 2, 3]; arr[0] = 10; arr[0] + arr[2];", 13},
+		{"let arr = [1, 2, 3]; arr[-1] = 10; arr[2];", 10},
+		{"let arr = [1, 2, 3]; arr[1] += 5; arr[1];", 7},
+		{"let arr = [1, 2, 3]; let f = fn(a) { a[0] = 100 }; f(arr); arr[0];", 100},
+		{`let h = {"a": 1}; h["a"] = 2; h["a"];`, 2},
+		{`let h = {}; h["b"] = 3; h["b"];`, 3},
+		{`let h = {"a": 1}; h["a"] *= 10; h["a"];`, 10},
+		{"let m = [[1, 2], [3, 4]]; m[1][0] = 30; m[1][0];", 30},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case float64:
+			testFloatObject(t, evaluated, expected)
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok {
+				t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if str.Value != expected {
+				t.Errorf("String has wrong value. got=%q, want=%q", str.Value, expected)
+			}
+		}
+	}
+}
+
+func TestAssignErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedKind    object.ErrorKind
+		expectedMessage string
+	}{
+		{"x = 1", object.UNBOUND_IDENTIFIER, "cannot assign to undeclared identifier: x"},
+		{"x += 1", object.UNBOUND_IDENTIFIER, "identifier not found: x"},
+		{"let f = fn() { y = 1 }; f();", object.UNBOUND_IDENTIFIER, "cannot assign to undeclared identifier: y"},
+		{"let x = 1; x += true;", object.TYPE_MISMATCH, "type mismatch: INTEGER + BOOLEAN"},
+		{"let x = 1; x /= 0;", object.DIVISION_BY_ZERO, "division by zero: 1 / 0"},
+		{"let arr = [1]; arr[1] = 2;", object.INDEX_OUT_OF_RANGE, "index out of range: index 1, length 1"},
+		{`let arr = [1]; arr["a"] = 2;`, object.TYPE_MISMATCH, "array index must be INTEGER, got STRING"},
+		{"let h = {}; h[[1]] = 2;", object.UNHASHABLE_KEY, "unusable as hash key: ARRAY"},
+		{`let s = "abc"; s[0] = "x";`, object.UNKNOWN_OPERATOR, "index assignment not supported: STRING"},
+		{"let x = 1; x = -true;", object.UNKNOWN_OPERATOR, "unknown operator: -BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Kind != tt.expectedKind {
+			t.Errorf("wrong error kind for %q. expected=%q, got=%q", tt.input, tt.expectedKind, errObj.Kind)
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message for %q. expected=%q, got=%q", tt.input, tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestWhileStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let i = 0; while (i < 10) { i += 1; } i;", 10},
+		{"let i = 0; while (false) { i += 1; } i;", 0},
+		{"let sum = 0; let i = 0; while (true) { i += 1; if (i > 5) { break; } sum += i; } sum;", 15},
+		// 偶数だけを足す
+		{"let sum = 0; let i = 0; while (i < 10) { i += 1; if (i % 2 == 1) { continue; } sum += i; } sum;", 30},
+		// 関数の中のループから return する
+		{"let f = fn() { let i = 0; while (true) { i += 1; if (i == 3) { return i * 100; } } }; f();", 300},
+		// 式の途中の break と continue は値にならずにループへ伝わる
+		{"let i = 0; while (true) { i += 1; let x = if (i > 3) { break; } else { i }; } i;", 4},
+		{"let s = 0; let i = 0; while (i < 5) { i += 1; s += [1, if (i == 2) { continue; } else { i }][1]; } s;", 13},
+		// ループは値を持たない
+		{"while (false) { 1 }", nil},
+		// 再帰では Go のスタックが溢れる回数でも繰り返せる
+		{"let i = 0; while (i < 1000000) { i += 1; } i;", 1000000},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if expected, ok := tt.expected.(int); ok {
+			testIntegerObject(t, evaluated, int64(expected))
+		} else if evaluated != nil {
+			t.Errorf("loop has a value. got=%T (%+v)", evaluated, evaluated)
+		}
+	}
+}
+
+func TestForStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let sum = 0; for (x in [1, 2, 3]) { sum += x; } sum;", 6},
+		{"let sum = 0; for (x in []) { sum += x; } sum;", 0},
+		{`let keys = ""; for (k in {"a": 1, "b": 2, "c": 3}) { keys += k; } keys;`, "abc"},
+		{`let h = {"a": 1, "b": 2}; let sum = 0; for (k in h) { sum += h[k]; } sum;`, 3},
+		{`let out = ""; for (c in "héllo") { out = c + out; } out;`, "olléh"},
+		{"let sum = 0; for (i in range(5)) { sum += i; } sum;", 10},
+		{"let sum = 0; for (i in range(1, 11)) { sum += i; } sum;", 55},
+		{"let sum = 0; for (i in range(0, 10, 3)) { sum += i; } sum;", 18},
+		{"let sum = 0; for (i in range(10, 0, -2)) { sum += i; } sum;", 30},
+		{"let sum = 0; for (i in range(5, 0)) { sum += i; } sum;", 0},
+		{"let sum = 0; for (i in range(1000000)) { sum += 1; } sum;", 1000000},
+		{"let sum = 0; for (i in range(10)) { if (i == 4) { break; } sum += i; } sum;", 6},
+		{"let sum = 0; for (i in range(10)) { if (i % 2 == 0) { continue; } sum += i; } sum;", 25},
+		// break と continue は最も内側のループだけに効く
+		{"let n = 0; for (i in range(3)) { for (j in range(3)) { if (j == 1) { break; } n += 1; } } n;", 3},
+		{"let n = 0; for (i in range(3)) { while (true) { break; } n += 1; } n;", 3},
+		// ループ変数は繰り返しのたびに新しく束縛される
+		{"let fs = []; for (i in range(3)) { fs = push(fs, fn() { i }); } fs[0]() + fs[2]();", 2},
+		// ループ変数と本体の let は外側の環境に漏れない
+		{"let i = 100; for (i in range(3)) { let j = i; } i;", 100},
+		{"let f = fn(xs) { for (x in xs) { if (x > 2) { return x; } } -1 }; f([1, 5, 3]);", 5},
+		{"let f = fn(xs) { for (x in xs) { if (x > 2) { return x; } } -1 }; f([1, 2]);", -1},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok {
+				t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if str.Value != expected {
+				t.Errorf("String has wrong value. got=%q, want=%q", str.Value, expected)
+			}
+		}
+	}
+}
+
+func TestLoopErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedKind    object.ErrorKind
+		expectedMessage string
+	}{
+		{"for (x in 5) { }", object.TYPE_MISMATCH, "cannot iterate over INTEGER"},
+		{"for (x in foo) { }", object.UNBOUND_IDENTIFIER, "identifier not found: foo"},
+		{"while (foo) { }", object.UNBOUND_IDENTIFIER, "identifier not found: foo"},
+		{"let i = 0; while (true) { i += 1; if (i == 3) { i + true; } }", object.TYPE_MISMATCH, "type mismatch: INTEGER + BOOLEAN"},
+		{"for (x in [1, 2]) { x / 0 }", object.DIVISION_BY_ZERO, "division by zero: 1 / 0"},
+		{"range(0, 10, 0)", object.OUT_OF_RANGE, "`range` step must not be zero"},
+		{"range(-9223372036854775807 - 1, 9223372036854775807)", object.OUT_OF_RANGE,
+			"`range` has too many elements: range(-9223372036854775808, 9223372036854775807, 1)"},
+		{"range(9223372036854775807, -9223372036854775807 - 1, -1)", object.OUT_OF_RANGE,
+			"`range` has too many elements: range(9223372036854775807, -9223372036854775808, -1)"},
+		{"range()", object.ARITY_MISMATCH, "wrong number of arguments to `range`: want=1 to 3, got=0"},
+		{`range("10")`, object.TYPE_MISMATCH, "arguments to `range` must be INTEGER, got STRING"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Kind != tt.expectedKind {
+			t.Errorf("wrong error kind for %q. expected=%q, got=%q", tt.input, tt.expectedKind, errObj.Kind)
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message for %q. expected=%q, got=%q", tt.input, tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
 func testEval(input string) object.Object {
 	l := lexer.New(input)
 	p := parser.New(l)
 	program := p.ParseProgram()
+	env := object.NewEnvironment()
 
-	return Eval(program)
+	return Eval(program, env)
 }
 
 func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
@@ -43,3 +1262,74 @@ func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 
 	return true
 }
+
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("object is not Float. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%g, want=%g",
+			result.Value, expected)
+		return false
+	}
+
+	return true
+}
+
+func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
+	result, ok := obj.(*object.Boolean)
+	if !ok {
+		t.Errorf("object is not Boolean. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%t, want=%t",
+			result.Value, expected)
+		return false
+	}
+
+	return true
+}
+
+func testStringObject(t *testing.T, obj object.Object, expected string) bool {
+	result, ok := obj.(*object.String)
+	if !ok {
+		t.Errorf("object is not String. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%q, want=%q",
+			result.Value, expected)
+		return false
+	}
+
+	return true
+}
+
+func testErrorObject(t *testing.T, obj object.Object, kind object.ErrorKind, message string) bool {
+	errObj, ok := obj.(*object.Error)
+	if !ok {
+		t.Errorf("object is not Error. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if errObj.Kind != kind {
+		t.Errorf("wrong error kind. expected=%q, got=%q", kind, errObj.Kind)
+		return false
+	}
+	if errObj.Message != message {
+		t.Errorf("wrong error message. expected=%q, got=%q", message, errObj.Message)
+		return false
+	}
+
+	return true
+}
+
+func testNullObject(t *testing.T, obj object.Object) bool {
+	if obj != object.NULL {
+		t.Errorf("object is not NULL. got=%T (%+v)", obj, obj)
+		return false
+	}
+	return true
+}
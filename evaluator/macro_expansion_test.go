@@ -0,0 +1,206 @@
+package evaluator
+
+import (
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"strings"
+	"testing"
+)
+
+func TestDefineMacros(t *testing.T) {
+	input := `
+let number = 1;
+let function = fn(x, y) { x + y };
+let mymacro = macro(x, y) { x + y; };
+`
+
+	env := object.NewEnvironment()
+	program := testParseProgram(input)
+
+	DefineMacros(program, env)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("Wrong number of statements. got=%d", len(program.Statements))
+	}
+
+	_, ok := env.Get("number")
+	if ok {
+		t.Fatalf("number should not be defined")
+	}
+	_, ok = env.Get("function")
+	if ok {
+		t.Fatalf("function should not be defined")
+	}
+
+	obj, ok := env.Get("mymacro")
+	if !ok {
+		t.Fatalf("macro not in environment.")
+	}
+
+	macro, ok := obj.(*object.Macro)
+	if !ok {
+		t.Fatalf("object is not Macro. got=%T (%+v)", obj, obj)
+	}
+
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("Wrong number of macro parameters. got=%d", len(macro.Parameters))
+	}
+
+	if macro.Parameters[0].String() != "x" {
+		t.Fatalf("parameter is not 'x'. got=%q", macro.Parameters[0])
+	}
+	if macro.Parameters[1].String() != "y" {
+		t.Fatalf("parameter is not 'y'. got=%q", macro.Parameters[1])
+	}
+
+	expectedBody := "(x + y)"
+
+	if macro.Body.String() != expectedBody {
+		t.Fatalf("body is not %q. got=%q", expectedBody, macro.Body.String())
+	}
+}
+
+func TestExpandMacros(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`
+let infixExpression = macro() { quote(1 + 2); };
+
+infixExpression();
+`,
+			`(1 + 2)`,
+		},
+		{
+			`
+let reverse = macro(a, b) { quote(unquote(b) - unquote(a)); };
+
+reverse(2 + 2, 10 - 5);
+`,
+			`(10 - 5) - (2 + 2)`,
+		},
+		{
+			`
+let unless = macro(condition, consequence, alternative) {
+    quote(if (!(unquote(condition))) {
+        unquote(consequence);
+    } else {
+        unquote(alternative);
+    });
+};
+
+unless(10 > 5, puts("not greater"), puts("greater"));
+`,
+			`if (!(10 > 5)) { puts("not greater") } else { puts("greater") }`,
+		},
+		{
+			// 同じマクロを何度呼び出しても，それぞれの引数で展開する
+			`
+let twice = macro(x) { quote(unquote(x) + unquote(x)); };
+
+twice(1);
+twice(a * b);
+`,
+			`(1 + 1); ((a * b) + (a * b))`,
+		},
+		{
+			// 関数の本体の中の呼び出しも展開する
+			`
+let one = macro() { quote(1); };
+
+let f = fn() { one() };
+`,
+			`let f = fn() { 1 };`,
+		},
+	}
+
+	for _, tt := range tests {
+		expected := testParseProgram(tt.expected)
+		program := testParseProgram(tt.input)
+
+		env := object.NewEnvironment()
+		DefineMacros(program, env)
+		expanded, err := ExpandMacros(program, env)
+		if err != nil {
+			t.Errorf("unexpected error for %q: %s", tt.input, err.Message)
+			continue
+		}
+
+		if expanded.String() != expected.String() {
+			t.Errorf("not equal. want=%q, got=%q",
+				expected.String(), expanded.String())
+		}
+	}
+}
+
+func TestExpandMacrosErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedKind    object.ErrorKind
+		expectedMessage string
+		expectedPos     string
+		expectedStack   []string
+	}{
+		{
+			"let m = macro(a) { quote(1) };\nm(1, 2);",
+			object.ARITY_MISMATCH,
+			"wrong number of arguments to macro: want=1, got=2",
+			"2:1",
+			nil,
+		},
+		{
+			"let m = macro() { 1 };\nm();",
+			object.TYPE_MISMATCH,
+			"macro must return QUOTE, got INTEGER",
+			"2:1",
+			nil,
+		},
+		{
+			"let m = macro() { 1 + true };\nm();",
+			object.TYPE_MISMATCH,
+			"type mismatch: INTEGER + BOOLEAN",
+			"1:19",
+			[]string{"m"},
+		},
+		{
+			"let m = macro() { let f = fn() { 1 + true }; f() };\nm();",
+			object.TYPE_MISMATCH,
+			"type mismatch: INTEGER + BOOLEAN",
+			"1:34",
+			[]string{"f", "m"},
+		},
+	}
+
+	for _, tt := range tests {
+		program := testParseProgram(tt.input)
+		env := object.NewEnvironment()
+		DefineMacros(program, env)
+
+		_, err := ExpandMacros(program, env)
+		if err == nil {
+			t.Errorf("no error returned for %q", tt.input)
+			continue
+		}
+
+		if err.Kind != tt.expectedKind || err.Message != tt.expectedMessage {
+			t.Errorf("wrong error for %q. want=%s %q, got=%s %q",
+				tt.input, tt.expectedKind, tt.expectedMessage, err.Kind, err.Message)
+		}
+		if err.Pos.String() != tt.expectedPos {
+			t.Errorf("wrong position for %q. want=%s, got=%s", tt.input, tt.expectedPos, err.Pos)
+		}
+		if strings.Join(err.Stack, ",") != strings.Join(tt.expectedStack, ",") {
+			t.Errorf("wrong stack for %q. want=%v, got=%v", tt.input, tt.expectedStack, err.Stack)
+		}
+	}
+}
+
+func testParseProgram(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}
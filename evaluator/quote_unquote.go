@@ -0,0 +1,138 @@
+package evaluator
+
+import (
+	"math"
+	"math/big"
+	"monkey/ast"
+	"monkey/object"
+	"monkey/token"
+)
+
+// quote(expr) の評価
+// 引数を評価せずに構文木のまま Quote に包む．ただし中の unquote(expr) は評価して，その値を表す構文木に置き換える
+func quote(node *ast.CallExpression, env *object.Environment) object.Object {
+	if len(node.Arguments) != 1 {
+		return newError(node, object.ARITY_MISMATCH,
+			"wrong number of arguments to `quote`: want=1, got=%d", len(node.Arguments))
+	}
+
+	quoted, err := evalUnquoteCalls(node.Arguments[0], env)
+	if err != nil {
+		return err
+	}
+	return &object.Quote{Node: quoted}
+}
+
+// quote の引数の中の unquote(expr) を評価し，その値を構文木に戻して置き換える
+// マクロの本体は呼び出すたびに評価するので，元の構文木は書き換えずに複製を書き換える
+func evalUnquoteCalls(quoted ast.Node, env *object.Environment) (ast.Node, *object.Error) {
+	var err *object.Error
+
+	modified := ast.Modify(ast.Copy(quoted), func(node ast.Node) ast.Node {
+		call, ok := node.(*ast.CallExpression)
+		if !ok || err != nil || !isCallTo(call, "unquote") {
+			return node
+		}
+
+		if len(call.Arguments) != 1 {
+			err = newError(call, object.ARITY_MISMATCH,
+				"wrong number of arguments to `unquote`: want=1, got=%d", len(call.Arguments))
+			return node
+		}
+
+		unquoted := Eval(call.Arguments[0], env)
+		if e, ok := unquoted.(*object.Error); ok {
+			err = e
+			return node
+		}
+
+		converted, convErr := convertObjectToASTNode(unquoted, call)
+		if convErr != nil {
+			err = convErr
+			return node
+		}
+		return converted
+	})
+
+	return modified, err
+}
+
+// 関数名が name の呼び出し式かどうか
+func isCallTo(call *ast.CallExpression, name string) bool {
+	ident, ok := call.Function.(*ast.Identifier)
+	return ok && ident.Value == name
+}
+
+// 値をその値を表すリテラルの構文木に変換する
+// 位置は置き換える unquote の呼び出しのものにする．配列とハッシュは要素も再帰的に変換する
+// 構文木で表せない値 (関数や NaN など) ならエラーを返す
+func convertObjectToASTNode(obj object.Object, at *ast.CallExpression) (ast.Node, *object.Error) {
+	tok := token.Token{Literal: obj.Inspect(), Pos: at.Pos(), End: at.End()}
+
+	switch obj := obj.(type) {
+	case *object.Integer:
+		tok.Type = token.INT
+		return &ast.IntegerLiteral{Token: tok, Value: obj.Value}, nil
+	case *object.BigInt:
+		tok.Type = token.INT
+		return &ast.BigIntLiteral{Token: tok, Value: new(big.Int).Set(obj.Value)}, nil
+	case *object.Float:
+		// NaN や無限大を表すリテラルはない
+		if math.IsNaN(obj.Value) || math.IsInf(obj.Value, 0) {
+			return nil, newError(at, object.TYPE_MISMATCH,
+				"cannot unquote non-finite %s %s", obj.Type(), obj.Inspect())
+		}
+		tok.Type = token.FLOAT
+		return &ast.FloatLiteral{Token: tok, Value: obj.Value}, nil
+	case *object.String:
+		tok.Type = token.STRING
+		tok.Literal = obj.Value
+		return &ast.StringLiteral{Token: tok, Value: obj.Value}, nil
+	case *object.Boolean:
+		if obj.Value {
+			tok.Type = token.TRUE
+		} else {
+			tok.Type = token.FALSE
+		}
+		return &ast.Boolean{Token: tok, Value: obj.Value}, nil
+	case *object.Null:
+		// null を表すリテラルはないので，null と評価される if (false) {} で表す
+		tok.Type = token.IF
+		tok.Literal = "if"
+		condition := &ast.Boolean{Token: token.Token{Type: token.FALSE, Literal: "false", Pos: tok.Pos, End: tok.End}}
+		consequence := &ast.BlockStatement{Token: token.Token{Type: token.LBRACE, Literal: "{", Pos: tok.Pos, End: tok.End}}
+		return &ast.IfExpression{Token: tok, Condition: condition, Consequence: consequence}, nil
+	case *object.Array:
+		tok.Type = token.LBRACKET
+		tok.Literal = "["
+		elements := make([]ast.Expression, len(obj.Elements))
+		for i, el := range obj.Elements {
+			node, err := convertObjectToASTNode(el, at)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = node.(ast.Expression)
+		}
+		return &ast.ArrayLiteral{Token: tok, Elements: elements}, nil
+	case *object.Hash:
+		tok.Type = token.LBRACE
+		tok.Literal = "{"
+		pairs := make([]ast.HashPair, len(obj.Keys))
+		for i, pair := range obj.OrderedPairs() {
+			key, err := convertObjectToASTNode(pair.Key, at)
+			if err != nil {
+				return nil, err
+			}
+			value, err := convertObjectToASTNode(pair.Value, at)
+			if err != nil {
+				return nil, err
+			}
+			pairs[i] = ast.HashPair{Key: key.(ast.Expression), Value: value.(ast.Expression)}
+		}
+		return &ast.HashLiteral{Token: tok, Pairs: pairs}, nil
+	case *object.Quote:
+		return ast.Copy(obj.Node), nil
+	default:
+		return nil, newError(at, object.TYPE_MISMATCH, "cannot unquote %s", typeOf(obj))
+	}
+}
@@ -0,0 +1,115 @@
+package evaluator
+
+import (
+	"monkey/object"
+	"testing"
+)
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(5)`, `5`},
+		{`quote(5 + 8)`, `(5 + 8)`},
+		{`quote(foobar)`, `foobar`},
+		{`quote(foobar + barfoo)`, `(foobar + barfoo)`},
+	}
+
+	for _, tt := range tests {
+		testQuoteObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestQuoteUnquote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(unquote(4))`, `4`},
+		{`quote(unquote(4 + 4))`, `8`},
+		{`quote(8 + unquote(4 + 4))`, `(8 + 8)`},
+		{`quote(unquote(4 + 4) + 8)`, `(8 + 8)`},
+		{`let foobar = 8; quote(foobar)`, `foobar`},
+		{`let foobar = 8; quote(unquote(foobar))`, `8`},
+		{`quote(unquote(1.5))`, `1.5`},
+		{`quote(unquote("a" + "b"))`, `"ab"`},
+		{`quote(unquote(true))`, `true`},
+		{`quote(unquote(true == false))`, `false`},
+		{`quote(unquote(quote(4 + 4)))`, `(4 + 4)`},
+		{`quote(unquote(9223372036854775807 + 1))`, `9223372036854775808`},
+		{`quote(unquote(-9223372036854775807 - 2))`, `-9223372036854775809`},
+		{`quote(unquote([1, "a", [true]]))`, `[1, "a", [true]]`},
+		{`quote(unquote([]))`, `[]`},
+		{`quote(unquote({"b": 1, "a": [2]}))`, `{"b": 1, "a": [2]}`},
+		{`quote(unquote([quote(x + 1)]))`, `[(x + 1)]`},
+		{`quote(unquote(if (false) { 1 }))`, `iffalse `},
+		{`let quotedInfixExpression = quote(4 + 4);
+		  quote(unquote(4 + 4) + unquote(quotedInfixExpression))`, `(8 + (4 + 4))`},
+	}
+
+	for _, tt := range tests {
+		testQuoteObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestQuoteUnquoteDoesNotModifyFunctionBody(t *testing.T) {
+	// 関数本体の quote を何度評価しても，元の構文木の unquote は残っている
+	input := `
+let f = fn(x) { quote(unquote(x) + 1) };
+f(1);
+f(2)`
+
+	testQuoteObject(t, testEval(input), `(2 + 1)`)
+}
+
+func TestQuoteErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedKind    object.ErrorKind
+		expectedMessage string
+	}{
+		{`quote()`, object.ARITY_MISMATCH, "wrong number of arguments to `quote`: want=1, got=0"},
+		{`quote(1, 2)`, object.ARITY_MISMATCH, "wrong number of arguments to `quote`: want=1, got=2"},
+		{`quote(unquote(1, 2))`, object.ARITY_MISMATCH, "wrong number of arguments to `unquote`: want=1, got=2"},
+		{`quote(unquote(fn(x) { x }))`, object.TYPE_MISMATCH, "cannot unquote FUNCTION"},
+		{`quote(unquote([1, len]))`, object.TYPE_MISMATCH, "cannot unquote BUILTIN"},
+		{`quote(unquote({"f": fn() { 1 }}))`, object.TYPE_MISMATCH, "cannot unquote FUNCTION"},
+		{`quote(unquote(0.0 / 0.0))`, object.TYPE_MISMATCH, "cannot unquote non-finite FLOAT NaN"},
+		{`quote(unquote(1e308 * 10.0))`, object.TYPE_MISMATCH, "cannot unquote non-finite FLOAT +Inf"},
+		{`quote(unquote([-1e308 * 10.0]))`, object.TYPE_MISMATCH, "cannot unquote non-finite FLOAT -Inf"},
+		{`quote(unquote(x))`, object.UNBOUND_IDENTIFIER, "identifier not found: x"},
+	}
+
+	for _, tt := range tests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q", tt.input)
+			continue
+		}
+		if errObj.Kind != tt.expectedKind || errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error for %q. want=%s %q, got=%s %q",
+				tt.input, tt.expectedKind, tt.expectedMessage, errObj.Kind, errObj.Message)
+		}
+	}
+}
+
+func testQuoteObject(t *testing.T, obj object.Object, expected string) bool {
+	quote, ok := obj.(*object.Quote)
+	if !ok {
+		t.Errorf("expected *object.Quote. got=%T (%+v)", obj, obj)
+		return false
+	}
+
+	if quote.Node == nil {
+		t.Errorf("quote.Node is nil")
+		return false
+	}
+
+	if quote.Node.String() != expected {
+		t.Errorf("not equal. got=%q, want=%q", quote.Node.String(), expected)
+		return false
+	}
+
+	return true
+}
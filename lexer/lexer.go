@@ -1,55 +1,142 @@
 package lexer
 
 import (
+	"fmt"
 	"monkey/token"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 字句解析で見つかったエラー
+type Error struct {
+	Pos token.Position
+	Msg string
+}
+
+func (e Error) Error() string { return fmt.Sprintf("%s: %s", e.Pos, e.Msg) }
+
+// 字句解析の動作を切り替えるフラグ
+type Mode uint
+
+const (
+	// コメントを読み飛ばさずに COMMENT トークンとして返す
+	ScanComments Mode = 1 << iota
 )
 
 type Lexer struct {
+	filename    string // トークンの位置情報に含めるファイル名
+	mode        Mode
 	input       string
-	position    int  // 現在読んでいる文字(ch)の位置
+	position    int  // 現在読んでいる文字(ch)の位置 (バイト単位)
 	readPositon int  // positionの次の位置
-	ch          byte // 現在読んでいる文字
+	ch          rune // 現在読んでいる文字
+	width       int  // 現在読んでいる文字(ch)の UTF-8 でのバイト数
+	line        int  // 現在読んでいる文字(ch)の行 (1始まり)
+	column      int  // 現在読んでいる文字(ch)の列 (1始まり, rune 単位)
+
+	errors []Error // 字句解析で見つかったエラー (ILLEGALトークンを返したときに記録する)
 }
 
 // Lexer のコンストラクタ
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	return NewFile("", input)
+}
+
+// ファイル名付きの Lexer のコンストラクタ
+// ファイル名はトークンの位置情報に埋め込まれ，エラーメッセージなどで "file:line:col" として表示される
+func NewFile(filename string, input string) *Lexer {
+	l := &Lexer{filename: filename, input: input, line: 1}
 	l.readChar()
+	l.skipShebang()
 	return l
 }
 
+// 字句解析の動作を切り替える
+func (l *Lexer) SetMode(mode Mode) {
+	l.mode = mode
+}
+
+// スクリプトとして直接実行できるように，先頭の "#!/usr/bin/env monkey" のような行を読み飛ばす
+// 改行は残しておくので，次の行の位置はずれない
+func (l *Lexer) skipShebang() {
+	if l.ch != '#' || l.peekChar() != '!' {
+		return
+	}
+	for l.ch != '\n' && l.ch != 0 {
+		l.readChar()
+	}
+}
+
 // Lexer のメソッド関数
 // 最初が小文字 → Lexerパッケージからのみ利用できる, 最初が大文字 → 他のパッケージでも使用できる
+// 入力は UTF-8 として1文字(rune)ずつ読む．列も rune 単位で数えるので，日本語の1文字は1列になる
 func (l *Lexer) readChar() {
+	// 改行を読み終えたら次の行の先頭に移る
+	if l.ch == '\n' {
+		l.line += 1
+		l.column = 1
+	} else {
+		l.column += 1
+	}
+
 	// 次の一文字が終端に到達したかどうかのチェック
 	if l.readPositon >= len(l.input) {
 		// ch = 0 はEOFを意味する
-		l.ch = 0
+		l.ch, l.width = 0, 1
 	} else {
-		// 現在はASCII文字だけを扱っているため、次の1バイトだけを読み込めばよい
-		// UnicodeやUTF-8を扱う場合、次の一文字が複数のバイトで構成される可能性があるため別途処理が必要となる
-		l.ch = l.input[l.readPositon]
+		// 1文字が複数のバイトで構成される可能性があるため，UTF-8 として1文字分をデコードする
+		l.ch, l.width = utf8.DecodeRuneInString(l.input[l.readPositon:])
 	}
 
 	l.position = l.readPositon
-	l.readPositon += 1
+	l.readPositon += l.width
+
+	// UTF-8 として正しくないバイトは，読んだ時点でその位置のエラーとして記録する
+	if l.isInvalidChar() {
+		l.errorf(l.currentPosition(), "invalid UTF-8 encoding %q", l.input[l.position:l.readPositon])
+	}
+}
+
+// 現在読んでいる文字が UTF-8 として正しくないバイトかどうか
+// 入力に U+FFFD そのものが書かれている場合は幅が3バイトになるので区別できる
+func (l *Lexer) isInvalidChar() bool {
+	return l.ch == utf8.RuneError && l.width == 1
 }
 
 // 覗き見(peek)しただけなので readCharとは異なり position は進めない
-func (l *Lexer) peekChar() byte {
+func (l *Lexer) peekChar() rune {
 	if l.readPositon >= len(l.input) {
 		return 0
 	} else {
 		// 一歩先の文字を読む
 		//(readPositon は position の次の位置を示す)
-		return l.input[l.readPositon]
+		r, _ := utf8.DecodeRuneInString(l.input[l.readPositon:])
+		return r
 	}
 }
 
 func (l *Lexer) NextToken() token.Token {
 	var tok token.Token
 
-	l.skipWhitespace()
+	// コメントは空白と同様に読み飛ばす
+	// ScanComments を指定している場合だけ COMMENT トークンとして返す
+	for {
+		l.skipWhitespace()
+		if !l.atComment() {
+			break
+		}
+
+		pos := l.currentPosition()
+		literal := l.readComment(pos)
+		if l.mode&ScanComments != 0 {
+			return token.Token{Type: token.COMMENT, Literal: literal, Pos: pos, End: l.currentPosition()}
+		}
+	}
+
+	// トークンの先頭の位置を覚えておく
+	pos := l.currentPosition()
 
 	switch l.ch {
 	case '=':
@@ -69,10 +156,8 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.RPAREN, l.ch)
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
-	case '+':
-		tok = newToken(token.PLUS, l.ch)
-	case '-':
-		tok = newToken(token.MINUS, l.ch)
+	case '+', '-', '*', '/', '%', '<', '>', '&', '|', '^':
+		tok = l.readOperator()
 	case '!':
 		if l.peekChar() == '=' {
 			ch := l.ch
@@ -82,74 +167,431 @@ func (l *Lexer) NextToken() token.Token {
 		} else {
 			tok = newToken(token.BANG, l.ch)
 		}
-	case '/':
-		tok = newToken(token.SLASH, l.ch)
-	case '*':
-		tok = newToken(token.ASTERISK, l.ch)
-	case '<':
-		tok = newToken(token.LT, l.ch)
-	case '>':
-		tok = newToken(token.GT, l.ch)
+	case '~':
+		tok = newToken(token.TILDE, l.ch)
 	case '{':
 		tok = newToken(token.LBRACE, l.ch)
 	case '}':
 		tok = newToken(token.RBRACE, l.ch)
+	case '[':
+		tok = newToken(token.LBRACKET, l.ch)
+	case ']':
+		tok = newToken(token.RBRACKET, l.ch)
+	case ':':
+		tok = newToken(token.COLON, l.ch)
+	case '"':
+		tok.Literal, tok.Type = l.readString(pos)
+		tok.Pos, tok.End = pos, l.currentPosition()
+		return tok // readString() で閉じる '"' の次まで読み進めているため
 	case 0:
 		tok.Literal = ""
 		tok.Type = token.EOF
 	default:
-		// letter: 英字
+		// letter: 英字や日本語などの Unicode の文字
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
+			tok.Pos, tok.End = pos, l.currentPosition()
 			return tok // readIdentifier() で既に readChar() を実行させているためreturnで脱出させる
 		} else if isDigit(l.ch) {
-			tok.Type = token.INT
-			tok.Literal = l.readNumber()
+			tok.Literal, tok.Type = l.readNumber(pos)
+			tok.Pos, tok.End = pos, l.currentPosition()
 			return tok
+		} else if l.isInvalidChar() {
+			// エラーは readChar で記録済み
+			tok = token.Token{Type: token.ILLEGAL, Literal: l.input[l.position:l.readPositon]}
 		} else {
+			l.errorf(pos, "illegal character %q", l.ch)
 			tok = newToken(token.ILLEGAL, l.ch)
 		}
 	}
 
 	l.readChar()
+	tok.Pos, tok.End = pos, l.currentPosition()
 	return tok
 }
 
+// readOperator で読む演算子
+// 長い演算子の先頭部分も必ず演算子になっている("<<=" に対する "<<" と "<")
+var operators = map[string]token.TokenType{
+	"+":   token.PLUS,
+	"-":   token.MINUS,
+	"*":   token.ASTERISK,
+	"/":   token.SLASH,
+	"%":   token.PERCENT,
+	"**":  token.POWER,
+	"<":   token.LT,
+	">":   token.GT,
+	"<=":  token.LT_EQ,
+	">=":  token.GT_EQ,
+	"<<":  token.SHIFT_LEFT,
+	">>":  token.SHIFT_RIGHT,
+	"&":   token.AMPERSAND,
+	"|":   token.PIPE,
+	"^":   token.CARET,
+	"&&":  token.AND,
+	"||":  token.OR,
+	"+=":  token.PLUS_ASSIGN,
+	"-=":  token.MINUS_ASSIGN,
+	"*=":  token.ASTERISK_ASSIGN,
+	"/=":  token.SLASH_ASSIGN,
+	"%=":  token.PERCENT_ASSIGN,
+	"**=": token.POWER_ASSIGN,
+	"&=":  token.AMPERSAND_ASSIGN,
+	"|=":  token.PIPE_ASSIGN,
+	"^=":  token.CARET_ASSIGN,
+	"<<=": token.SHIFT_LEFT_ASSIGN,
+	">>=": token.SHIFT_RIGHT_ASSIGN,
+}
+
+// "<", "<=", "<<", "<<=" のように先頭が同じ演算子を，できるだけ長く一致するように読む
+// 読み終えたとき l.ch は演算子の最後の文字になっている
+func (l *Lexer) readOperator() token.Token {
+	literal := string(l.ch)
+	for {
+		longer := literal + string(l.peekChar())
+		if _, ok := operators[longer]; !ok {
+			break
+		}
+		l.readChar()
+		literal = longer
+	}
+	return token.Token{Type: operators[literal], Literal: literal}
+}
+
+// 現在読んでいる文字(ch)の位置を返す
+func (l *Lexer) currentPosition() token.Position {
+	// 終端を越えて読み進めても，オフセットは入力の長さまでにとどめる
+	offset := l.position
+	if offset > len(l.input) {
+		offset = len(l.input)
+	}
+
+	return token.Position{
+		Filename: l.filename,
+		Offset:   offset,
+		Line:     l.line,
+		Column:   l.column,
+	}
+}
+
 // 例：「let abc ...」があったとき、position = 'a'の位置、l.position = 'c'の位置となり、その範囲のabcを取得する
 func (l *Lexer) readIdentifier() string {
 	// 最初の基準となる位置を把握しておく
 	position := l.position
 
-	// 識別子を非英字になるまで読み進める
-	for isLetter(l.ch) {
+	// 識別子を文字と数字以外になるまで読み進める
+	// 先頭は isLetter で判定済みなので，2文字目以降には数字も使える
+	for isLetter(l.ch) || unicode.IsDigit(l.ch) {
 		l.readChar()
 	}
 
 	return l.input[position:l.position]
 }
 
-func (l *Lexer) readNumber() string {
+// 数値リテラルを読む
+// 整数は 0x1F (16進数), 0o17 (8進数), 0b1010 (2進数) の接頭辞と，1_000_000 のような桁区切りの '_' を受け付ける
+// 0 で始まる 017 のような書き方は，strconv.ParseInt と同じく8進数として扱う
+// 10進数に小数部 ".5" か指数部 "e-9" が続く場合は浮動小数点数(FLOAT)として読む
+// 数字に続く英字なども1つのリテラルとして読み，その基数で使えない文字があればその位置でエラーを記録して ILLEGAL を返す
+func (l *Lexer) readNumber(start token.Position) (string, token.TokenType) {
 	position := l.position
 
-	// 識別子を非英字になるまで読み進める
-	for isDigit(l.ch) {
+	base, prefix := 10, 0
+	if l.ch == '0' {
+		switch l.peekChar() {
+		case 'x', 'X':
+			base, prefix = 16, 2
+		case 'o', 'O':
+			base, prefix = 8, 2
+		case 'b', 'B':
+			base, prefix = 2, 2
+		default:
+			if isDigit(l.peekChar()) || l.peekChar() == '_' {
+				base = 8
+			}
+		}
+	}
+
+	if prefix != 0 {
+		l.readAlnum()
+		literal := l.input[position:l.position]
+		if offset, msg := checkDigits(literal, base, prefix); msg != "" {
+			l.errorf(advance(start, literal[:offset]), "%s", msg)
+			return literal, token.ILLEGAL
+		}
+		return literal, token.INT
+	}
+
+	// 整数部，小数部，指数部の数字の並びをそれぞれ区切って検査する
+	// 数字に続く英字などは最後の部分に含めるので，その部分の検査でエラーになる
+	var parts []int // 各部分の先頭の位置
+	parts = append(parts, l.position)
+	l.readDigits()
+
+	var tokenType token.TokenType = token.INT
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		tokenType = token.FLOAT
+		l.readChar()
+		parts = append(parts, l.position)
+		l.readDigits()
+	}
+	if (l.ch == 'e' || l.ch == 'E') && (isDigit(l.peekChar()) || l.peekChar() == '+' || l.peekChar() == '-') {
+		tokenType = token.FLOAT
+		l.readChar()
+		if l.ch == '+' || l.ch == '-' {
+			l.readChar()
+		}
+		parts = append(parts, l.position)
+		if !isDigit(l.ch) {
+			l.errorf(advance(start, l.input[position:l.position]), "exponent has no digits")
+			l.readAlnum()
+			return l.input[position:l.position], token.ILLEGAL
+		}
+		l.readDigits()
+	}
+	l.readAlnum()
+
+	// 小数部や指数部がある場合，整数部は先頭が 0 でも10進数として扱う
+	if tokenType == token.FLOAT {
+		base = 10
+	}
+
+	literal := l.input[position:l.position]
+	parts = append(parts, l.position)
+	for i := 0; i < len(parts)-1; i++ {
+		part := l.input[parts[i]:parts[i+1]]
+		if i != len(parts)-2 {
+			// 次の部分との区切り("." や "e+")を除く
+			part = strings.TrimRight(part, ".eE+-")
+		}
+		if offset, msg := checkDigits(part, base, 0); msg != "" {
+			offset += parts[i] - position
+			l.errorf(advance(start, literal[:offset]), "%s", msg)
+			return literal, token.ILLEGAL
+		}
+	}
+	return literal, tokenType
+}
+
+// 10進数の数字と桁区切りの '_' を読み進める
+func (l *Lexer) readDigits() {
+	for isDigit(l.ch) || l.ch == '_' {
 		l.readChar()
 	}
+}
 
-	return l.input[position:l.position]
+// 数値リテラルに続く英字や数字をすべて読み進める
+func (l *Lexer) readAlnum() {
+	for isLetter(l.ch) || isDigit(l.ch) {
+		l.readChar()
+	}
+}
+
+var baseNames = map[int]string{2: "binary", 8: "octal", 10: "decimal", 16: "hexadecimal"}
+
+// 整数リテラルの接頭辞より後ろの各文字が，基数 base の数字または桁区切りとして正しいかを調べる
+// 誤りがあれば，その文字のリテラル内でのオフセットとエラーメッセージを返す
+func checkDigits(literal string, base, prefix int) (int, string) {
+	digits := literal[prefix:]
+	if prefix != 0 && digits == "" {
+		return 0, fmt.Sprintf("%s literal has no digits", baseNames[base])
+	}
+
+	// 直前の文字が数字か (接頭辞の直後の '_' は 0x_1F のように許す)
+	afterDigit := prefix != 0
+	for i, r := range digits {
+		if r == '_' {
+			if !afterDigit {
+				return prefix + i, "'_' must separate successive digits"
+			}
+			afterDigit = false
+			continue
+		}
+		if digitValue(r) >= base {
+			return prefix + i, fmt.Sprintf("invalid digit %q in %s literal", r, baseNames[base])
+		}
+		afterDigit = true
+	}
+
+	if !afterDigit {
+		return len(literal) - 1, "'_' must separate successive digits"
+	}
+	return 0, ""
+}
+
+// 数字の値を返す．数字でなければ基数として使われない大きな値を返す
+func digitValue(r rune) int {
+	switch {
+	case '0' <= r && r <= '9':
+		return int(r - '0')
+	case 'a' <= r && r <= 'f':
+		return int(r - 'a' + 10)
+	case 'A' <= r && r <= 'F':
+		return int(r - 'A' + 10)
+	}
+	return 16
+}
+
+// 同じ行で，pos から文字列 s の分だけ進んだ位置を返す (列は rune 単位で数える)
+func advance(pos token.Position, s string) token.Position {
+	pos.Offset += len(s)
+	pos.Column += utf8.RuneCountInString(s)
+	return pos
+}
+
+// コメントの始まり "//" または "/*" の上にいるかどうか
+func (l *Lexer) atComment() bool {
+	return l.ch == '/' && (l.peekChar() == '/' || l.peekChar() == '*')
+}
+
+// コメントを読み，"//" や "/* */" を含めたコメント全体の文字列を返す
+// "//" は行末まで(改行は含めない)，"/* */" は対応する "*/" までを読む
+// "/* */" は入れ子にできるので，コメントアウトした範囲にコメントが含まれていてもよい
+// 閉じ "*/" がないまま終端に達した場合は，開き "/*" の位置でエラーを記録する
+func (l *Lexer) readComment(start token.Position) string {
+	position := l.position
+
+	if l.peekChar() == '/' {
+		for l.ch != '\n' && l.ch != 0 {
+			l.readChar()
+		}
+		return l.input[position:l.position]
+	}
+
+	depth := 0
+	for {
+		switch {
+		case l.ch == '/' && l.peekChar() == '*':
+			depth++
+			l.readChar()
+		case l.ch == '*' && l.peekChar() == '/':
+			depth--
+			l.readChar()
+			if depth == 0 {
+				l.readChar()
+				return l.input[position:l.position]
+			}
+		case l.ch == 0 && l.position >= len(l.input):
+			l.errorf(start, "unterminated block comment")
+			return l.input[position:]
+		}
+		l.readChar()
+	}
+}
+
+// 文字列リテラルを読み，エスケープシーケンスを解釈した後の文字列を返す
+// 開き '"' の上から読み始め，閉じ '"' の次の文字まで読み進める
+// 閉じ '"' がないまま終端に達した場合は，開き '"' の位置でエラーを記録し ILLEGAL を返す
+func (l *Lexer) readString(start token.Position) (string, token.TokenType) {
+	var out strings.Builder
+
+	for {
+		l.readChar()
+
+		switch l.ch {
+		case '"':
+			l.readChar()
+			return out.String(), token.STRING
+		case 0:
+			if l.position >= len(l.input) {
+				l.errorf(start, "unterminated string literal")
+				return l.input[start.Offset:], token.ILLEGAL
+			}
+			out.WriteRune(l.ch)
+		case '\\':
+			l.readEscape(&out)
+			if l.position >= len(l.input) {
+				l.errorf(start, "unterminated string literal")
+				return l.input[start.Offset:], token.ILLEGAL
+			}
+		default:
+			l.writeChar(&out)
+		}
+	}
+}
+
+// 現在の文字を out に書き込む
+// UTF-8 として正しくないバイトはエラーを記録済みなので，置き換えずにそのまま書き込む
+func (l *Lexer) writeChar(out *strings.Builder) {
+	if l.isInvalidChar() {
+		out.WriteString(l.input[l.position:l.readPositon])
+		return
+	}
+	out.WriteRune(l.ch)
+}
+
+// "\" の上から読み始め，エスケープシーケンスを解釈して out に書き込む
+// 読み終えたとき l.ch はエスケープシーケンスの最後の文字になっている
+func (l *Lexer) readEscape(out *strings.Builder) {
+	pos := l.currentPosition()
+	l.readChar()
+
+	switch l.ch {
+	case 'n':
+		out.WriteByte('\n')
+	case 't':
+		out.WriteByte('\t')
+	case 'r':
+		out.WriteByte('\r')
+	case '"':
+		out.WriteByte('"')
+	case '\\':
+		out.WriteByte('\\')
+	case 'u':
+		// \u{1F600} のように，波括弧の中に16進数でコードポイントを書く
+		if l.peekChar() != '{' {
+			l.errorf(pos, "invalid unicode escape: expected '{' after \\u")
+			return
+		}
+		l.readChar()
+		digits := l.readPositon
+		for l.peekChar() != '}' && l.peekChar() != '"' && l.peekChar() != 0 {
+			l.readChar()
+		}
+		hex := l.input[digits:l.readPositon]
+		if l.peekChar() != '}' {
+			l.errorf(pos, "invalid unicode escape: missing '}'")
+			return
+		}
+		l.readChar()
+		code, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil || len(hex) > 6 || code > 0x10FFFF || (0xD800 <= code && code <= 0xDFFF) {
+			l.errorf(pos, "invalid unicode escape \\u{%s}", hex)
+			return
+		}
+		out.WriteRune(rune(code))
+	case 0:
+		// 終端に達した場合は readString で unterminated として扱う
+		if l.position < len(l.input) {
+			l.errorf(pos, "unknown escape sequence \\%c", l.ch)
+		}
+	default:
+		l.errorf(pos, "unknown escape sequence \\%c", l.ch)
+	}
+}
+
+// 字句解析のエラーを記録する
+func (l *Lexer) errorf(pos token.Position, format string, a ...interface{}) {
+	l.errors = append(l.errors, Error{Pos: pos, Msg: fmt.Sprintf(format, a...)})
+}
+
+// これまでに見つかった字句解析のエラーを返す
+func (l *Lexer) Errors() []Error {
+	return l.errors
 }
 
-func isDigit(ch byte) bool {
+func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
 
-func newToken(tokenType token.TokenType, ch byte) token.Token {
+func newToken(tokenType token.TokenType, ch rune) token.Token {
 	return token.Token{Type: tokenType, Literal: string(ch)}
 }
 
-func isLetter(ch byte) bool {
-	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+// 識別子に使える文字かどうか (unicode.IsLetter で判定するので，漢字や仮名も使える)
+func isLetter(ch rune) bool {
+	return unicode.IsLetter(ch) || ch == '_'
 }
 
 // トークンの区切りとなる文字（スペース, 改行, etc.）は読み飛ばす
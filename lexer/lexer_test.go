@@ -15,7 +15,7 @@ let add = fn(x, y) {
 
 let result = add(five, ten);
 
-!-/*5;
+!-/ *5;
 5 < 10 > 5;
 
 if (5 < 10) {
@@ -26,6 +26,11 @@ if (5 < 10) {
 
 10 == 10;
 10 != 9;
+"foobar"
+"foo bar"
+[1, 2];
+a[1:];
+{"foo": "bar"}
 `
 
 	tests := []struct {
@@ -105,6 +110,25 @@ if (5 < 10) {
 		{token.NOT_EQ, "!="},
 		{token.INT, "9"},
 		{token.SEMICOLON, ";"},
+		{token.STRING, "foobar"},
+		{token.STRING, "foo bar"},
+		{token.LBRACKET, "["},
+		{token.INT, "1"},
+		{token.COMMA, ","},
+		{token.INT, "2"},
+		{token.RBRACKET, "]"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.LBRACKET, "["},
+		{token.INT, "1"},
+		{token.COLON, ":"},
+		{token.RBRACKET, "]"},
+		{token.SEMICOLON, ";"},
+		{token.LBRACE, "{"},
+		{token.STRING, "foo"},
+		{token.COLON, ":"},
+		{token.STRING, "bar"},
+		{token.RBRACE, "}"},
 		{token.EOF, ""},
 	}
 
@@ -122,3 +146,400 @@ if (5 < 10) {
 		}
 	}
 }
+
+func TestTokenPosition(t *testing.T) {
+	input := `let x = 5;
+  x + 10;
+`
+
+	tests := []struct {
+		expectedLiteral string
+		expectedOffset  int
+		expectedLine    int
+		expectedColumn  int
+		expectedEndOff  int
+	}{
+		{"let", 0, 1, 1, 3},
+		{"x", 4, 1, 5, 5},
+		{"=", 6, 1, 7, 7},
+		{"5", 8, 1, 9, 9},
+		{";", 9, 1, 10, 10},
+		{"x", 13, 2, 3, 14},
+		{"+", 15, 2, 5, 16},
+		{"10", 17, 2, 7, 19},
+		{";", 19, 2, 9, 20},
+		{"", 21, 3, 1, 21},
+	}
+
+	l := NewFile("a.mk", input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Pos.Filename != "a.mk" {
+			t.Fatalf("tests[%d] - filename wrong. expected=%q, got=%q", i, "a.mk", tok.Pos.Filename)
+		}
+
+		if tok.Pos.Offset != tt.expectedOffset || tok.Pos.Line != tt.expectedLine ||
+			tok.Pos.Column != tt.expectedColumn {
+			t.Fatalf("tests[%d] - pos wrong. expected=%d (%d:%d), got=%d (%d:%d)", i,
+				tt.expectedOffset, tt.expectedLine, tt.expectedColumn,
+				tok.Pos.Offset, tok.Pos.Line, tok.Pos.Column)
+		}
+
+		if tok.End.Offset != tt.expectedEndOff {
+			t.Fatalf("tests[%d] - end offset wrong. expected=%d, got=%d", i, tt.expectedEndOff, tok.End.Offset)
+		}
+	}
+}
+
+func TestLoopKeywords(t *testing.T) {
+	input := `while for in break continue forever index`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.WHILE, "while"},
+		{token.FOR, "for"},
+		{token.IN, "in"},
+		{token.BREAK, "break"},
+		{token.CONTINUE, "continue"},
+		{token.IDENT, "forever"},
+		{token.IDENT, "index"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - token wrong. expected=%q %q, got=%q %q", i,
+				tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestMacroKeyword(t *testing.T) {
+	input := `let unless = macro(cond) { quote(unquote(cond)) };`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENT, "unless"},
+		{token.ASSIGN, "="},
+		{token.MACRO, "macro"},
+		{token.LPAREN, "("},
+		{token.IDENT, "cond"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.IDENT, "quote"},
+		{token.LPAREN, "("},
+		{token.IDENT, "unquote"},
+		{token.LPAREN, "("},
+		{token.IDENT, "cond"},
+		{token.RPAREN, ")"},
+		{token.RPAREN, ")"},
+		{token.RBRACE, "}"},
+		{token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - token wrong. expected=%q %q, got=%q %q", i,
+				tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestUnicodeIdentifiers(t *testing.T) {
+	input := `let 合計 = fn(値1, _x) { 値1 + "こんにちは" };
+合計`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedColumn  int
+	}{
+		{token.LET, "let", 1},
+		{token.IDENT, "合計", 5},
+		{token.ASSIGN, "=", 8},
+		{token.FUNCTION, "fn", 10},
+		{token.LPAREN, "(", 12},
+		{token.IDENT, "値1", 13},
+		{token.COMMA, ",", 15},
+		{token.IDENT, "_x", 17},
+		{token.RPAREN, ")", 19},
+		{token.LBRACE, "{", 21},
+		{token.IDENT, "値1", 23},
+		{token.PLUS, "+", 26},
+		{token.STRING, "こんにちは", 28},
+		{token.RBRACE, "}", 36},
+		{token.SEMICOLON, ";", 37},
+		{token.IDENT, "合計", 1},
+		{token.EOF, "", 3},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - token wrong. expected=%q %q, got=%q %q", i,
+				tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+		if tok.Pos.Column != tt.expectedColumn {
+			t.Fatalf("tests[%d] - column wrong. expected=%d, got=%d", i, tt.expectedColumn, tok.Pos.Column)
+		}
+	}
+
+	if len(l.Errors()) != 0 {
+		t.Errorf("unexpected lexer errors: %v", l.Errors())
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// line comment
+let x = 10 / 2; // trailing
+/* block
+   comment */ x
+/* outer /* nested */ still outer */ /**/ y`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.COMMENT, "// line comment"},
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "10"},
+		{token.SLASH, "/"},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.COMMENT, "// trailing"},
+		{token.COMMENT, "/* block\n   comment */"},
+		{token.IDENT, "x"},
+		{token.COMMENT, "/* outer /* nested */ still outer */"},
+		{token.COMMENT, "/**/"},
+		{token.IDENT, "y"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	l.SetMode(ScanComments)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - token wrong. expected=%q %q, got=%q %q", i,
+				tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+
+	// モードを指定しなければコメントは読み飛ばす
+	l = New(input)
+	for i, tt := range tests {
+		if tt.expectedType == token.COMMENT {
+			continue
+		}
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - token wrong without ScanComments. expected=%q %q, got=%q %q", i,
+				tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+
+	if len(l.Errors()) != 0 {
+		t.Errorf("unexpected lexer errors: %v", l.Errors())
+	}
+}
+
+func TestOperators(t *testing.T) {
+	input := `% ** * <= >= < > << >> && || & | ^ ~ <<= &&& += -= *= /= %= **= &= |= ^= >>= = ==`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.PERCENT, "%"},
+		{token.POWER, "**"},
+		{token.ASTERISK, "*"},
+		{token.LT_EQ, "<="},
+		{token.GT_EQ, ">="},
+		{token.LT, "<"},
+		{token.GT, ">"},
+		{token.SHIFT_LEFT, "<<"},
+		{token.SHIFT_RIGHT, ">>"},
+		{token.AND, "&&"},
+		{token.OR, "||"},
+		{token.AMPERSAND, "&"},
+		{token.PIPE, "|"},
+		{token.CARET, "^"},
+		{token.TILDE, "~"},
+		{token.SHIFT_LEFT_ASSIGN, "<<="},
+		{token.AND, "&&"},
+		{token.AMPERSAND, "&"},
+		{token.PLUS_ASSIGN, "+="},
+		{token.MINUS_ASSIGN, "-="},
+		{token.ASTERISK_ASSIGN, "*="},
+		{token.SLASH_ASSIGN, "/="},
+		{token.PERCENT_ASSIGN, "%="},
+		{token.POWER_ASSIGN, "**="},
+		{token.AMPERSAND_ASSIGN, "&="},
+		{token.PIPE_ASSIGN, "|="},
+		{token.CARET_ASSIGN, "^="},
+		{token.SHIFT_RIGHT_ASSIGN, ">>="},
+		{token.ASSIGN, "="},
+		{token.EQ, "=="},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - token wrong. expected=%q %q, got=%q %q", i,
+				tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestNumberLiterals(t *testing.T) {
+	input := `0x1F 0o17 0b1010 1_000_000 017 0 0xZ 1__0 3.14 1e-9 2.5E+3 1_000.000_1 01.5 5. 1.5x 1e+`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INT, "0x1F"},
+		{token.INT, "0o17"},
+		{token.INT, "0b1010"},
+		{token.INT, "1_000_000"},
+		{token.INT, "017"},
+		{token.INT, "0"},
+		{token.ILLEGAL, "0xZ"},
+		{token.ILLEGAL, "1__0"},
+		{token.FLOAT, "3.14"},
+		{token.FLOAT, "1e-9"},
+		{token.FLOAT, "2.5E+3"},
+		{token.FLOAT, "1_000.000_1"},
+		{token.FLOAT, "01.5"},
+		{token.INT, "5"},
+		{token.ILLEGAL, "."},
+		{token.ILLEGAL, "1.5x"},
+		{token.ILLEGAL, "1e+"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - token wrong. expected=%q %q, got=%q %q", i,
+				tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+
+	if len(l.Errors()) != 5 {
+		t.Errorf("expected 5 lexer errors, got=%v", l.Errors())
+	}
+}
+
+func TestStringEscapes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"a\nb"`, "a\nb"},
+		{`"a\tb"`, "a\tb"},
+		{`"say \"hi\""`, `say "hi"`},
+		{`"back\\slash"`, `back\slash`},
+		{`"\u{41}\u{3042}\u{1F600}"`, "A\u3042\U0001F600"},
+		{"\"multi\nline\"", "multi\nline"},
+		{`""`, ""},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+
+		if tok.Type != token.STRING {
+			t.Fatalf("tokentype wrong for %s. expected=%q, got=%q", tt.input, token.STRING, tok.Type)
+		}
+		if tok.Literal != tt.expected {
+			t.Errorf("literal wrong for %s. expected=%q, got=%q", tt.input, tt.expected, tok.Literal)
+		}
+		if len(l.Errors()) != 0 {
+			t.Errorf("unexpected lexer errors for %s: %v", tt.input, l.Errors())
+		}
+	}
+}
+
+func TestLexerErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let s = \"abc", "1:9: unterminated string literal"},
+		{"\n  \"abc\\", "2:3: unterminated string literal"},
+		{`"\q"`, `1:2: unknown escape sequence \q`},
+		{`"\u{110000}"`, `1:2: invalid unicode escape \u{110000}`},
+		{`"\u{41"`, `1:2: invalid unicode escape: missing '}'`},
+		{`"\u41"`, `1:2: invalid unicode escape: expected '{' after \u`},
+		{"5 @ 3", "1:3: illegal character '@'"},
+		{"let 値 = 1 ＠", "1:11: illegal character '＠'"},
+		{"let x = \xff;", `1:9: invalid UTF-8 encoding "\xff"`},
+		{"x /* a /* b */ c", "1:3: unterminated block comment"},
+		{"\"あ\xe3\x81\"", `1:3: invalid UTF-8 encoding "\xe3"`},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		}
+
+		errors := l.Errors()
+		if len(errors) == 0 {
+			t.Errorf("no lexer errors for %q", tt.input)
+			continue
+		}
+		if errors[0].Error() != tt.expected {
+			t.Errorf("wrong lexer error for %q. expected=%q, got=%q", tt.input, tt.expected, errors[0].Error())
+		}
+	}
+}
+
+func TestShebangIsSkipped(t *testing.T) {
+	input := "#!/usr/bin/env monkey\nlet x = 1;"
+
+	l := New(input)
+	tok := l.NextToken()
+
+	if tok.Type != token.LET {
+		t.Fatalf("tokentype wrong. expected=%q, got=%q", token.LET, tok.Type)
+	}
+	if tok.Pos.Line != 2 || tok.Pos.Column != 1 {
+		t.Fatalf("position wrong. expected=2:1, got=%s", tok.Pos)
+	}
+
+	// 先頭以外の "#!" は読み飛ばさない
+	l = New("1 #!")
+	l.NextToken()
+	if tok := l.NextToken(); tok.Type != token.ILLEGAL {
+		t.Fatalf("tokentype wrong. expected=%q, got=%q", token.ILLEGAL, tok.Type)
+	}
+}
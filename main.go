@@ -2,18 +2,217 @@ package main
 
 import (
 	"fmt"
+	"io"
+	"monkey/ast"
+	"monkey/compiler"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
 	"monkey/repl"
+	"monkey/vm"
 	"os"
 	"os/user"
+	"strings"
+)
+
+// プロセスの終了コード
+const (
+	exitOK           = 0
+	exitRuntimeError = 1 // 実行時エラー
+	exitParseError   = 2 // 構文エラー
+	exitUsage        = 64
+)
+
+const usage = `usage:
+  monkey                         start the interactive REPL
+  monkey run <script.mk> [args]  run a script file
+  monkey -e '<source>' [args]    run the given source code
+
+options:
+  --engine=eval|vm               run scripts with the tree-walking evaluator
+                                 (default) or the bytecode virtual machine;
+                                 must come before the script or source
+`
+
+// スクリプトを実行するエンジン
+const (
+	engineEval = "eval" // 抽象構文木をたどって評価する
+	engineVM   = "vm"   // バイトコードにコンパイルして仮想マシンで実行する
 )
 
 func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// コマンドライン引数に応じて REPL の起動またはスクリプトの実行を行い，終了コードを返す
+// --engine はサブコマンドの前後どちらにも書けるが，スクリプトやソースコードより前に書かなければならない
+func run(args []string, stdout, stderr io.Writer) int {
+	engine := engineEval
+	args = takeEngineFlags(args, &engine)
+
+	command := ""
+	if len(args) != 0 {
+		command = args[0]
+		args = append(args[:1:1], takeEngineFlags(args[1:], &engine)...)
+	}
+
+	if engine != engineEval && engine != engineVM {
+		fmt.Fprintf(stderr, "monkey: unknown engine %q\n", engine)
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+
+	// スクリプトの後ろの --engine はスクリプトへの引数と区別できないので，黙って渡さずにエラーにする
+	if len(args) > 2 && (command == "run" || command == "-e") {
+		for _, arg := range args[2:] {
+			if strings.HasPrefix(arg, "--engine=") {
+				fmt.Fprintf(stderr, "monkey: %s must come before the script or source\n", arg)
+				fmt.Fprint(stderr, usage)
+				return exitUsage
+			}
+		}
+	}
+
+	switch command {
+	case "":
+		// REPL は評価器だけで動かす
+		if engine != engineEval {
+			fmt.Fprintf(stderr, "monkey: the REPL supports only --engine=%s\n", engineEval)
+			return exitUsage
+		}
+		startREPL(stdout)
+		return exitOK
+	case "run":
+		if len(args) < 2 {
+			fmt.Fprint(stderr, usage)
+			return exitUsage
+		}
+		src, err := os.ReadFile(args[1])
+		if err != nil {
+			fmt.Fprintf(stderr, "monkey: %s\n", err)
+			return exitUsage
+		}
+		return execute(engine, args[1], string(src), args[2:], stdout, stderr)
+	case "-e":
+		if len(args) < 2 {
+			fmt.Fprint(stderr, usage)
+			return exitUsage
+		}
+		return execute(engine, "<eval>", args[1], args[2:], stdout, stderr)
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(stdout, usage)
+		return exitOK
+	default:
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+}
+
+// 先頭に並んだ --engine=<name> を取り除き，最後に指定したエンジンを engine に入れる
+func takeEngineFlags(args []string, engine *string) []string {
+	for len(args) != 0 && strings.HasPrefix(args[0], "--engine=") {
+		*engine = strings.TrimPrefix(args[0], "--engine=")
+		args = args[1:]
+	}
+	return args
+}
+
+func startREPL(out io.Writer) {
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
 	}
-	fmt.Printf("Hello %s! This is the Monkey programming language!\n",
+	fmt.Fprintf(out, "Hello %s! This is the Monkey programming language!\n",
 		user.Username)
-	fmt.Printf("Feel free to type in commands\n")
-	repl.Start(os.Stdin, os.Stdout)
+	fmt.Fprintf(out, "Feel free to type in commands\n")
+	repl.Start(os.Stdin, out)
+}
+
+// ソースコードを構文解析して，指定したエンジンで実行する
+// スクリプトへの引数は文字列の配列として変数 args に束縛する
+func execute(engine, filename, src string, scriptArgs []string, stdout, stderr io.Writer) int {
+	l := lexer.NewFile(filename, src)
+	p := parser.New(l)
+
+	program := p.ParseProgram()
+	if len(p.Diagnostics()) != 0 {
+		for _, d := range p.Diagnostics() {
+			fmt.Fprintf(stderr, "%s\n", d)
+			if d.Hint != "" {
+				fmt.Fprintf(stderr, "\thint: %s\n", d.Hint)
+			}
+		}
+		return exitParseError
+	}
+
+	// マクロは構文木の段階で展開するので，どちらのエンジンでも使える
+	macroEnv := object.NewEnvironment()
+	evaluator.DefineMacros(program, macroEnv)
+	if _, errObj := evaluator.ExpandMacros(program, macroEnv); errObj != nil {
+		printRuntimeError(stderr, errObj)
+		return exitRuntimeError
+	}
+
+	evaluator.SetOutput(stdout)
+	args := newArgsArray(scriptArgs)
+
+	if engine == engineVM {
+		return executeVM(program, args, stderr)
+	}
+
+	env := object.NewEnvironment()
+	env.Set("args", args)
+
+	evaluated := evaluator.Eval(program, env)
+	if errObj, ok := evaluated.(*object.Error); ok {
+		printRuntimeError(stderr, errObj)
+		return exitRuntimeError
+	}
+
+	return exitOK
+}
+
+// プログラムをバイトコードにコンパイルして仮想マシンで実行する
+// コンパイルエラーは構文エラーと同じ終了コードにする
+func executeVM(program *ast.Program, args *object.Array, stderr io.Writer) int {
+	symbolTable := compiler.NewGlobalSymbolTable()
+	argsSymbol := symbolTable.Define("args")
+
+	comp := compiler.NewWithState(symbolTable, []object.Object{})
+	if err := comp.Compile(program); err != nil {
+		fmt.Fprintf(stderr, "compile error: %s\n", err)
+		return exitParseError
+	}
+
+	machine := vm.New(comp.Bytecode())
+	machine.Globals()[argsSymbol.Index] = args
+
+	if err := machine.Run(); err != nil {
+		errObj, ok := err.(*object.Error)
+		if !ok {
+			errObj = &object.Error{Message: err.Error()}
+		}
+		printRuntimeError(stderr, errObj)
+		return exitRuntimeError
+	}
+
+	return exitOK
+}
+
+// 実行時エラーを位置とスタックトレースを付けて表示する
+func printRuntimeError(w io.Writer, errObj *object.Error) {
+	if errObj.Pos.IsValid() {
+		fmt.Fprintf(w, "%s: ", errObj.Pos)
+	}
+	fmt.Fprintf(w, "runtime error: %s\n", errObj.Message)
+	fmt.Fprint(w, errObj.StackTrace())
+}
+
+func newArgsArray(args []string) *object.Array {
+	elements := make([]object.Object, len(args))
+	for i, arg := range args {
+		elements[i] = &object.String{Value: arg}
+	}
+	return &object.Array{Elements: elements}
 }
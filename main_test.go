@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "script.mk")
+	src := "puts(len(args), args);\nlet x = 1 + true;"
+	if err := os.WriteFile(script, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		args           []string
+		expectedCode   int
+		expectedStdout string
+		expectedStderr string // 標準エラー出力の先頭
+	}{
+		// スクリプトファイルの実行と引数
+		{
+			[]string{"run", script, "a", "b"},
+			exitRuntimeError,
+			"2\n[a, b]\n",
+			script + ":2:9: runtime error: type mismatch: INTEGER + BOOLEAN\n",
+		},
+		{
+			[]string{"--engine=vm", "run", script, "a"},
+			exitRuntimeError,
+			"1\n[a]\n",
+			script + ":2:9: runtime error: type mismatch: INTEGER + BOOLEAN\n",
+		},
+		{
+			[]string{"run", filepath.Join(t.TempDir(), "missing.mk")},
+			exitUsage,
+			"",
+			"monkey: open ",
+		},
+		{[]string{"run"}, exitUsage, "", "usage:"},
+
+		// -e で渡したソースコードの実行
+		{[]string{"-e", "puts(1 + 2)"}, exitOK, "3\n", ""},
+		{[]string{"--engine=vm", "-e", "puts(1 + 2)"}, exitOK, "3\n", ""},
+		{[]string{"-e", "puts(args)", "x", "y"}, exitOK, "[x, y]\n", ""},
+		{[]string{"--engine=vm", "-e", "puts(args)", "x", "y"}, exitOK, "[x, y]\n", ""},
+		{[]string{"-e", "puts(args)"}, exitOK, "[]\n", ""},
+		{[]string{"-e"}, exitUsage, "", "usage:"},
+
+		// 構文エラー
+		{
+			[]string{"-e", "let = 1"},
+			exitParseError,
+			"",
+			"<eval>:1:5: expected next token to be IDENT, got = instead\n",
+		},
+		{
+			[]string{"--engine=vm", "-e", "let = 1"},
+			exitParseError,
+			"",
+			"<eval>:1:5: expected next token to be IDENT, got = instead\n",
+		},
+
+		// 実行時エラー
+		{
+			[]string{"-e", "puts(1);\nx"},
+			exitRuntimeError,
+			"1\n",
+			"<eval>:2:1: runtime error: identifier not found: x\n",
+		},
+		{
+			[]string{"--engine=vm", "-e", "puts(1);\nx"},
+			exitRuntimeError,
+			"1\n",
+			"<eval>:2:1: runtime error: identifier not found: x\n",
+		},
+
+		// コンパイルエラーは構文エラーと同じ終了コードになる
+		{[]string{"-e", "quote(1)"}, exitOK, "", ""},
+		{
+			[]string{"--engine=vm", "-e", "quote(1)"},
+			exitParseError,
+			"",
+			"compile error: <eval>:1:1: quote is not supported by the vm engine\n",
+		},
+
+		// エンジンの指定
+		{[]string{"--engine=eval", "-e", "puts(true)"}, exitOK, "true\n", ""},
+		{[]string{"--engine=js", "-e", "1"}, exitUsage, "", "monkey: unknown engine \"js\"\nusage:"},
+		{[]string{"--engine=", "-e", "1"}, exitUsage, "", "monkey: unknown engine \"\"\n"},
+		{[]string{"--engine=vm"}, exitUsage, "", "monkey: the REPL supports only --engine=eval\n"},
+		// サブコマンドの後ろにも書ける
+		{
+			[]string{"run", "--engine=vm", script, "a"},
+			exitRuntimeError,
+			"1\n[a]\n",
+			script + ":2:9: runtime error: type mismatch: INTEGER + BOOLEAN\n",
+		},
+		{[]string{"-e", "--engine=vm", "quote(1)"}, exitParseError, "", "compile error: "},
+		{[]string{"--engine=vm", "-e", "--engine=eval", "quote(1)"}, exitOK, "", ""},
+		{[]string{"run", "--engine=js", script}, exitUsage, "", "monkey: unknown engine \"js\"\n"},
+		// スクリプトやソースコードの後ろに書くとエラーになり，スクリプトへの引数にはならない
+		{
+			[]string{"-e", "puts(args)", "--engine=vm"},
+			exitUsage,
+			"",
+			"monkey: --engine=vm must come before the script or source\nusage:",
+		},
+		{
+			[]string{"run", script, "a", "--engine=vm"},
+			exitUsage,
+			"",
+			"monkey: --engine=vm must come before the script or source\nusage:",
+		},
+
+		// 使い方の表示
+		{[]string{"--help"}, exitOK, usage, ""},
+		{[]string{"bogus"}, exitUsage, "", usage},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		code := run(tt.args, &stdout, &stderr)
+
+		if code != tt.expectedCode {
+			t.Errorf("run(%q): wrong exit code. want=%d, got=%d (stderr=%q)",
+				tt.args, tt.expectedCode, code, stderr.String())
+		}
+		if stdout.String() != tt.expectedStdout {
+			t.Errorf("run(%q): wrong stdout. want=%q, got=%q",
+				tt.args, tt.expectedStdout, stdout.String())
+		}
+		if tt.expectedStderr == "" && stderr.Len() != 0 {
+			t.Errorf("run(%q): unexpected stderr %q", tt.args, stderr.String())
+		}
+		if !strings.HasPrefix(stderr.String(), tt.expectedStderr) {
+			t.Errorf("run(%q): wrong stderr. want prefix %q, got=%q",
+				tt.args, tt.expectedStderr, stderr.String())
+		}
+	}
+}
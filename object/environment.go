@@ -0,0 +1,64 @@
+package object
+
+import "sort"
+
+// 識別子と値の対応を保持する環境
+// outer には関数を定義した場所の環境(外側のスコープ)が入る
+type Environment struct {
+	store map[string]Object
+	outer *Environment
+}
+
+// Environment のコンストラクタ
+func NewEnvironment() *Environment {
+	s := make(map[string]Object)
+	return &Environment{store: s, outer: nil}
+}
+
+// 関数呼び出しの際に，関数が定義された環境(outer)を包んだ新しい環境を作る
+// 引数の束縛は新しい環境に入るので，外側の同名の変数を上書きしない
+func NewEnclosedEnvironment(outer *Environment) *Environment {
+	env := NewEnvironment()
+	env.outer = outer
+	return env
+}
+
+// 名前に束縛された値を取得する
+// 見つからなければ外側の環境を順にたどって探す
+func (e *Environment) Get(name string) (Object, bool) {
+	obj, ok := e.store[name]
+	if !ok && e.outer != nil {
+		obj, ok = e.outer.Get(name)
+	}
+	return obj, ok
+}
+
+// 現在の環境に名前と値を束縛する
+func (e *Environment) Set(name string, val Object) Object {
+	e.store[name] = val
+	return val
+}
+
+// すでに束縛されている名前に値を再代入する
+// 現在の環境から外側へ順にたどり，最初に見つかった環境の束縛を書き換える
+// どの環境にも名前がなければ何もせずに false を返す
+func (e *Environment) Assign(name string, val Object) (Object, bool) {
+	for env := e; env != nil; env = env.outer {
+		if _, ok := env.store[name]; ok {
+			env.store[name] = val
+			return val, true
+		}
+	}
+	return nil, false
+}
+
+// 現在の環境に束縛されている名前を辞書順で返す
+// 外側の環境の名前は含まない
+func (e *Environment) Names() []string {
+	names := make([]string, 0, len(e.store))
+	for name := range e.store {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,75 @@
+package object
+
+import "unicode/utf8"
+
+// for文で要素を順にたどるための反復子
+type Iterator interface {
+	// 次の要素を返す．要素が残っていなければ false を返す
+	Next() (Object, bool)
+}
+
+// 値の要素をたどる反復子を返す
+// 配列は要素を，ハッシュはキーを挿入した順に，文字列は1文字ずつの文字列を，Range は整数を順に返す
+// 反復できない値なら false を返す
+func NewIterator(obj Object) (Iterator, bool) {
+	switch obj := obj.(type) {
+	case *Array:
+		return &arrayIterator{elements: obj.Elements}, true
+	case *Hash:
+		// 反復中にハッシュにキーを追加しても，作った時点のキーだけをたどる
+		keys := make([]Object, 0, len(obj.Keys))
+		for _, pair := range obj.OrderedPairs() {
+			keys = append(keys, pair.Key)
+		}
+		return &arrayIterator{elements: keys}, true
+	case *String:
+		return &stringIterator{value: obj.Value}, true
+	case *Range:
+		return &rangeIterator{r: obj, n: obj.Len()}, true
+	default:
+		return nil, false
+	}
+}
+
+type arrayIterator struct {
+	elements []Object
+	i        int
+}
+
+func (it *arrayIterator) Next() (Object, bool) {
+	if it.i >= len(it.elements) {
+		return nil, false
+	}
+	element := it.elements[it.i]
+	it.i++
+	return element, true
+}
+
+type stringIterator struct {
+	value  string
+	offset int // 次の文字のバイトオフセット
+}
+
+func (it *stringIterator) Next() (Object, bool) {
+	if it.offset >= len(it.value) {
+		return nil, false
+	}
+	r, width := utf8.DecodeRuneInString(it.value[it.offset:])
+	it.offset += width
+	return &String{Value: string(r)}, true
+}
+
+type rangeIterator struct {
+	r *Range
+	i int64
+	n int64 // 要素数
+}
+
+func (it *rangeIterator) Next() (Object, bool) {
+	if it.i >= it.n {
+		return nil, false
+	}
+	value := it.r.At(it.i)
+	it.i++
+	return &Integer{Value: value}, true
+}
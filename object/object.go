@@ -1,13 +1,58 @@
 package object
 
-import "fmt"
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/big"
+	"monkey/ast"
+	"monkey/code"
+	"monkey/token"
+	"strconv"
+	"strings"
+)
 
 type ObjectType string
 
+// Goで実装されたMonkeyの組み込み関数
+type BuiltinFunction func(args ...Object) Object
+
 const (
 	NULL_OBJ    = "NULL"
 	INTEGER_OBJ = "INTEGER"
+	FLOAT_OBJ   = "FLOAT"
+	BIGINT_OBJ  = "BIGINT"
 	BOOLEAN_OBJ = "BOOLEAN"
+	STRING_OBJ  = "STRING"
+
+	RETURN_VALUE_OBJ = "RETURN_VALUE"
+	BREAK_OBJ        = "BREAK"
+	CONTINUE_OBJ     = "CONTINUE"
+	ERROR_OBJ        = "ERROR"
+
+	FUNCTION_OBJ          = "FUNCTION"
+	BUILTIN_OBJ           = "BUILTIN"
+	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION"
+	CELL_OBJ              = "CELL"
+
+	QUOTE_OBJ = "QUOTE"
+	MACRO_OBJ = "MACRO"
+
+	ARRAY_OBJ = "ARRAY"
+	HASH_OBJ  = "HASH"
+	RANGE_OBJ = "RANGE"
+)
+
+// true, false, null は値が1種類しかないので，評価のたびに新しいオブジェクトを作らず使いまわす
+// 同じインスタンスを参照するので，比較はポインタの比較だけで済む
+var (
+	NULL  = &Null{}
+	TRUE  = &Boolean{Value: true}
+	FALSE = &Boolean{Value: false}
+
+	BREAK    = &Break{}
+	CONTINUE = &Continue{}
 )
 
 type Object interface {
@@ -24,6 +69,34 @@ type Integer struct {
 func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
 func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
 
+// int64 に収まらない整数
+// 整数の演算が int64 の範囲を超えたときに自動的にこの型になり，範囲に収まる結果は Integer に戻す
+// そのため BigInt が int64 の範囲の値を持つことはない
+type BigInt struct {
+	Value *big.Int
+}
+
+func (b *BigInt) Type() ObjectType { return BIGINT_OBJ }
+func (b *BigInt) Inspect() string  { return b.Value.String() }
+
+// 浮動小数点数 (IEEE 754 倍精度)
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+
+// 表示した文字列をそのまま Monkey のリテラルとして読み直せるように，
+// 値を正確に表す最短の表記を使い，整数と区別できるように小数点か指数を必ず含める
+// NaN と無限大はリテラルで書けないので "NaN", "+Inf", "-Inf" と表示する
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if math.IsNaN(f.Value) || math.IsInf(f.Value, 0) || strings.ContainsAny(s, ".e") {
+		return s
+	}
+	return s + ".0"
+}
+
 type Boolean struct {
 	Value bool
 }
@@ -31,7 +104,393 @@ type Boolean struct {
 func (b *Boolean) Type() ObjectType { return BOOLEAN_OBJ }
 func (b *Boolean) Inspect() string  { return fmt.Sprintf("%t", b.Value) }
 
+type String struct {
+	Value string
+}
+
+func (s *String) Type() ObjectType { return STRING_OBJ }
+func (s *String) Inspect() string  { return s.Value }
+
 type Null struct{}
 
 func (n *Null) Type() ObjectType { return NULL_OBJ }
 func (n *Null) Inspect() string  { return "null" }
+
+// return文で返された値を包むためのオブジェクト
+// ブロックを抜けるときに包まれたままにしておくことで，外側の評価を打ち切れるようにする
+type ReturnValue struct {
+	Value Object
+}
+
+func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
+func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
+
+// break文と continue文を評価したことを表すオブジェクト
+// ReturnValue と同じようにブロックを抜けても外側へ伝わり，最も内側のループで取り除かれる
+type Break struct{}
+
+func (b *Break) Type() ObjectType { return BREAK_OBJ }
+func (b *Break) Inspect() string  { return "break" }
+
+type Continue struct{}
+
+func (c *Continue) Type() ObjectType { return CONTINUE_OBJ }
+func (c *Continue) Inspect() string  { return "continue" }
+
+// 実行時エラーの種類
+type ErrorKind string
+
+const (
+	TYPE_MISMATCH      ErrorKind = "type mismatch"      // 5 + true など，演算子の両辺の型が合わない
+	UNKNOWN_OPERATOR   ErrorKind = "unknown operator"   // -true など，その型に定義されていない演算子
+	UNBOUND_IDENTIFIER ErrorKind = "unbound identifier" // 環境に束縛されていない識別子
+	ARITY_MISMATCH     ErrorKind = "arity mismatch"     // 関数の仮引数と実引数の数が合わない
+	DIVISION_BY_ZERO   ErrorKind = "division by zero"   // 0 による除算
+	INDEX_OUT_OF_RANGE ErrorKind = "index out of range" // 配列の範囲外へのアクセス
+	UNHASHABLE_KEY     ErrorKind = "unhashable key"     // ハッシュのキーに使えない値 (関数など)
+	OUT_OF_RANGE       ErrorKind = "out of range"       // 負のシフト量や大きすぎる指数など，演算の範囲外の値
+	STACK_OVERFLOW     ErrorKind = "stack overflow"     // 仮想マシンで関数呼び出しが深くなりすぎた
+)
+
+// 実行時エラー
+// 評価中にエラーが発生したら，このオブジェクトを返して以降の評価を打ち切る
+type Error struct {
+	Kind    ErrorKind
+	Message string
+	Pos     token.Position // エラーが発生したノードの位置
+	Stack   []string       // エラーが伝搬してきた関数名 (内側の呼び出しが先頭)
+}
+
+func (e *Error) Type() ObjectType { return ERROR_OBJ }
+func (e *Error) Inspect() string {
+	if e.Pos.IsValid() {
+		return fmt.Sprintf("ERROR: %s: %s", e.Pos, e.Message)
+	}
+	return "ERROR: " + e.Message
+}
+
+// Go の error としても扱えるようにする (仮想マシンの Run が返す)
+func (e *Error) Error() string { return e.Message }
+
+// スタックトレースとして表示する行数の上限
+const maxStackTraceLines = 20
+
+// Monkeyレベルのスタックトレースを1行1フレームで返す
+// 同じ関数が続くフレームは1行にまとめ，maxStackTraceLines 行を超えた分は数だけを表示する
+func (e *Error) StackTrace() string {
+	var out bytes.Buffer
+
+	lines := 0
+	for i := 0; i < len(e.Stack); {
+		if lines == maxStackTraceLines {
+			fmt.Fprintf(&out, "\t... %d more frames\n", len(e.Stack)-i)
+			break
+		}
+
+		// 再帰で同じ関数が続く場合は1行にまとめる
+		j := i + 1
+		for j < len(e.Stack) && e.Stack[j] == e.Stack[i] {
+			j++
+		}
+		out.WriteString("\tat " + e.Stack[i] + "\n")
+		if repeated := j - i - 1; repeated > 0 {
+			fmt.Fprintf(&out, "\t... repeated %d more times\n", repeated)
+		}
+
+		lines++
+		i = j
+	}
+
+	return out.String()
+}
+
+// 関数オブジェクト
+// Env には関数リテラルを評価した時点の環境を保持しておき，クロージャを実現する
+type Function struct {
+	Name       string // let で束縛された名前．スタックトレースに使う (無名関数なら空)
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
+
+func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
+func (f *Function) Inspect() string  { return inspectFunction(f.Parameters, f.Body) }
+
+func inspectFunction(parameters []*ast.Identifier, body *ast.BlockStatement) string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString("fn")
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") {\n")
+	out.WriteString(body.String())
+	out.WriteString("\n}")
+
+	return out.String()
+}
+
+// コンパイラが関数リテラルから作る，命令列になった関数
+// 定数プールに置かれ，実行時に OpClosure によって Closure に包まれる
+type CompiledFunction struct {
+	Instructions  code.Instructions
+	Positions     code.PosTable // 実行時エラーの位置を求めるための表
+	NumLocals     int           // 仮引数を含む局所変数の数
+	NumParameters int
+	Name          string   // let で束縛された名前．スタックトレースに使う (無名関数なら空)
+	LocalNames    []string // 局所変数の名前 (添字は局所変数の番号)．エラーメッセージに使う
+	FreeNames     []string // 自由変数の名前 (添字は自由変数の番号)
+
+	// 元の関数リテラル．表示に使う (プログラム本体なら nil)
+	Literal *ast.FunctionLiteral
+}
+
+func (cf *CompiledFunction) Type() ObjectType { return COMPILED_FUNCTION_OBJ }
+func (cf *CompiledFunction) Inspect() string {
+	if cf.Literal == nil {
+		return fmt.Sprintf("CompiledFunction[%p]", cf)
+	}
+	return inspectFunction(cf.Literal.Parameters, cf.Literal.Body)
+}
+
+// 仮想マシンの関数オブジェクト
+// 関数と，それが捕捉した外側の変数(自由変数)のセルの組．評価器の Function と区別できないように型は FUNCTION にする
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []*Cell
+}
+
+func (c *Closure) Type() ObjectType { return FUNCTION_OBJ }
+func (c *Closure) Inspect() string  { return c.Fn.Inspect() }
+
+// クロージャに捕捉された変数の入れ物
+// 関数と外側のスコープで同じセルを共有するので，どちらで代入してももう一方から見える
+// Value が nil なら，変数はまだ定義されていない
+type Cell struct {
+	Value Object
+}
+
+func (c *Cell) Type() ObjectType { return CELL_OBJ }
+func (c *Cell) Inspect() string {
+	if c.Value == nil {
+		return "<unbound>"
+	}
+	return c.Value.Inspect()
+}
+
+// quote で評価せずに取り出した構文木
+// マクロはこれを返し，呼び出し箇所がその構文木に置き換わる
+type Quote struct {
+	Node ast.Node
+}
+
+func (q *Quote) Type() ObjectType { return QUOTE_OBJ }
+func (q *Quote) Inspect() string  { return "QUOTE(" + q.Node.String() + ")" }
+
+// マクロオブジェクト
+// 関数と同じく定義した時点の環境を持つが，呼び出すと引数を評価せずに quote したまま受け取る
+type Macro struct {
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
+
+func (m *Macro) Type() ObjectType { return MACRO_OBJ }
+func (m *Macro) Inspect() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range m.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString("macro")
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") {\n")
+	out.WriteString(m.Body.String())
+	out.WriteString("\n}")
+
+	return out.String()
+}
+
+// 組み込み関数オブジェクト
+type Builtin struct {
+	Name string
+	Fn   BuiltinFunction
+}
+
+func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
+func (b *Builtin) Inspect() string  { return "builtin function " + b.Name }
+
+type Array struct {
+	Elements []Object
+}
+
+func (ao *Array) Type() ObjectType { return ARRAY_OBJ }
+func (ao *Array) Inspect() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, e := range ao.Elements {
+		elements = append(elements, e.Inspect())
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+
+// ハッシュのキーとして使うための値
+// 同じ値を持つオブジェクトは，別のインスタンスでも同じ HashKey になる
+type HashKey struct {
+	Type  ObjectType
+	Value uint64
+}
+
+// ハッシュのキーとして使えるオブジェクトが実装するインターフェース
+type Hashable interface {
+	HashKey() HashKey
+}
+
+func (b *Boolean) HashKey() HashKey {
+	var value uint64
+
+	if b.Value {
+		value = 1
+	} else {
+		value = 0
+	}
+
+	return HashKey{Type: b.Type(), Value: value}
+}
+
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+func (b *BigInt) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte(b.Value.String()))
+
+	return HashKey{Type: b.Type(), Value: h.Sum64()}
+}
+
+func (s *String) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte(s.Value))
+
+	return HashKey{Type: s.Type(), Value: h.Sum64()}
+}
+
+// ハッシュに格納するキーと値の組
+// HashKey からは元のキーを復元できないので，キーのオブジェクトも一緒に保持する
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+// ハッシュ(連想配列)
+// 表示や繰り返しの順序が毎回同じになるように，キーを挿入した順番も保持する
+type Hash struct {
+	Pairs map[HashKey]HashPair
+	Keys  []HashKey // キーを挿入した順番
+}
+
+// Hash のコンストラクタ
+func NewHash() *Hash {
+	return &Hash{Pairs: make(map[HashKey]HashPair)}
+}
+
+// キーに値を格納する
+// 既にあるキーの場合は値だけを更新し，順番は最初に挿入したときのままにする
+func (h *Hash) Set(key Hashable, value Object) {
+	hashKey := key.HashKey()
+	if _, ok := h.Pairs[hashKey]; !ok {
+		h.Keys = append(h.Keys, hashKey)
+	}
+	h.Pairs[hashKey] = HashPair{Key: key.(Object), Value: value}
+}
+
+// キーに対応する値を取得する
+func (h *Hash) Get(key Hashable) (Object, bool) {
+	pair, ok := h.Pairs[key.HashKey()]
+	if !ok {
+		return nil, false
+	}
+	return pair.Value, true
+}
+
+// キーと値の組を挿入した順番で返す
+func (h *Hash) OrderedPairs() []HashPair {
+	pairs := make([]HashPair, 0, len(h.Keys))
+	for _, key := range h.Keys {
+		pairs = append(pairs, h.Pairs[key])
+	}
+	return pairs
+}
+
+func (h *Hash) Type() ObjectType { return HASH_OBJ }
+func (h *Hash) Inspect() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for _, pair := range h.OrderedPairs() {
+		pairs = append(pairs, fmt.Sprintf("%s: %s",
+			pair.Key.Inspect(), pair.Value.Inspect()))
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// range(start, stop, step) が返す整数の列
+// 要素を配列として持たずに必要になった時点で計算するので，大きな範囲でもメモリを使わない
+type Range struct {
+	Start int64
+	Stop  int64 // この値は含まない
+	Step  int64 // 0 以外
+}
+
+// 列の要素数
+// 要素数が int64 に収まらない Range は作らないこと (LenFits で確かめる)
+func (r *Range) Len() int64 {
+	return int64(r.count())
+}
+
+// 要素数が int64 に収まるかどうか
+// Step が ±1 で int64 のほぼ全体にわたる範囲 (最大 2^64-1 要素) は収まらない
+func (r *Range) LenFits() bool {
+	return r.count() <= math.MaxInt64
+}
+
+// Stop - Start が int64 に収まらない場合でも正しく数えられるように，uint64 で計算する
+func (r *Range) count() uint64 {
+	switch {
+	case r.Step > 0 && r.Start < r.Stop:
+		return (uint64(r.Stop)-uint64(r.Start)-1)/uint64(r.Step) + 1
+	case r.Step < 0 && r.Start > r.Stop:
+		return (uint64(r.Start)-uint64(r.Stop)-1)/(-uint64(r.Step)) + 1
+	default:
+		return 0
+	}
+}
+
+// i 番目 (0始まり) の要素
+func (r *Range) At(i int64) int64 {
+	return int64(uint64(r.Start) + uint64(i)*uint64(r.Step))
+}
+
+func (r *Range) Type() ObjectType { return RANGE_OBJ }
+func (r *Range) Inspect() string {
+	return fmt.Sprintf("range(%d, %d, %d)", r.Start, r.Stop, r.Step)
+}
@@ -0,0 +1,199 @@
+package object
+
+import (
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestStringHashKey(t *testing.T) {
+	hello1 := &String{Value: "Hello World"}
+	hello2 := &String{Value: "Hello World"}
+	diff1 := &String{Value: "My name is johnny"}
+	diff2 := &String{Value: "My name is johnny"}
+
+	if hello1.HashKey() != hello2.HashKey() {
+		t.Errorf("strings with same content have different hash keys")
+	}
+
+	if diff1.HashKey() != diff2.HashKey() {
+		t.Errorf("strings with same content have different hash keys")
+	}
+
+	if hello1.HashKey() == diff1.HashKey() {
+		t.Errorf("strings with different content have same hash keys")
+	}
+}
+
+func TestHashKeyDistinguishesTypes(t *testing.T) {
+	if (&Integer{Value: 1}).HashKey() == TRUE.HashKey() {
+		t.Errorf("integer 1 and true have same hash keys")
+	}
+	if (&Integer{Value: 0}).HashKey() == FALSE.HashKey() {
+		t.Errorf("integer 0 and false have same hash keys")
+	}
+}
+
+func TestHashKeepsInsertionOrder(t *testing.T) {
+	h := NewHash()
+	h.Set(&String{Value: "c"}, &Integer{Value: 1})
+	h.Set(&String{Value: "a"}, &Integer{Value: 2})
+	h.Set(&String{Value: "b"}, &Integer{Value: 3})
+	h.Set(&String{Value: "a"}, &Integer{Value: 4})
+
+	expected := []string{"c", "a", "b"}
+	pairs := h.OrderedPairs()
+	if len(pairs) != len(expected) {
+		t.Fatalf("wrong number of pairs. expected=%d, got=%d", len(expected), len(pairs))
+	}
+	for i, key := range expected {
+		if pairs[i].Key.Inspect() != key {
+			t.Errorf("pairs[%d] has wrong key. expected=%q, got=%q", i, key, pairs[i].Key.Inspect())
+		}
+	}
+
+	value, ok := h.Get(&String{Value: "a"})
+	if !ok || value.Inspect() != "4" {
+		t.Errorf("h.Get(a) wrong. got=%v (%t)", value, ok)
+	}
+}
+
+func TestEnvironmentNames(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("z", &Integer{Value: 1})
+
+	env := NewEnclosedEnvironment(outer)
+	env.Set("b", &Integer{Value: 2})
+	env.Set("a", &Integer{Value: 3})
+
+	names := env.Names()
+	expected := []string{"a", "b"}
+	if len(names) != len(expected) {
+		t.Fatalf("wrong number of names. expected=%v, got=%v", expected, names)
+	}
+	for i, name := range expected {
+		if names[i] != name {
+			t.Errorf("names[%d] wrong. expected=%q, got=%q", i, name, names[i])
+		}
+	}
+}
+
+func TestEnvironmentAssign(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("x", &Integer{Value: 1})
+	env := NewEnclosedEnvironment(outer)
+
+	if _, ok := env.Assign("x", &Integer{Value: 2}); !ok {
+		t.Fatalf("Assign of outer binding failed")
+	}
+	if len(env.Names()) != 0 {
+		t.Errorf("Assign created a local binding. got=%v", env.Names())
+	}
+	if val, _ := outer.Get("x"); val.(*Integer).Value != 2 {
+		t.Errorf("outer binding not updated. got=%s", val.Inspect())
+	}
+
+	if _, ok := env.Assign("y", &Integer{Value: 3}); ok {
+		t.Errorf("Assign of undeclared name succeeded")
+	}
+	if _, ok := outer.Get("y"); ok {
+		t.Errorf("Assign of undeclared name created a binding")
+	}
+}
+
+func TestRangeLen(t *testing.T) {
+	tests := []struct {
+		r        Range
+		expected int64
+	}{
+		{Range{Start: 0, Stop: 10, Step: 1}, 10},
+		{Range{Start: 0, Stop: 10, Step: 3}, 4},
+		{Range{Start: 10, Stop: 0, Step: -3}, 4},
+		{Range{Start: 5, Stop: 5, Step: 1}, 0},
+		{Range{Start: 5, Stop: 0, Step: 1}, 0},
+		{Range{Start: 0, Stop: 5, Step: -1}, 0},
+		{Range{Start: math.MinInt64, Stop: math.MaxInt64, Step: math.MaxInt64}, 3},
+		{Range{Start: math.MaxInt64, Stop: math.MinInt64, Step: math.MinInt64}, 2},
+	}
+
+	for _, tt := range tests {
+		if !tt.r.LenFits() {
+			t.Errorf("%s.LenFits() should be true", tt.r.Inspect())
+		}
+		if got := tt.r.Len(); got != tt.expected {
+			t.Errorf("%s.Len() wrong. expected=%d, got=%d", tt.r.Inspect(), tt.expected, got)
+		}
+	}
+
+	// 要素数が int64 に収まらない範囲と，ちょうど収まる範囲
+	tooLong := []Range{
+		{Start: math.MinInt64, Stop: math.MaxInt64, Step: 1},
+		{Start: math.MaxInt64, Stop: math.MinInt64, Step: -1},
+		{Start: math.MinInt64, Stop: 0, Step: 1}, // 2^63 要素
+	}
+	for _, r := range tooLong {
+		if r.LenFits() {
+			t.Errorf("%s.LenFits() should be false", r.Inspect())
+		}
+	}
+	longest := Range{Start: math.MinInt64, Stop: -1, Step: 1}
+	if !longest.LenFits() || longest.Len() != math.MaxInt64 {
+		t.Errorf("%s.Len() wrong. expected=%d, got=%d", longest.Inspect(), int64(math.MaxInt64), longest.Len())
+	}
+	half := Range{Start: math.MinInt64, Stop: math.MaxInt64 - 1, Step: 2}
+	if !half.LenFits() || half.Len() != 1<<63-1 {
+		t.Errorf("%s.Len() wrong. expected=%d, got=%d", half.Inspect(), int64(1<<63-1), half.Len())
+	}
+
+	r := &Range{Start: math.MinInt64, Stop: math.MaxInt64, Step: math.MaxInt64}
+	if r.At(1) != -1 {
+		t.Errorf("%s.At(1) wrong. expected=%d, got=%d", r.Inspect(), -1, r.At(1))
+	}
+}
+
+func TestBigIntHashKey(t *testing.T) {
+	a, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	b, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	c, _ := new(big.Int).SetString("-123456789012345678901234567890", 10)
+
+	if (&BigInt{Value: a}).HashKey() != (&BigInt{Value: b}).HashKey() {
+		t.Errorf("big integers with same value have different hash keys")
+	}
+	if (&BigInt{Value: a}).HashKey() == (&BigInt{Value: c}).HashKey() {
+		t.Errorf("big integers with different values have same hash keys")
+	}
+}
+
+func TestErrorStackTrace(t *testing.T) {
+	repeat := func(name string, n int) []string {
+		stack := make([]string, n)
+		for i := range stack {
+			stack[i] = name
+		}
+		return stack
+	}
+	alternating := make([]string, 30)
+	for i := range alternating {
+		alternating[i] = []string{"a", "b"}[i%2]
+	}
+
+	tests := []struct {
+		stack    []string
+		expected string
+	}{
+		{nil, ""},
+		{[]string{"inner", "outer"}, "\tat inner\n\tat outer\n"},
+		// 再帰で続く同じ関数は1行にまとめる
+		{append(repeat("r", 5), "main"), "\tat r\n\t... repeated 4 more times\n\tat main\n"},
+		// 表示する行数には上限がある
+		{alternating, strings.Repeat("\tat a\n\tat b\n", 10) + "\t... 10 more frames\n"},
+	}
+
+	for _, tt := range tests {
+		err := &Error{Message: "boom", Stack: tt.stack}
+		if got := err.StackTrace(); got != tt.expected {
+			t.Errorf("wrong stack trace for %v.\nwant=%q\ngot =%q", tt.stack, tt.expected, got)
+		}
+	}
+}
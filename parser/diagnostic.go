@@ -0,0 +1,63 @@
+package parser
+
+import (
+	"fmt"
+	"monkey/token"
+)
+
+// 構文解析時に報告できるエラーの最大数
+// これを超えたエラーは捨てて，代わりに "too many errors" を1つだけ報告する
+const MaxErrors = 10
+
+// 診断メッセージの深刻度
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	default:
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+}
+
+// 構文解析で見つかった問題1件分の情報
+type Diagnostic struct {
+	Severity Severity
+	Pos      token.Position  // 問題のあるトークンの先頭
+	End      token.Position  // 問題のあるトークンの末尾の次
+	Message  string          // 位置を含まないメッセージ本文
+	Expected token.TokenType // 期待していたトークンの種類 (特定のトークンを期待していなければ空)
+	Found    token.Token     // 実際に見つかったトークン
+	Hint     string          // 修正のヒント (なければ空)
+}
+
+// "file:line:col: message" の形式で返す
+// Parser.Errors() はこの形式の文字列を返す
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s", d.Pos, d.Message)
+}
+
+// 期待していたトークンと実際のトークンの組み合わせから，よくある間違いに対するヒントを作る
+func hintFor(expected token.TokenType, found token.Token) string {
+	switch {
+	case found.Type == token.EOF && expected != "":
+		return fmt.Sprintf("input ended early; is a closing %q missing?", string(expected))
+	case found.Type == token.EOF:
+		return "input ended early; the expression is incomplete"
+	case expected == token.ASSIGN && found.Type == token.EQ:
+		return "use '=' to bind a value; '==' compares two values"
+	case expected == token.IDENT && token.LookupIdent(found.Literal) != token.IDENT:
+		return fmt.Sprintf("%q is a keyword and cannot be used as a name", found.Literal)
+	case expected == "" && (found.Type == token.RPAREN || found.Type == token.RBRACE):
+		return fmt.Sprintf("%q has no matching opening bracket", found.Literal)
+	}
+	return ""
+}
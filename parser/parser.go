@@ -1,38 +1,102 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"math"
+	"math/big"
 	"monkey/ast"
 	"monkey/lexer"
 	"monkey/token"
 	"strconv"
+	"strings"
 )
 
-// iotaを使って，LOWESTが1，EQUALSが2，...と値を入れていく
-// この値は演算時の優先順位を示している．1が最も速い
+// iotaを使って，LOWESTが1，LOGICAL_ORが2，...と値を入れていく
+// この値は演算時の優先順位を示している．値が大きいほど強く結びつく
+//
+//	優先順位     演算子                結合
+//	ASSIGN       = += -= *= /= %= ...  右
+//	LOGICAL_OR   ||                    左
+//	LOGICAL_AND  &&                    左
+//	EQUALS       == !=                 左
+//	LESSGREATER  < > <= >=             左
+//	BITWISE_OR   |                     左
+//	BITWISE_XOR  ^                     左
+//	BITWISE_AND  &                     左
+//	SHIFT        << >>                 左
+//	SUM          + -                   左
+//	PRODUCT      * / %                 左
+//	PREFIX       -X !X ~X              (前置)
+//	POWER        **                    右
+//	CALL         myFunction(X)
+//	INDEX        array[index]
+//
+// ビット演算子は C とは異なり比較演算子より強く結びつくので，x & 1 == 0 は (x & 1) == 0 になる
+// ** は前置演算子より強く結びつくので，-2 ** 2 は -(2 ** 2) になる
 const (
 	_ int = iota
 	LOWEST
+	ASSIGN      // x = 5 or x += 1
+	LOGICAL_OR  // ||
+	LOGICAL_AND // &&
 	EQUALS      // ==
 	LESSGREATER // > or <
+	BITWISE_OR  // |
+	BITWISE_XOR // ^
+	BITWISE_AND // &
+	SHIFT       // << or >>
 	SUM         // +
 	PRODUCT     // *
 	PREFIX      // -X or !X
+	POWER       // **
 	CALL        // myFunction(X)
+	INDEX       // array[index]
 )
 
 // 優先順位テーブル
-// EQ(=)とNOT_EQ(!=)は同じ優先順位(EQUALS=2)など
+// EQ(=)とNOT_EQ(!=)は同じ優先順位(EQUALS)など
 var precedences = map[token.TokenType]int{
-	token.EQ:       EQUALS,
-	token.NOT_EQ:   EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
-	token.LPAREN:   CALL,
+	token.ASSIGN:             ASSIGN,
+	token.PLUS_ASSIGN:        ASSIGN,
+	token.MINUS_ASSIGN:       ASSIGN,
+	token.ASTERISK_ASSIGN:    ASSIGN,
+	token.SLASH_ASSIGN:       ASSIGN,
+	token.PERCENT_ASSIGN:     ASSIGN,
+	token.POWER_ASSIGN:       ASSIGN,
+	token.AMPERSAND_ASSIGN:   ASSIGN,
+	token.PIPE_ASSIGN:        ASSIGN,
+	token.CARET_ASSIGN:       ASSIGN,
+	token.SHIFT_LEFT_ASSIGN:  ASSIGN,
+	token.SHIFT_RIGHT_ASSIGN: ASSIGN,
+	token.OR:                 LOGICAL_OR,
+	token.AND:                LOGICAL_AND,
+	token.EQ:                 EQUALS,
+	token.NOT_EQ:             EQUALS,
+	token.LT:                 LESSGREATER,
+	token.GT:                 LESSGREATER,
+	token.LT_EQ:              LESSGREATER,
+	token.GT_EQ:              LESSGREATER,
+	token.PIPE:               BITWISE_OR,
+	token.CARET:              BITWISE_XOR,
+	token.AMPERSAND:          BITWISE_AND,
+	token.SHIFT_LEFT:         SHIFT,
+	token.SHIFT_RIGHT:        SHIFT,
+	token.PLUS:               SUM,
+	token.MINUS:              SUM,
+	token.SLASH:              PRODUCT,
+	token.ASTERISK:           PRODUCT,
+	token.PERCENT:            PRODUCT,
+	token.POWER:              POWER,
+	token.LPAREN:             CALL,
+	token.LBRACKET:           INDEX,
+}
+
+// 右結合の演算子
+// 2 ** 3 ** 2 は 2 ** (3 ** 2) になる
+// 代入演算子は parseAssignExpression で右結合として扱う
+var rightAssociative = map[token.TokenType]bool{
+	token.POWER: true,
 }
 
 type (
@@ -43,12 +107,31 @@ type (
 type Parser struct {
 	l *lexer.Lexer
 
-	errors []string
+	diagnostics []Diagnostic
+
+	// 診断メッセージとして取り込み済みの字句解析エラーの数
+	lexErrors int
+
+	// エラーを報告してから次の文の境界で同期するまでの間は true になる
+	// この間に見つかったエラーは最初のエラーの巻き添えなので報告しない
+	panicking bool
+
+	// 現在パースしているブロック "{ }" の深さ
+	// 同期の際に，ブロックの中なら "}" の手前で止まるために使う
+	blockDepth int
+
+	// 現在パースしているループの深さ
+	// ループの外にある break や continue をエラーにするために使う．関数リテラルに入ると 0 に戻る
+	loopDepth int
 
 	// lexerでは文字列を読んでいたが、今回はトークンを取得する
 	curToken  token.Token
 	peekToken token.Token
 
+	// curToken, peekToken の直前にあるドキュメントコメント (なければ nil)
+	curDoc  *ast.CommentGroup
+	peekDoc *ast.CommentGroup
+
 	// -5 や !hoge などの前置演算子をパースするために，トークンと対応したパース関数をmapで紐づける
 	prefixParseFns map[token.TokenType]prefixParseFn
 
@@ -59,20 +142,30 @@ type Parser struct {
 // Parserを作るためのコンストラクタ
 func New(l *lexer.Lexer) *Parser {
 	p := &Parser{
-		l:      l,
-		errors: []string{},
+		l:           l,
+		diagnostics: []Diagnostic{},
 	}
 
+	// ドキュメントコメントを let 文に付けるために，コメントもトークンとして受け取る
+	l.SetMode(lexer.ScanComments)
+
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
+	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.ILLEGAL, p.parseIllegal)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
+	p.registerPrefix(token.TILDE, p.parsePrefixExpression)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
 	p.registerPrefix(token.FALSE, p.parseBoolean)
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
+	p.registerPrefix(token.MACRO, p.parseMacroLiteral)
+	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
+	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
 
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
@@ -83,11 +176,27 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
+	for _, tok := range []token.TokenType{
+		token.PERCENT, token.POWER, token.LT_EQ, token.GT_EQ, token.AND, token.OR,
+		token.AMPERSAND, token.PIPE, token.CARET, token.SHIFT_LEFT, token.SHIFT_RIGHT,
+	} {
+		p.registerInfix(tok, p.parseInfixExpression)
+	}
+
+	// 代入 x = 5 や複合代入 x += 1 も，代入先を左辺とする中値演算子として登録する
+	for tok, precedence := range precedences {
+		if precedence == ASSIGN {
+			p.registerInfix(tok, p.parseAssignExpression)
+		}
+	}
 
 	// 呼び出し式 add(2, 3) における中値演算子"("を登録する
 	// これは add という識別子(関数を束縛している) と 2,3 という引数リストの2つの式を持つ必要があるので，中値演算子となる
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 
+	// 添字式 array[1] における中値演算子"["を登録する
+	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+
 	// 現在調べているトークンだけだと十分な情報が得られない場合があるので、次のトークンも調べるようにする
 	/* 十分な情報を得られない例
 	5; なのか 5 + 5; なのかを判別するとき．;があるから処理を終えるのか，+だから演算子に関連したパーサを呼び出すのか
@@ -107,21 +216,124 @@ func (p *Parser) parseIdentifier() ast.Expression {
 	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 }
 
+// エラーを "file:line:col: message" 形式の文字列で返す
+// 詳しい情報が必要な場合は Diagnostics を使う
 func (p *Parser) Errors() []string {
-	return p.errors
+	errors := make([]string, 0, len(p.diagnostics))
+	for _, d := range p.diagnostics {
+		errors = append(errors, d.String())
+	}
+	return errors
+}
+
+// 構文解析で見つかった問題を報告順に返す
+func (p *Parser) Diagnostics() []Diagnostic {
+	return p.diagnostics
+}
+
+// tok の位置でエラーを報告する
+// 同期待ちの間や，報告数が上限に達した後のエラーは捨てる
+func (p *Parser) errorAt(tok token.Token, expected token.TokenType, format string, a ...interface{}) {
+	if p.panicking {
+		return
+	}
+	p.panicking = true
+
+	p.report(Diagnostic{
+		Severity: SeverityError,
+		Pos:      tok.Pos,
+		End:      tok.End,
+		Message:  fmt.Sprintf(format, a...),
+		Expected: expected,
+		Found:    tok,
+		Hint:     hintFor(expected, tok),
+	})
+}
+
+// 診断メッセージを記録する
+// 報告数が上限に達したら "too many errors" を1つだけ記録し，以降は捨てる
+func (p *Parser) report(d Diagnostic) {
+	if len(p.diagnostics) > MaxErrors {
+		return
+	}
+	if len(p.diagnostics) == MaxErrors {
+		d.Message = "too many errors"
+		d.Expected = ""
+		d.Hint = ""
+	}
+	p.diagnostics = append(p.diagnostics, d)
 }
 
 func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead",
+	p.errorAt(p.peekToken, t, "expected next token to be %s, got %s instead",
 		t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+}
+
+// エラーの後，次の文の境界までトークンを読み飛ばす
+// ";" の上，または次のトークンが文の始まり(let, return, while, for など)・ブロックの終わり "}"・終端の手前で止まる
+// これにより，1つの書き間違いから連鎖的にエラーが報告されるのを防ぐ
+func (p *Parser) synchronize() {
+	for !p.curTokenIs(token.SEMICOLON) && !p.curTokenIs(token.EOF) {
+		switch p.peekToken.Type {
+		case token.LET, token.RETURN, token.WHILE, token.FOR, token.BREAK, token.CONTINUE, token.EOF:
+			p.panicking = false
+			return
+		case token.RBRACE:
+			if p.blockDepth > 0 {
+				p.panicking = false
+				return
+			}
+		}
+		p.nextToken()
+	}
+	p.panicking = false
 }
 
 // peekToekenに今見ているトークンが入っている
 // nextToken関数が呼ばれたらcurTokenに今見ていたトークンを格納して、peekTokenは次のトークンを見るようにする
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
-	p.peekToken = p.l.NextToken()
+	p.curDoc = p.peekDoc
+	p.peekToken, p.peekDoc = p.readToken()
+
+	// 字句解析で見つかったエラーを診断メッセージとして取り込む
+	for _, e := range p.l.Errors()[p.lexErrors:] {
+		p.report(Diagnostic{
+			Severity: SeverityError,
+			Pos:      e.Pos,
+			End:      p.peekToken.End,
+			Message:  e.Msg,
+			Found:    p.peekToken,
+		})
+	}
+	p.lexErrors = len(p.l.Errors())
+}
+
+// コメント以外の次のトークンと，その直前にあるドキュメントコメントを返す
+// 空行を挟まずに連続したコメントを1つのまとまりとし，トークンの直前の行で終わるものをドキュメントコメントとする
+// 前のトークンと同じ行にあるコメント("let x = 1; // ..." など)は，その行の注釈なのでドキュメントコメントにしない
+func (p *Parser) readToken() (token.Token, *ast.CommentGroup) {
+	var group *ast.CommentGroup
+
+	for {
+		tok := p.l.NextToken()
+		if tok.Type != token.COMMENT {
+			if group != nil && group.End().Line < tok.Pos.Line-1 {
+				group = nil
+			}
+			return tok, group
+		}
+
+		comment := &ast.Comment{Token: tok, Text: tok.Literal}
+		switch {
+		case tok.Pos.Line == p.curToken.End.Line:
+			group = nil
+		case group != nil && tok.Pos.Line <= group.End().Line+1:
+			group.List = append(group.List, comment)
+		default:
+			group = &ast.CommentGroup{List: []*ast.Comment{comment}}
+		}
+	}
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
@@ -132,8 +344,11 @@ func (p *Parser) ParseProgram() *ast.Program {
 	for p.curToken.Type != token.EOF {
 		stmt := p.parseStatement()
 
+		// エラーがあれば次の文の境界まで読み飛ばす (壊れた文は捨てる)
 		// parseStatementで読み込んだステートメントがnil以外（事前に定義したletやreturnなど）であればStatementsに追加する
-		if stmt != nil {
+		if p.panicking {
+			p.synchronize()
+		} else if stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
 		p.nextToken()
@@ -148,6 +363,14 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.WHILE:
+		return p.parseWhileStatement()
+	case token.FOR:
+		return p.parseForStatement()
+	case token.BREAK:
+		return p.parseBreakStatement()
+	case token.CONTINUE:
+		return p.parseContinueStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -156,7 +379,7 @@ func (p *Parser) parseStatement() ast.Statement {
 // 初めがletで，次が識別子，その次が=であることをチェック素すr
 // 初めがletなのは既にparseStatementで確定させている
 func (p *Parser) parseLetStatement() *ast.LetStatement {
-	stmt := &ast.LetStatement{Token: p.curToken}
+	stmt := &ast.LetStatement{Doc: p.curDoc, Token: p.curToken}
 
 	// 識別子のチェック
 	if !p.expectPeek(token.IDENT) {
@@ -195,6 +418,110 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return stmt
 }
 
+// while (条件) { 本体 }
+func (p *Parser) parseWhileStatement() *ast.WhileStatement {
+	stmt := &ast.WhileStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// for (変数 in 反復対象) { 本体 }
+func (p *Parser) parseForStatement() *ast.ForStatement {
+	stmt := &ast.ForStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Variable = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Iterable = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// ループの本体をパースする
+// 本体の中では break と continue が使える
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+	p.loopDepth++
+	defer func() { p.loopDepth-- }()
+
+	return p.parseBlockStatement()
+}
+
+func (p *Parser) parseBreakStatement() *ast.BreakStatement {
+	stmt := &ast.BreakStatement{Token: p.curToken}
+
+	if p.loopDepth == 0 {
+		p.errorAt(p.curToken, "", "break is not in a loop")
+		return nil
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseContinueStatement() *ast.ContinueStatement {
+	stmt := &ast.ContinueStatement{Token: p.curToken}
+
+	if p.loopDepth == 0 {
+		p.errorAt(p.curToken, "", "continue is not in a loop")
+		return nil
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
 
@@ -208,8 +535,7 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 }
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.errorAt(p.curToken, "", "no prefix parse function for %s found", t)
 }
 
 func (p *Parser) parseExpression(precedence int) ast.Expression {
@@ -276,7 +602,11 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	}
 
 	// 現在の演算子の優先順位を得る
+	// 右結合の演算子は，右辺に同じ優先順位の演算子が続いたときに右辺側で結びつけるよう，優先順位を1つ下げる
 	precedence := p.curPrecedence()
+	if rightAssociative[p.curToken.Type] {
+		precedence--
+	}
 	p.nextToken()
 
 	// 次のトークンの優先順位を得る
@@ -343,6 +673,10 @@ func (p *Parser) parseIfExpression() ast.Expression {
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
+	errs := len(p.diagnostics)
+
+	p.blockDepth++
+	defer func() { p.blockDepth-- }()
 
 	p.nextToken()
 
@@ -350,12 +684,22 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	// "}" → 正常終了, "EOF" → 正しい構文ではない
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
 		stmt := p.parseStatement()
-		if stmt != nil {
+		if p.panicking {
+			p.synchronize()
+		} else if stmt != nil {
 			block.Statements = append(block.Statements, stmt)
 		}
 		p.nextToken()
 	}
 
+	if p.curTokenIs(token.RBRACE) {
+		block.Rbrace = p.curToken.Pos
+	} else if len(p.diagnostics) == errs {
+		// ブロック内で既にエラーを報告していれば，閉じ括弧がないことは報告しない
+		p.errorAt(p.curToken, token.RBRACE, "expected %s to close the block, got %s instead",
+			token.RBRACE, p.curToken.Type)
+	}
+
 	return block
 }
 
@@ -374,6 +718,35 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
+	// 関数の本体から外側のループを break することはできない
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
+	defer func() { p.loopDepth = loopDepth }()
+
+	lit.Body = p.parseBlockStatement()
+
+	return lit
+}
+
+// マクロリテラル macro(x, y) { ... }
+// 構文は関数リテラルと同じ
+func (p *Parser) parseMacroLiteral() ast.Expression {
+	lit := &ast.MacroLiteral{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	lit.Parameters = p.parseFunctionParameters()
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
+	defer func() { p.loopDepth = loopDepth }()
+
 	lit.Body = p.parseBlockStatement()
 
 	return lit
@@ -414,7 +787,10 @@ func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
 
 	// CallExpression ノードの Arguments に 関数呼び出しの引数部分を格納
-	exp.Arguments = p.parseCallArguments()
+	exp.Arguments = p.parseExpressionList(token.RPAREN)
+	if p.curTokenIs(token.RPAREN) {
+		exp.Rparen = p.curToken.Pos
+	}
 	return exp
 }
 
@@ -423,10 +799,11 @@ func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 // 1つ目：引数にあたる部分に識別子や関数リテラルなどいろんなものが入るので，parseExpressionを使っていパン化しているところ
 // 2つ目：返り値が []*ast.Identifier ではなく []ast.Expression となる
 // 関数リテラルの定義時は識別子だけだが，関数呼び出しでは識別子だけでなく，引数に関数リテラルを入れることもできる
-func (p *Parser) parseCallArguments() []ast.Expression {
+// 関数呼び出しの引数 "(a, b)" と配列リテラルの要素 "[a, b]" で共通して使うので，終わりのトークンを end で受け取る
+func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	args := []ast.Expression{}
 
-	if p.peekTokenIs(token.RPAREN) {
+	if p.peekTokenIs(end) {
 		p.nextToken()
 		return args
 	}
@@ -440,20 +817,134 @@ func (p *Parser) parseCallArguments() []ast.Expression {
 		args = append(args, p.parseExpression(LOWEST))
 	}
 
-	if !p.expectPeek(token.RPAREN) {
+	if !p.expectPeek(end) {
 		return nil
 	}
 
 	return args
 }
 
+func (p *Parser) parseArrayLiteral() ast.Expression {
+	array := &ast.ArrayLiteral{Token: p.curToken}
+
+	array.Elements = p.parseExpressionList(token.RBRACKET)
+	if p.curTokenIs(token.RBRACKET) {
+		array.Rbracket = p.curToken.Pos
+	}
+
+	return array
+}
+
+// ハッシュリテラル {<key>: <value>, ...} をパースする
+// ブロック文の "{" は if や fn の後で parseBlockStatement が直接読むので，式の先頭に現れる "{" はハッシュとなる
+func (p *Parser) parseHashLiteral() ast.Expression {
+	hash := &ast.HashLiteral{Token: p.curToken, Pairs: []ast.HashPair{}}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		key := p.parseExpression(LOWEST)
+
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+
+		p.nextToken()
+		value := p.parseExpression(LOWEST)
+
+		hash.Pairs = append(hash.Pairs, ast.HashPair{Key: key, Value: value})
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	hash.Rbrace = p.curToken.Pos
+
+	return hash
+}
+
+// 添字式 a[i] とスライス式 a[low:high] をパースする
+// "[" の直後または添字の後に ":" があればスライス式になる
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	tok := p.curToken
+
+	var index ast.Expression
+	if !p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		index = p.parseExpression(LOWEST)
+	}
+
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		slice := &ast.SliceExpression{Token: tok, Left: left, Low: index}
+
+		if !p.peekTokenIs(token.RBRACKET) {
+			p.nextToken()
+			slice.High = p.parseExpression(LOWEST)
+		}
+
+		if !p.expectPeek(token.RBRACKET) {
+			return nil
+		}
+		slice.Rbracket = p.curToken.Pos
+
+		return slice
+	}
+
+	exp := &ast.IndexExpression{Token: tok, Left: left, Index: index}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	exp.Rbracket = p.curToken.Pos
+
+	return exp
+}
+
+// 整数のリテラルをパースする
+// int64 に収まらない値は範囲外のエラーにせず，多倍長整数(BigInt)のリテラルにする
+// 演算の結果があふれたときに BigInt になるのと同じく，リテラルでも大きな整数をそのまま書ける
+// -9223372036854775808 は 9223372036854775808 に前置の "-" を付けた式なので，評価すると int64 の最小値になる
 func (p *Parser) parseIntegerLiteral() ast.Expression {
 	lit := &ast.IntegerLiteral{Token: p.curToken}
 
+	// 接頭辞(0x, 0o, 0b)や桁区切りの '_' は字句解析で検査済みなので，ここで失敗するのは範囲外の場合だけ
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		bigValue, ok := new(big.Int).SetString(p.curToken.Literal, 0)
+		if !ok {
+			p.errorAt(p.curToken, "", "could not parse %q as integer", p.curToken.Literal)
+			return nil
+		}
+		return &ast.BigIntLiteral{Token: p.curToken, Value: bigValue}
+	}
+	if err != nil {
+		p.errorAt(p.curToken, "", "could not parse %q as integer", p.curToken.Literal)
+		return nil
+	}
+
+	lit.Value = value
+
+	return lit
+}
+
+// 浮動小数点数のリテラルをパースする
+// strconv.ParseFloat は10進数の '_' を受け付けないので，取り除いてから変換する
+// 絶対値が大きすぎて無限大になる場合はエラーとし，小さすぎる場合は 0 に丸める
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.curToken}
+
+	value, err := strconv.ParseFloat(strings.ReplaceAll(p.curToken.Literal, "_", ""), 64)
+	if errors.Is(err, strconv.ErrRange) {
+		p.errorAt(p.curToken, "", "float literal %s out of range (must be at most %g)",
+			p.curToken.Literal, math.MaxFloat64)
+		return nil
+	}
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.errorAt(p.curToken, "", "could not parse %q as float", p.curToken.Literal)
 		return nil
 	}
 
@@ -462,6 +953,16 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	return lit
 }
 
+func (p *Parser) parseStringLiteral() ast.Expression {
+	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+}
+
+// ILLEGALトークンは字句解析の段階でエラーを報告済みなので，ここでは同期だけ行う
+func (p *Parser) parseIllegal() ast.Expression {
+	p.panicking = true
+	return nil
+}
+
 // 現在のトークンが引数のトークンと一致しているかどうかの等号演算
 func (p *Parser) curTokenIs(t token.TokenType) bool {
 	return p.curToken.Type == t
@@ -491,3 +992,28 @@ func (p *Parser) registerPrefix(tokenType token.TokenType, fn prefixParseFn) {
 func (p *Parser) registerInfix(tokenType token.TokenType, fn infixParseFn) {
 	p.infixParseFns[tokenType] = fn
 }
+
+// 代入式をパースする
+// 代入先は識別子か添字式でなければならない．右結合なので a = b = 1 は a = (b = 1) になる
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	expression := &ast.AssignExpression{
+		Token:    p.curToken,
+		Target:   target,
+		Operator: p.curToken.Literal,
+	}
+
+	switch target.(type) {
+	case *ast.Identifier, *ast.IndexExpression:
+	default:
+		p.errorAt(p.curToken, "", "cannot assign to %s", target)
+		return nil
+	}
+
+	p.nextToken()
+	expression.Value = p.parseExpression(LOWEST)
+	if expression.Value == nil {
+		return nil
+	}
+
+	return expression
+}
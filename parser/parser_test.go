@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"monkey/ast"
 	"monkey/lexer"
+	"monkey/token"
+	"strings"
 	"testing"
 )
 
@@ -41,6 +43,63 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestDocComments(t *testing.T) {
+	input := `// add は2つの数の和を返す
+// 引数は整数
+let add = fn(a, b) { a + b };
+
+// 空行を挟んだコメントはドキュメントにならない
+
+let x = 1; // 行末のコメントも次の文のドキュメントにはならない
+let y = 2;
+/* ブロックコメントも
+   ドキュメントになる */
+let z = /* 式の中のコメント */ 3;
+let f = fn() {
+	// 関数の中の let 文
+	let inner = 4;
+	inner
+};
+`
+
+	tests := []struct {
+		name        string
+		expectedDoc string
+	}{
+		{"add", "add は2つの数の和を返す\n引数は整数\n"},
+		{"x", ""},
+		{"y", ""},
+		{"z", "ブロックコメントも\n  ドキュメントになる\n"},
+		{"f", ""},
+	}
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != len(tests) {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d",
+			len(tests), len(program.Statements))
+	}
+
+	for i, tt := range tests {
+		stmt, ok := program.Statements[i].(*ast.LetStatement)
+		if !ok || stmt.Name.Value != tt.name {
+			t.Fatalf("program.Statements[%d] is not let %s. got=%s", i, tt.name, program.Statements[i])
+		}
+		if stmt.Doc.Text() != tt.expectedDoc {
+			t.Errorf("doc comment of %s wrong. expected=%q, got=%q", tt.name, tt.expectedDoc, stmt.Doc.Text())
+		}
+	}
+
+	body := program.Statements[4].(*ast.LetStatement).Value.(*ast.FunctionLiteral).Body
+	inner := body.Statements[0].(*ast.LetStatement)
+	if inner.Doc.Text() != "関数の中の let 文\n" {
+		t.Errorf("doc comment of inner wrong. got=%q", inner.Doc.Text())
+	}
+}
+
 func TestReturnStatements(t *testing.T) {
 	tests := []struct {
 		input         string
@@ -390,6 +449,37 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a + b + c * d / f + g)",
 			"add((((a + b) + ((c * d) / f)) + g))",
 		},
+		{
+			"a * [1, 2, 3, 4][b * c] * d",
+			"((a * ([1, 2, 3, 4][(b * c)])) * d)",
+		},
+		{
+			"add(a * b[2], b[1], 2 * [1, 2][1])",
+			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
+		},
+		{
+			"-a[1:2]",
+			"(-(a[1:2]))",
+		},
+		{"a % b * c", "((a % b) * c)"},
+		{"a + b % c", "(a + (b % c))"},
+		{"a <= b == c >= d", "((a <= b) == (c >= d))"},
+		{"a || b && c", "(a || (b && c))"},
+		{"a && b || c && d", "((a && b) || (c && d))"},
+		{"a == b && c != d", "((a == b) && (c != d))"},
+		{"!a && b", "((!a) && b)"},
+		{"a | b ^ c & d", "(a | (b ^ (c & d)))"},
+		{"x & 1 == 0", "((x & 1) == 0)"},
+		{"a << 1 + b", "(a << (1 + b))"},
+		{"a >> b << c", "((a >> b) << c)"},
+		{"a | b < c", "((a | b) < c)"},
+		{"~a & b", "((~a) & b)"},
+		{"2 ** 3 ** 2", "(2 ** (3 ** 2))"},
+		{"-2 ** 2", "(-(2 ** 2))"},
+		{"2 ** -1", "(2 ** (-1))"},
+		{"a * b ** c", "(a * (b ** c))"},
+		{"a ** b[0]", "(a ** (b[0]))"},
+		{"f(x) ** 2", "(f(x) ** 2)"},
 	}
 
 	for _, tt := range tests {
@@ -577,6 +667,173 @@ func TestIfExpression(t *testing.T) {
 	}
 }
 
+func TestWhileStatement(t *testing.T) {
+	input := `while (x < 10) { x += 1; }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.WhileStatement. got=%T",
+			program.Statements[0])
+	}
+
+	if !testInfixExpression(t, stmt.Condition, "x", "<", 10) {
+		return
+	}
+
+	if len(stmt.Body.Statements) != 1 {
+		t.Fatalf("body is not 1 statements. got=%d\n", len(stmt.Body.Statements))
+	}
+
+	body, ok := stmt.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Statements[0] is not ast.ExpressionStatement. got=%T",
+			stmt.Body.Statements[0])
+	}
+	if body.String() != "(x += 1)" {
+		t.Errorf("body.String() wrong. got=%q", body.String())
+	}
+}
+
+func TestForStatement(t *testing.T) {
+	input := `for (item in range(0, 10)) { puts(item); }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ForStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ForStatement. got=%T",
+			program.Statements[0])
+	}
+
+	if !testIdentifier(t, stmt.Variable, "item") {
+		return
+	}
+
+	call, ok := stmt.Iterable.(*ast.CallExpression)
+	if !ok {
+		t.Fatalf("stmt.Iterable is not ast.CallExpression. got=%T", stmt.Iterable)
+	}
+	if !testIdentifier(t, call.Function, "range") {
+		return
+	}
+
+	if len(stmt.Body.Statements) != 1 {
+		t.Fatalf("body is not 1 statements. got=%d\n", len(stmt.Body.Statements))
+	}
+
+	expected := "for(item in range(0, 10)) puts(item)"
+	if program.String() != expected {
+		t.Errorf("program.String() wrong. expected=%q, got=%q", expected, program.String())
+	}
+}
+
+func TestLoopTrailingSemicolon(t *testing.T) {
+	// let 文などと同じく，ループの後ろの ; は省略できる
+	tests := []struct {
+		input              string
+		expectedStatements int
+	}{
+		{"while (false) {};", 1},
+		{"for (x in []) {};", 1},
+		{"while (false) {}; for (x in []) {}; 1", 3},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != tt.expectedStatements {
+			t.Errorf("%q: wrong number of statements. want=%d, got=%d",
+				tt.input, tt.expectedStatements, len(program.Statements))
+		}
+	}
+}
+
+func TestBreakAndContinue(t *testing.T) {
+	input := `
+while (true) {
+  if (x) { break; }
+  for (y in ys) {
+    continue
+  }
+  break
+}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.WhileStatement)
+	if len(stmt.Body.Statements) != 3 {
+		t.Fatalf("body is not 3 statements. got=%d\n", len(stmt.Body.Statements))
+	}
+
+	ifExp := stmt.Body.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.IfExpression)
+	if _, ok := ifExp.Consequence.Statements[0].(*ast.BreakStatement); !ok {
+		t.Errorf("consequence is not ast.BreakStatement. got=%T", ifExp.Consequence.Statements[0])
+	}
+
+	forStmt := stmt.Body.Statements[1].(*ast.ForStatement)
+	if _, ok := forStmt.Body.Statements[0].(*ast.ContinueStatement); !ok {
+		t.Errorf("for body is not ast.ContinueStatement. got=%T", forStmt.Body.Statements[0])
+	}
+
+	if _, ok := stmt.Body.Statements[2].(*ast.BreakStatement); !ok {
+		t.Errorf("body[2] is not ast.BreakStatement. got=%T", stmt.Body.Statements[2])
+	}
+}
+
+func TestLoopErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"break;", "1:1: break is not in a loop"},
+		{"if (x) { continue; }", "1:10: continue is not in a loop"},
+		// 関数の本体から外側のループは抜けられない
+		{"while (true) { let f = fn() { break; }; }", "1:31: break is not in a loop"},
+		{"for (1 in xs) { }", "1:6: expected next token to be IDENT, got INT instead"},
+		{"for (x of xs) { }", "1:8: expected next token to be IN, got IDENT instead"},
+		{"while true { }", "1:7: expected next token to be (, got TRUE instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 {
+			t.Errorf("expected 1 error for %q, got=%d: %v", tt.input, len(errors), errors)
+			continue
+		}
+		if errors[0] != tt.expected {
+			t.Errorf("wrong error for %q. expected=%q, got=%q", tt.input, tt.expected, errors[0])
+		}
+	}
+}
+
 func TestIfElseExpression(t *testing.T) {
 	input := `if (x < y) { x } else { y }`
 
@@ -636,6 +893,57 @@ func TestIfElseExpression(t *testing.T) {
 	}
 }
 
+func TestMacroLiteralParsing(t *testing.T) {
+	input := `macro(x, y) { x + y; }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+			program.Statements[0])
+	}
+
+	macro, ok := stmt.Expression.(*ast.MacroLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.MacroLiteral. got=%T",
+			stmt.Expression)
+	}
+
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("macro literal parameters wrong. want 2, got=%d\n",
+			len(macro.Parameters))
+	}
+
+	testLiteralExpression(t, macro.Parameters[0], "x")
+	testLiteralExpression(t, macro.Parameters[1], "y")
+
+	if len(macro.Body.Statements) != 1 {
+		t.Fatalf("macro.Body.Statements has not 1 statements. got=%d\n",
+			len(macro.Body.Statements))
+	}
+
+	bodyStmt, ok := macro.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("macro body stmt is not ast.ExpressionStatement. got=%T",
+			macro.Body.Statements[0])
+	}
+
+	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
+
+	if macro.String() != "macro(x, y) (x + y)" {
+		t.Errorf("macro.String() wrong. got=%q", macro.String())
+	}
+}
+
 func TestFunctionLiteralParsing(t *testing.T) {
 	input := `fn(x, y) { x + y; }`
 
@@ -750,3 +1058,640 @@ func TestCallExpressionParsing(t *testing.T) {
 	testInfixExpression(t, exp.Arguments[1], 2, "*", 3)
 	testInfixExpression(t, exp.Arguments[2], 4, "+", 5)
 }
+
+func TestNodeSpans(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedStart int
+		expectedEnd   int
+	}{
+		{"foobar;", 0, 6},
+		{"  1 + 2 * 3", 2, 11},
+		{"let x = -a;", 0, 10},
+		{"return add(1, 2);", 0, 16},
+		{"if (x) { y } else { z }", 0, 23},
+		{"fn(x, y) { x + y; }", 0, 19},
+		{"add(1, fn() {})", 0, 15},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0]
+		if stmt.Pos().Offset != tt.expectedStart || stmt.End().Offset != tt.expectedEnd {
+			t.Errorf("wrong span for %q. expected=[%d, %d), got=[%d, %d)", tt.input,
+				tt.expectedStart, tt.expectedEnd, stmt.Pos().Offset, stmt.End().Offset)
+		}
+	}
+}
+
+func TestIntegerLiteralBases(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"0x1F", 31},
+		{"0XFF", 255},
+		{"0o17", 15},
+		{"017", 15},
+		{"0b1010", 10},
+		{"1_000_000", 1000000},
+		{"0x_dead_beef", 0xdeadbeef},
+		{"0", 0},
+		{"9223372036854775807", 9223372036854775807},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.IntegerLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value wrong for %q. expected=%d, got=%d", tt.input, tt.expected, literal.Value)
+		}
+		if literal.TokenLiteral() != tt.input {
+			t.Errorf("literal.TokenLiteral wrong. expected=%q, got=%q", tt.input, literal.TokenLiteral())
+		}
+	}
+}
+
+func TestFloatLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14", 3.14},
+		{"1e-9", 1e-9},
+		{"1_000.5", 1000.5},
+		{"1e-400", 0},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.FloatLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.FloatLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value wrong for %q. expected=%g, got=%g", tt.input, tt.expected, literal.Value)
+		}
+		if literal.String() != tt.input {
+			t.Errorf("literal.String() wrong. expected=%q, got=%q", tt.input, literal.String())
+		}
+	}
+
+	p := New(lexer.New("1e400"))
+	p.ParseProgram()
+	expected := "1:1: float literal 1e400 out of range (must be at most 1.7976931348623157e+308)"
+	if len(p.Errors()) != 1 || p.Errors()[0] != expected {
+		t.Errorf("wrong errors for 1e400. expected=%q, got=%q", expected, p.Errors())
+	}
+}
+
+func TestBigIntLiteralExpression(t *testing.T) {
+	// int64 に収まらない整数のリテラルは範囲外のエラーにならず，多倍長整数のリテラルになる
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775808", "9223372036854775808"},
+		{"0xFFFFFFFFFFFFFFFFF", "295147905179352825855"},
+		{"0o1777777777777777777777", "18446744073709551615"},
+		{"0b1" + strings.Repeat("0", 64), "18446744073709551616"},
+		{"1_000_000_000_000_000_000_000", "1000000000000000000000"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.BigIntLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.BigIntLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value.String() != tt.expected {
+			t.Errorf("literal.Value wrong for %q. expected=%s, got=%s", tt.input, tt.expected, literal.Value)
+		}
+		if literal.String() != tt.input {
+			t.Errorf("literal.String() wrong. expected=%q, got=%q", tt.input, literal.String())
+		}
+		// 位置はリテラル全体を指す
+		if literal.Pos().Column != 1 || literal.End().Column != len(tt.input)+1 {
+			t.Errorf("literal span wrong for %q. got=%s-%s", tt.input, literal.Pos(), literal.End())
+		}
+	}
+
+	// -9223372036854775808 は 9223372036854775808 に "-" を付けた式になる
+	l := lexer.New("-9223372036854775808")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	prefix, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.PrefixExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.PrefixExpression. got=%T", program.Statements[0])
+	}
+	if _, ok := prefix.Right.(*ast.BigIntLiteral); !ok {
+		t.Errorf("prefix.Right not *ast.BigIntLiteral. got=%T", prefix.Right)
+	}
+}
+
+// int64 の範囲外の値はエラーにならず BigInt のリテラルになる (TestBigIntLiteralExpression)
+func TestIntegerLiteralErrors(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    string
+		expectedEnd int
+	}{
+		{"0b102", "1:5: invalid digit '2' in binary literal", 6},
+		{"0o8", "1:3: invalid digit '8' in octal literal", 4},
+		{"089", "1:2: invalid digit '8' in octal literal", 4},
+		{"0xG", "1:3: invalid digit 'G' in hexadecimal literal", 4},
+		{"12abc", "1:3: invalid digit 'a' in decimal literal", 6},
+		{"0x", "1:1: hexadecimal literal has no digits", 3},
+		{"1__000", "1:3: '_' must separate successive digits", 7},
+		{"1000_", "1:5: '_' must separate successive digits", 6},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		diagnostics := p.Diagnostics()
+		if len(diagnostics) != 1 {
+			t.Errorf("expected 1 diagnostic for %q, got=%d: %v", tt.input, len(diagnostics), p.Errors())
+			continue
+		}
+		if diagnostics[0].String() != tt.expected {
+			t.Errorf("wrong error for %q.\nexpected=%q\ngot=     %q", tt.input, tt.expected, diagnostics[0].String())
+		}
+		if diagnostics[0].End.Column != tt.expectedEnd {
+			t.Errorf("wrong error end for %q. expected=%d, got=%d", tt.input, tt.expectedEnd, diagnostics[0].End.Column)
+		}
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x = 5", "(x = 5)"},
+		{"x += 1 * 2", "(x += (1 * 2))"},
+		{"x **= 2", "(x **= 2)"},
+		{"x <<= 1", "(x <<= 1)"},
+		// 代入は右結合
+		{"a = b = c", "(a = (b = c))"},
+		{"a -= b |= c", "(a -= (b |= c))"},
+		// 代入は論理演算子よりも弱く結合する
+		{"x = a || b && c", "(x = (a || (b && c)))"},
+		{"arr[i + 1] = v", "((arr[(i + 1)]) = v)"},
+		{`h["k"] %= 2`, `((h["k"]) %= 2)`},
+		{"f(x = 1)", "f((x = 1))"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		actual := program.String()
+		if actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+
+	l := lexer.New("x += 1")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.AssignExpression)
+	if !ok {
+		t.Fatalf("exp is not ast.AssignExpression. got=%T", stmt.Expression)
+	}
+	if !testIdentifier(t, exp.Target, "x") {
+		return
+	}
+	if exp.Operator != "+=" {
+		t.Errorf("exp.Operator is not %q. got=%q", "+=", exp.Operator)
+	}
+	if !testIntegerLiteral(t, exp.Value, 1) {
+		return
+	}
+}
+
+func TestInvalidAssignTargets(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"5 = 1", "1:3: cannot assign to 5"},
+		{"f() = 1", "1:5: cannot assign to f()"},
+		{"a + b = 1", "1:7: cannot assign to (a + b)"},
+		{"x = 1 += 2", "1:7: cannot assign to 1"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 {
+			t.Errorf("expected 1 error for %q, got=%d: %v", tt.input, len(errors), errors)
+			continue
+		}
+		if errors[0] != tt.expected {
+			t.Errorf("wrong error for %q. expected=%q, got=%q", tt.input, tt.expected, errors[0])
+		}
+	}
+}
+
+func TestParserErrorPosition(t *testing.T) {
+	l := lexer.NewFile("main.mk", "let x = 1;\nlet = 5;")
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) == 0 {
+		t.Fatalf("expected parser errors, got none")
+	}
+
+	expected := "main.mk:2:5: expected next token to be IDENT, got = instead"
+	if errors[0] != expected {
+		t.Errorf("wrong error message. expected=%q, got=%q", expected, errors[0])
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input              string
+		expectedErrors     []string
+		expectedStatements int
+	}{
+		// 1つの書き間違いからは1つのエラーだけが報告される
+		{
+			"let = 5; let y = 10;",
+			[]string{"1:5: expected next token to be IDENT, got = instead"},
+			1,
+		},
+		{
+			"let x 5 * 3 + 2; x;",
+			[]string{"1:7: expected next token to be =, got INT instead"},
+			1,
+		},
+		{
+			"let a = 1; let b 2; let c = 3; let 4;",
+			[]string{
+				"1:18: expected next token to be =, got INT instead",
+				"1:36: expected next token to be IDENT, got INT instead",
+			},
+			2,
+		},
+		// ブロックの中のエラーは "}" の手前で同期する
+		{
+			"let f = fn() { let = 1; 2 }; f();",
+			[]string{"1:20: expected next token to be IDENT, got = instead"},
+			2,
+		},
+		{
+			"if (x) { y",
+			[]string{"1:11: expected } to close the block, got EOF instead"},
+			0,
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != len(tt.expectedErrors) {
+			t.Errorf("wrong number of errors for %q. expected=%q, got=%q",
+				tt.input, tt.expectedErrors, errors)
+			continue
+		}
+		for i, msg := range tt.expectedErrors {
+			if errors[i] != msg {
+				t.Errorf("wrong error %d for %q. expected=%q, got=%q", i, tt.input, msg, errors[i])
+			}
+		}
+
+		if len(program.Statements) != tt.expectedStatements {
+			t.Errorf("wrong number of statements for %q. expected=%d, got=%d",
+				tt.input, tt.expectedStatements, len(program.Statements))
+		}
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	l := lexer.New("let x == 5;")
+	p := New(l)
+	p.ParseProgram()
+
+	diagnostics := p.Diagnostics()
+	if len(diagnostics) != 1 {
+		t.Fatalf("expected 1 diagnostic. got=%d (%+v)", len(diagnostics), diagnostics)
+	}
+
+	d := diagnostics[0]
+	if d.Severity != SeverityError {
+		t.Errorf("d.Severity not %s. got=%s", SeverityError, d.Severity)
+	}
+	if d.Expected != token.ASSIGN {
+		t.Errorf("d.Expected not %q. got=%q", token.ASSIGN, d.Expected)
+	}
+	if d.Found.Type != token.EQ {
+		t.Errorf("d.Found.Type not %q. got=%q", token.EQ, d.Found.Type)
+	}
+	if d.Pos.Offset != 6 || d.End.Offset != 8 {
+		t.Errorf("wrong span. expected=[6, 8), got=[%d, %d)", d.Pos.Offset, d.End.Offset)
+	}
+	if d.Hint == "" {
+		t.Errorf("d.Hint is empty")
+	}
+}
+
+func TestMaxErrors(t *testing.T) {
+	input := ""
+	for i := 0; i < MaxErrors+5; i++ {
+		input += "let 1;"
+	}
+
+	l := lexer.New(input)
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) != MaxErrors+1 {
+		t.Fatalf("expected %d errors. got=%d", MaxErrors+1, len(errors))
+	}
+	if !strings.HasSuffix(errors[MaxErrors], "too many errors") {
+		t.Errorf("last error is not 'too many errors'. got=%q", errors[MaxErrors])
+	}
+}
+
+func TestStringLiteralExpression(t *testing.T) {
+	input := `"hello world";`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.StringLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.StringLiteral. got=%T", stmt.Expression)
+	}
+
+	if literal.Value != "hello world" {
+		t.Errorf("literal.Value not %q. got=%q", "hello world", literal.Value)
+	}
+}
+
+func TestLexerErrorsAreReported(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`let s = "abc`, []string{"1:9: unterminated string literal"}},
+		{"let x = 5 @ 3; let y = 1;", []string{"1:11: illegal character '@'"}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != len(tt.expected) {
+			t.Errorf("wrong number of errors for %q. expected=%q, got=%q", tt.input, tt.expected, errors)
+			continue
+		}
+		for i, msg := range tt.expected {
+			if errors[i] != msg {
+				t.Errorf("wrong error for %q. expected=%q, got=%q", tt.input, msg, errors[i])
+			}
+		}
+	}
+}
+
+func TestParsingArrayLiterals(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("exp not ast.ArrayLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(array.Elements) != 3 {
+		t.Fatalf("len(array.Elements) not 3. got=%d", len(array.Elements))
+	}
+
+	testIntegerLiteral(t, array.Elements[0], 1)
+	testInfixExpression(t, array.Elements[1], 2, "*", 2)
+	testInfixExpression(t, array.Elements[2], 3, "+", 3)
+}
+
+func TestParsingEmptyArrayLiterals(t *testing.T) {
+	l := lexer.New("[]")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("exp not ast.ArrayLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(array.Elements) != 0 {
+		t.Errorf("len(array.Elements) not 0. got=%d", len(array.Elements))
+	}
+}
+
+func TestParsingIndexExpressions(t *testing.T) {
+	input := "myArray[1 + 1]"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	indexExp, ok := stmt.Expression.(*ast.IndexExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.IndexExpression. got=%T", stmt.Expression)
+	}
+
+	if !testIdentifier(t, indexExp.Left, "myArray") {
+		return
+	}
+
+	if !testInfixExpression(t, indexExp.Index, 1, "+", 1) {
+		return
+	}
+}
+
+func TestParsingSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedLow  interface{}
+		expectedHigh interface{}
+	}{
+		{"arr[1:3]", 1, 3},
+		{"arr[1:]", 1, nil},
+		{"arr[:3]", nil, 3},
+		{"arr[:]", nil, nil},
+		{"arr[lo:hi]", "lo", "hi"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		slice, ok := stmt.Expression.(*ast.SliceExpression)
+		if !ok {
+			t.Fatalf("exp not *ast.SliceExpression. got=%T", stmt.Expression)
+		}
+
+		testIdentifier(t, slice.Left, "arr")
+
+		if tt.expectedLow == nil {
+			if slice.Low != nil {
+				t.Errorf("slice.Low not nil. got=%s", slice.Low)
+			}
+		} else {
+			testLiteralExpression(t, slice.Low, tt.expectedLow)
+		}
+
+		if tt.expectedHigh == nil {
+			if slice.High != nil {
+				t.Errorf("slice.High not nil. got=%s", slice.High)
+			}
+		} else {
+			testLiteralExpression(t, slice.High, tt.expectedHigh)
+		}
+	}
+}
+
+func TestParsingHashLiteralsStringKeys(t *testing.T) {
+	input := `{"one": 1, "two": 2, "three": 3}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+
+	expected := []struct {
+		key   string
+		value int64
+	}{
+		{"one", 1},
+		{"two", 2},
+		{"three", 3},
+	}
+
+	if len(hash.Pairs) != len(expected) {
+		t.Fatalf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+
+	// ペアはソースコードに書かれた順に並んでいる
+	for i, pair := range hash.Pairs {
+		literal, ok := pair.Key.(*ast.StringLiteral)
+		if !ok {
+			t.Errorf("key is not ast.StringLiteral. got=%T", pair.Key)
+			continue
+		}
+
+		if literal.Value != expected[i].key {
+			t.Errorf("key %d wrong. expected=%q, got=%q", i, expected[i].key, literal.Value)
+		}
+
+		testIntegerLiteral(t, pair.Value, expected[i].value)
+	}
+}
+
+func TestParsingEmptyHashLiteral(t *testing.T) {
+	l := lexer.New("{}")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(hash.Pairs) != 0 {
+		t.Errorf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+}
+
+func TestParsingHashLiteralsWithExpressions(t *testing.T) {
+	input := `{"one": 0 + 1, 2: 10 - 8, true: 15 / 5}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(hash.Pairs) != 3 {
+		t.Fatalf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+
+	testInfixExpression(t, hash.Pairs[0].Value, 0, "+", 1)
+	testIntegerLiteral(t, hash.Pairs[1].Key, 2)
+	testInfixExpression(t, hash.Pairs[1].Value, 10, "-", 8)
+	testBooleanLiteral(t, hash.Pairs[2].Key, true)
+	testInfixExpression(t, hash.Pairs[2].Value, 15, "/", 5)
+
+	if hash.String() != `{"one": (0 + 1), 2: (10 - 8), true: (15 / 5)}` {
+		t.Errorf("hash.String() wrong. got=%q", hash.String())
+	}
+}
@@ -0,0 +1,179 @@
+package repl
+
+import (
+	"fmt"
+	"io"
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/token"
+	"os"
+	"strings"
+	"time"
+)
+
+// コロンで始まる REPL のメタコマンド
+// run は引数(コマンド名より後ろの文字列)を受け取り，REPL を終了する場合は true を返す
+type command struct {
+	name  string
+	args  string
+	usage string
+	run   func(s *session, arg string) bool
+}
+
+// :help が commands を参照するので，初期化の循環を避けるために init で設定する
+var commands []command
+
+func init() {
+	commands = []command{
+		{"tokens", "<source>", "print the tokens produced by the lexer", (*session).tokens},
+		{"ast", "<source>", "print the parsed syntax tree", (*session).ast},
+		{"expand", "<source>", "print the source after macro expansion", (*session).expand},
+		{"env", "", "list the bindings in the session", (*session).env},
+		{"load", "<file>", "evaluate a file into the session", (*session).load},
+		{"reset", "", "clear all bindings and macros in the session", (*session).reset},
+		{"time", "<source>", "evaluate the source and report how long it took", (*session).time},
+		{"help", "", "list the available commands", (*session).help},
+		{"quit", "", "exit the REPL", (*session).quit},
+	}
+}
+
+func lookupCommand(name string) (command, bool) {
+	for _, c := range commands {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return command{}, false
+}
+
+// ":name arg" の形式の行をメタコマンドとして実行する
+// REPL を終了する場合は true を返す
+func (s *session) runCommand(line string) bool {
+	name, arg, _ := strings.Cut(strings.TrimPrefix(line, ":"), " ")
+	arg = strings.TrimSpace(arg)
+
+	c, ok := lookupCommand(name)
+	if !ok {
+		fmt.Fprintf(s.out, "unknown command :%s (type :help for a list of commands)\n", name)
+		return false
+	}
+	return c.run(s, arg)
+}
+
+// 字句解析の結果を1トークン1行で表示する
+// コメントも COMMENT トークンとして表示する
+func (s *session) tokens(arg string) bool {
+	l := lexer.New(arg)
+	l.SetMode(lexer.ScanComments)
+	for {
+		tok := l.NextToken()
+		fmt.Fprintf(s.out, "%-8s %-10s %q\n", tok.Pos, tok.Type, tok.Literal)
+		if tok.Type == token.EOF {
+			break
+		}
+	}
+	for _, err := range l.Errors() {
+		fmt.Fprintf(s.out, "lexer error: %s\n", err)
+	}
+	return false
+}
+
+// 構文木をノードの型と位置付きで表示する
+func (s *session) ast(arg string) bool {
+	p := parser.New(lexer.New(arg))
+	program := p.ParseProgram()
+	if len(p.Diagnostics()) != 0 {
+		printParserErrors(s.out, p.Diagnostics())
+		return false
+	}
+
+	ast.Fprint(s.out, program)
+	return false
+}
+
+// マクロを展開したあとのプログラムを表示する
+// 入力中で定義したマクロも使えるが，セッションには残さない
+func (s *session) expand(arg string) bool {
+	p := parser.New(lexer.New(arg))
+	program := p.ParseProgram()
+	if len(p.Diagnostics()) != 0 {
+		printParserErrors(s.out, p.Diagnostics())
+		return false
+	}
+
+	env := object.NewEnclosedEnvironment(s.macroEnv)
+	evaluator.DefineMacros(program, env)
+	expanded, errObj := evaluator.ExpandMacros(program, env)
+	if errObj != nil {
+		printRuntimeError(s.out, errObj)
+		return false
+	}
+
+	fmt.Fprintf(s.out, "%s\n", expanded.String())
+	return false
+}
+
+// セッションの環境に束縛されている名前を "名前: 型 = 値" の形式で表示する
+// 関数のように値の表示が複数行にわたる場合は1行にまとめる
+func (s *session) env(string) bool {
+	for _, name := range s.environment.Names() {
+		val, _ := s.environment.Get(name)
+		inspected := strings.Join(strings.Fields(val.Inspect()), " ")
+		fmt.Fprintf(s.out, "%s: %s = %s\n", name, val.Type(), inspected)
+	}
+	return false
+}
+
+// ファイルを読み込んでセッションの環境で評価する
+// ファイルで定義した変数や関数は，その後の入力から参照できる
+func (s *session) load(arg string) bool {
+	if arg == "" {
+		io.WriteString(s.out, "usage: :load <file>\n")
+		return false
+	}
+
+	src, err := os.ReadFile(arg)
+	if err != nil {
+		fmt.Fprintf(s.out, "%s\n", err)
+		return false
+	}
+
+	if _, ok := s.eval(lexer.NewFile(arg, string(src))); ok {
+		fmt.Fprintf(s.out, "loaded %s\n", arg)
+	}
+	return false
+}
+
+// 環境を作り直して，それまでの束縛とマクロをすべて破棄する
+func (s *session) reset(string) bool {
+	s.environment = object.NewEnvironment()
+	s.macroEnv = object.NewEnvironment()
+	io.WriteString(s.out, "environment cleared\n")
+	return false
+}
+
+// 入力を評価して結果を表示し，構文解析と評価にかかった時間を報告する
+func (s *session) time(arg string) bool {
+	start := time.Now()
+	s.evalInput(arg)
+	fmt.Fprintf(s.out, "time: %s\n", time.Since(start))
+	return false
+}
+
+func (s *session) help(string) bool {
+	for _, c := range commands {
+		usage := ":" + c.name
+		if c.args != "" {
+			usage += " " + c.args
+		}
+		fmt.Fprintf(s.out, "  %-18s %s\n", usage, c.usage)
+	}
+	return false
+}
+
+func (s *session) quit(string) bool {
+	return true
+}
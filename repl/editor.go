@@ -0,0 +1,406 @@
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Ctrl-C で入力中の行を破棄したときに readLine が返すエラー
+var errInterrupted = errors.New("interrupted")
+
+// キーの制御文字
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyCtrlG     = 7
+	keyCtrlH     = 8
+	keyTab       = 9
+	keyLF        = 10
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyCR        = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlR     = 18
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyEscape    = 27
+	keyBackspace = 127
+)
+
+// エスケープシーケンスで送られてくるキー
+// 制御文字と重ならないように Unicode の私用領域の値を割り当てる
+const (
+	keyUp rune = 0xE000 + iota
+	keyDown
+	keyRight
+	keyLeft
+	keyHome
+	keyEnd
+	keyDelete
+	keyUnknown
+)
+
+// 端末の raw モードで1行を編集する行エディタ
+// 端末の制御は ANSI エスケープシーケンスだけで行うので，in と out を差し替えればテストできる
+type lineEditor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	history  *history
+	complete func() []string // 補完候補の一覧を返す
+
+	prompt string
+	buf    []rune // 編集中の行
+	pos    int    // カーソルの位置(buf の添字)
+}
+
+func newLineEditor(in io.Reader, out io.Writer, h *history, complete func() []string) *lineEditor {
+	return &lineEditor{
+		in:       bufio.NewReader(in),
+		out:      out,
+		history:  h,
+		complete: complete,
+	}
+}
+
+// プロンプトを表示して1行を読む
+// Enter で確定した行を返す．空の行で Ctrl-D を押すと io.EOF を，Ctrl-C を押すと errInterrupted を返す
+func (e *lineEditor) readLine(prompt string) (string, error) {
+	e.prompt = prompt
+	e.buf = e.buf[:0]
+	e.pos = 0
+
+	// 履歴をたどっている間の位置．len(entries) は編集中の新しい行を指す
+	index := len(e.history.entries)
+	// 履歴をたどる前に編集していた行
+	var editing []rune
+
+	// Ctrl-R の検索を抜けたキーで，次に処理するもの
+	next := rune(-1)
+
+	e.refresh()
+
+	for {
+		key := next
+		next = -1
+		if key < 0 {
+			var err error
+			if key, err = e.readKey(); err != nil {
+				return "", err
+			}
+		}
+
+		switch key {
+		case keyCR, keyLF:
+			line := string(e.buf)
+			io.WriteString(e.out, "\r\n")
+			e.history.add(line)
+			return line, nil
+		case keyCtrlC:
+			io.WriteString(e.out, "^C\r\n")
+			return "", errInterrupted
+		case keyCtrlD:
+			if len(e.buf) == 0 {
+				io.WriteString(e.out, "\r\n")
+				return "", io.EOF
+			}
+			e.deleteAt(e.pos)
+		case keyBackspace, keyCtrlH:
+			if e.pos > 0 {
+				e.pos--
+				e.deleteAt(e.pos)
+			}
+		case keyDelete:
+			e.deleteAt(e.pos)
+		case keyCtrlA, keyHome:
+			e.pos = 0
+		case keyCtrlE, keyEnd:
+			e.pos = len(e.buf)
+		case keyCtrlB, keyLeft:
+			if e.pos > 0 {
+				e.pos--
+			}
+		case keyCtrlF, keyRight:
+			if e.pos < len(e.buf) {
+				e.pos++
+			}
+		case keyCtrlK:
+			e.buf = e.buf[:e.pos]
+		case keyCtrlU:
+			e.buf = append(e.buf[:0], e.buf[e.pos:]...)
+			e.pos = 0
+		case keyCtrlW:
+			// カーソルの直前の空白と，その前の単語を削除する
+			start := e.pos
+			for start > 0 && unicode.IsSpace(e.buf[start-1]) {
+				start--
+			}
+			for start > 0 && !unicode.IsSpace(e.buf[start-1]) {
+				start--
+			}
+			e.buf = append(e.buf[:start], e.buf[e.pos:]...)
+			e.pos = start
+		case keyCtrlL:
+			io.WriteString(e.out, "\x1b[H\x1b[2J")
+		case keyCtrlP, keyUp:
+			if index > 0 {
+				if index == len(e.history.entries) {
+					editing = append(editing[:0], e.buf...)
+				}
+				index--
+				e.setLine(e.history.entries[index])
+			}
+		case keyCtrlN, keyDown:
+			if index < len(e.history.entries) {
+				index++
+				if index == len(e.history.entries) {
+					e.setLine(string(editing))
+				} else {
+					e.setLine(e.history.entries[index])
+				}
+			}
+		case keyTab:
+			e.completeWord()
+		case keyCtrlR:
+			// 検索を抜けたキーは，見つかった行に対する通常の編集として処理する
+			var err error
+			if next, err = e.search(); err != nil {
+				return "", err
+			}
+		default:
+			if unicode.IsPrint(key) {
+				e.insert(key)
+			}
+		}
+
+		e.refresh()
+	}
+}
+
+// 1キー分の入力を読む
+// 矢印キーなどのエスケープシーケンスは1つのキーにまとめる
+func (e *lineEditor) readKey() (rune, error) {
+	r, _, err := e.in.ReadRune()
+	if err != nil || r != keyEscape {
+		return r, err
+	}
+
+	// ESC [ X や ESC O X の形式のシーケンス
+	r, _, err = e.in.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	if r != '[' && r != 'O' {
+		return keyUnknown, nil
+	}
+
+	r, _, err = e.in.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	switch r {
+	case 'A':
+		return keyUp, nil
+	case 'B':
+		return keyDown, nil
+	case 'C':
+		return keyRight, nil
+	case 'D':
+		return keyLeft, nil
+	case 'H':
+		return keyHome, nil
+	case 'F':
+		return keyEnd, nil
+	}
+
+	// ESC [ 3 ~ のように数字の後に '~' が続くシーケンス
+	if '0' <= r && r <= '9' {
+		param := []rune{r}
+		for {
+			r, _, err = e.in.ReadRune()
+			if err != nil {
+				return 0, err
+			}
+			if r < '0' || '9' < r {
+				break
+			}
+			param = append(param, r)
+		}
+		if r == '~' {
+			switch string(param) {
+			case "1", "7":
+				return keyHome, nil
+			case "3":
+				return keyDelete, nil
+			case "4", "8":
+				return keyEnd, nil
+			}
+		}
+	}
+	return keyUnknown, nil
+}
+
+// Ctrl-R による履歴の逆方向インクリメンタル検索
+// 文字を入力するごとに，それを含む最も新しい履歴を表示する．もう一度 Ctrl-R を押すとさらに古い履歴を探す
+// Ctrl-G で検索前の行に戻して -1 を返す
+// それ以外の制御キー(Enter など)は見つかった行を編集中の行にしたうえで，そのキーを返す
+func (e *lineEditor) search() (rune, error) {
+	original := string(e.buf)
+	var query []rune
+	index := len(e.history.entries)
+	match := ""
+	failed := false
+
+	find := func(from int) {
+		for i := from; i >= 0; i-- {
+			if i < len(e.history.entries) && strings.Contains(e.history.entries[i], string(query)) {
+				index = i
+				match = e.history.entries[i]
+				failed = false
+				return
+			}
+		}
+		failed = true
+	}
+
+	for {
+		label := "reverse-i-search"
+		if failed {
+			label = "failed reverse-i-search"
+		}
+		fmt.Fprintf(e.out, "\r(%s)`%s': %s\x1b[K", label, string(query), match)
+
+		key, err := e.readKey()
+		if err != nil {
+			return -1, err
+		}
+
+		switch {
+		case key == keyCtrlR:
+			if len(query) != 0 {
+				find(index - 1)
+			}
+		case key == keyBackspace || key == keyCtrlH:
+			if len(query) != 0 {
+				query = query[:len(query)-1]
+				index = len(e.history.entries)
+				match = ""
+				failed = false
+				if len(query) != 0 {
+					find(index - 1)
+				}
+			}
+		case key == keyCtrlG || key == keyCtrlC:
+			e.setLine(original)
+			return -1, nil
+		case unicode.IsPrint(key):
+			query = append(query, key)
+			find(index)
+		default:
+			e.setLine(match)
+			return key, nil
+		}
+	}
+}
+
+// カーソルの直前の単語を補完する
+// 候補が1つならそれに置き換え，複数なら共通の接頭辞まで補い，それ以上補えなければ候補を一覧表示する
+func (e *lineEditor) completeWord() {
+	start := e.wordStart(isIdentRune)
+	prefix := string(e.buf[start:e.pos])
+	if prefix == "" {
+		return
+	}
+
+	candidates := completions(prefix, e.complete())
+	if len(candidates) == 0 {
+		return
+	}
+
+	common := commonPrefix(candidates)
+	if len(candidates) == 1 || common != prefix {
+		for _, r := range []rune(common)[len([]rune(prefix)):] {
+			e.insert(r)
+		}
+		return
+	}
+
+	io.WriteString(e.out, "\r\n"+strings.Join(candidates, "  ")+"\r\n")
+}
+
+// カーソルより前で，条件を満たす文字が続く範囲の先頭の位置を返す
+func (e *lineEditor) wordStart(in func(rune) bool) int {
+	start := e.pos
+	for start > 0 && in(e.buf[start-1]) {
+		start--
+	}
+	return start
+}
+
+func (e *lineEditor) insert(r rune) {
+	e.buf = append(e.buf, 0)
+	copy(e.buf[e.pos+1:], e.buf[e.pos:])
+	e.buf[e.pos] = r
+	e.pos++
+}
+
+func (e *lineEditor) deleteAt(i int) {
+	if i < len(e.buf) {
+		e.buf = append(e.buf[:i], e.buf[i+1:]...)
+	}
+}
+
+func (e *lineEditor) setLine(line string) {
+	e.buf = append(e.buf[:0], []rune(line)...)
+	e.pos = len(e.buf)
+}
+
+// 行を書き直してカーソルを編集位置に合わせる
+func (e *lineEditor) refresh() {
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K", e.prompt, string(e.buf))
+	if n := len(e.buf) - e.pos; n > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dD", n)
+	}
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// names のうち prefix で始まるものを重複なく辞書順で返す
+func completions(prefix string, names []string) []string {
+	seen := make(map[string]bool)
+	var candidates []string
+	for _, name := range names {
+		if strings.HasPrefix(name, prefix) && !seen[name] {
+			seen[name] = true
+			candidates = append(candidates, name)
+		}
+	}
+	sort.Strings(candidates)
+	return candidates
+}
+
+// 単語に共通する接頭辞を返す
+// 多バイト文字の途中で切らないように rune 単位で比べる
+func commonPrefix(words []string) string {
+	prefix := []rune(words[0])
+	for _, w := range words[1:] {
+		r := []rune(w)
+		n := 0
+		for n < len(prefix) && n < len(r) && prefix[n] == r[n] {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+	return string(prefix)
+}
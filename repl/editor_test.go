@@ -0,0 +1,206 @@
+package repl
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"monkey/object"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLineEditor(t *testing.T) {
+	tests := []struct {
+		keys     string
+		expected string
+	}{
+		{"abc\r", "abc"},
+		{"abc\n", "abc"},
+		{"abc\x1b[D\x1b[DX\r", "aXbc"},
+		{"abc\x01X\x05Y\r", "XabcY"},
+		{"abc\x1b[HX\x1b[FY\r", "XabcY"},
+		{"abc\x02\x02\x06X\r", "abXc"},
+		{"abc\x7f\r", "ab"},
+		{"abc\x01\x7f\r", "abc"},
+		{"abc\x1b[D\x1b[D\x1b[3~\r", "ac"},
+		{"abc\x01\x04\r", "bc"},
+		{"abc\x1b[D\x0b\r", "ab"},
+		{"abc\x1b[D\x15\r", "c"},
+		{"let x = 1  \x17\r", "let x = "},
+		{"あい\x1b[Dう\r", "あうい"},
+		{"a\x1bxb\r", "ab"},
+	}
+
+	for _, tt := range tests {
+		e := newLineEditor(strings.NewReader(tt.keys), io.Discard, &history{}, nil)
+		line, err := e.readLine(PROMPT)
+		if err != nil {
+			t.Errorf("readLine(%q) returned error: %s", tt.keys, err)
+			continue
+		}
+		if line != tt.expected {
+			t.Errorf("readLine(%q) wrong. expected=%q, got=%q", tt.keys, tt.expected, line)
+		}
+	}
+}
+
+func TestLineEditorControlKeys(t *testing.T) {
+	tests := []struct {
+		keys     string
+		expected error
+	}{
+		{"\x04", io.EOF},
+		{"abc\x03", errInterrupted},
+		{"abc", io.EOF},
+	}
+
+	for _, tt := range tests {
+		e := newLineEditor(strings.NewReader(tt.keys), io.Discard, &history{}, nil)
+		if _, err := e.readLine(PROMPT); err != tt.expected {
+			t.Errorf("readLine(%q) returned wrong error. expected=%v, got=%v", tt.keys, tt.expected, err)
+		}
+	}
+}
+
+func TestLineEditorHistory(t *testing.T) {
+	tests := []struct {
+		keys     string
+		expected string
+	}{
+		{"\x1b[A\r", "x + 1"},
+		{"\x1b[A\x1b[A\r", "let x = 1"},
+		{"\x1b[A\x1b[A\x1b[A\r", "let x = 1"},
+		{"\x10\x10\x0e\r", "x + 1"},
+		{"new\x1b[A\x1b[B\r", "new"},
+		{"\x12let\r", "let x = 1"},
+		{"\x12x\r", "x + 1"},
+		{"\x12x\x12\r", "let x = 1"},
+		{"\x12x \x12\x7f\r", "x + 1"},
+		{"\x12let\x05!\r", "let x = 1!"},
+		{"ab\x12zzz\x07\r", "ab"},
+	}
+
+	for _, tt := range tests {
+		h := &history{entries: []string{"let x = 1", "x + 1"}}
+		e := newLineEditor(strings.NewReader(tt.keys), io.Discard, h, nil)
+		line, err := e.readLine(PROMPT)
+		if err != nil {
+			t.Errorf("readLine(%q) returned error: %s", tt.keys, err)
+			continue
+		}
+		if line != tt.expected {
+			t.Errorf("readLine(%q) wrong. expected=%q, got=%q", tt.keys, tt.expected, line)
+		}
+	}
+}
+
+func TestLineEditorCompletion(t *testing.T) {
+	s := &session{environment: object.NewEnvironment()}
+	s.environment.Set("counter", &object.Integer{Value: 1})
+	s.environment.Set("count_all", &object.Integer{Value: 2})
+
+	tests := []struct {
+		keys     string
+		expected string
+		listed   string
+	}{
+		{"put\t\r", "puts", ""},
+		{"ret\t\r", "return", ""},
+		{"pu\t\r", "pu", "push  puts"},
+		{"x + cou\t\r", "x + count", ""},
+		{"x + count\t\r", "x + count", "count_all  counter"},
+		{"le\t\r", "le", "len  let"},
+		{"zz\t\r", "zz", ""},
+		{"fi\x01\t\r", "fi", ""},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		e := newLineEditor(strings.NewReader(tt.keys), &out, &history{}, s.completionNames)
+		line, err := e.readLine(PROMPT)
+		if err != nil {
+			t.Errorf("readLine(%q) returned error: %s", tt.keys, err)
+			continue
+		}
+		if line != tt.expected {
+			t.Errorf("readLine(%q) wrong. expected=%q, got=%q", tt.keys, tt.expected, line)
+		}
+		if tt.listed != "" && !strings.Contains(out.String(), "\r\n"+tt.listed+"\r\n") {
+			t.Errorf("candidates %q not listed. got=%q", tt.listed, out.String())
+		}
+	}
+}
+
+func TestHistoryPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), HISTORY_FILE)
+
+	h := loadHistory(path)
+	for _, line := range []string{"let x = 1", "", "x", "x", "x + 1"} {
+		h.add(line)
+	}
+
+	expected := []string{"let x = 1", "x", "x + 1"}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("history file not written: %s", err)
+	}
+	if string(content) != strings.Join(expected, "\n")+"\n" {
+		t.Errorf("history file wrong. got=%q", content)
+	}
+
+	reloaded := loadHistory(path)
+	if strings.Join(reloaded.entries, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("reloaded history wrong. expected=%q, got=%q", expected, reloaded.entries)
+	}
+}
+
+func TestHistoryFileIsTrimmed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), HISTORY_FILE)
+
+	// 上限の2倍の行がある履歴ファイル
+	var content strings.Builder
+	for i := 0; i < 2*maxHistory; i++ {
+		fmt.Fprintf(&content, "old %d\n", i)
+	}
+	if err := os.WriteFile(path, []byte(content.String()), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	h := loadHistory(path)
+	if len(h.entries) != maxHistory || h.entries[0] != fmt.Sprintf("old %d", maxHistory) {
+		t.Fatalf("loaded history wrong. len=%d, first=%q", len(h.entries), h.entries[0])
+	}
+
+	// 次に追加したときに，保持している履歴だけで書き直す
+	h.add("new 1")
+	h.add("new 2")
+
+	lines := readHistoryFile(t, path)
+	if len(lines) != maxHistory+1 {
+		t.Fatalf("history file not trimmed. want=%d lines, got=%d", maxHistory+1, len(lines))
+	}
+	if lines[0] != fmt.Sprintf("old %d", maxHistory+1) || lines[len(lines)-2] != "new 1" || lines[len(lines)-1] != "new 2" {
+		t.Errorf("trimmed history file wrong. first=%q, last=%q", lines[0], lines[len(lines)-2:])
+	}
+
+	// 書き直した後の履歴も次のセッションに引き継がれる
+	reloaded := loadHistory(path)
+	if strings.Join(reloaded.entries, "\n") != strings.Join(lines[1:], "\n") {
+		t.Errorf("reloaded history wrong. got first=%q, len=%d", reloaded.entries[0], len(reloaded.entries))
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary history file left behind")
+	}
+}
+
+func readHistoryFile(t *testing.T, path string) []string {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("history file not readable: %s", err)
+	}
+	return strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+}
@@ -0,0 +1,108 @@
+package repl
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+)
+
+// 履歴ファイルの名前(ホームディレクトリに置く)
+const HISTORY_FILE = ".monkey_history"
+
+// 履歴として保持する行数の上限
+const maxHistory = 1000
+
+// 行エディタで入力した行の履歴
+// path が空でなければ，行を追加するたびにファイルにも書き足して次のセッションに引き継ぐ
+// ファイルは書き足すだけだと際限なく大きくなるので，上限の2倍を超えたら保持している履歴で書き直す
+type history struct {
+	entries   []string
+	path      string
+	fileLines int // 履歴ファイルの行数
+}
+
+// ファイルから履歴を読み込む
+// ファイルがまだ無い場合や読めない場合は空の履歴から始める
+func loadHistory(path string) *history {
+	h := &history{path: path}
+	if path == "" {
+		return h
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return h
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		h.fileLines++
+		h.append(scanner.Text())
+	}
+	return h
+}
+
+// ホームディレクトリの履歴ファイルのパスを返す
+func defaultHistoryPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, HISTORY_FILE)
+}
+
+// 行を履歴に追加する
+// 空の行と直前と同じ行は追加しない
+func (h *history) add(line string) {
+	if !h.append(line) || h.path == "" {
+		return
+	}
+
+	// 履歴を保存できなくても REPL は続けられるので，エラーは無視する
+	if h.fileLines >= 2*maxHistory {
+		h.rewrite()
+		return
+	}
+
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	if _, err := f.WriteString(line + "\n"); err == nil {
+		h.fileLines++
+	}
+}
+
+// 保持している履歴で履歴ファイルを書き直す
+// 書き込みの途中で失敗しても元のファイルが壊れないように，一時ファイルに書いてから置き換える
+func (h *history) rewrite() {
+	var buf bytes.Buffer
+	for _, entry := range h.entries {
+		buf.WriteString(entry + "\n")
+	}
+
+	tmp := h.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0600); err != nil {
+		return
+	}
+	if err := os.Rename(tmp, h.path); err != nil {
+		os.Remove(tmp)
+		return
+	}
+	h.fileLines = len(h.entries)
+}
+
+func (h *history) append(line string) bool {
+	if line == "" || (len(h.entries) != 0 && h.entries[len(h.entries)-1] == line) {
+		return false
+	}
+
+	h.entries = append(h.entries, line)
+	if len(h.entries) > maxHistory {
+		h.entries = h.entries[len(h.entries)-maxHistory:]
+	}
+	return true
+}
@@ -6,39 +6,196 @@ import (
 	"io"
 	"monkey/evaluator"
 	"monkey/lexer"
+	"monkey/object"
 	"monkey/parser"
+	"monkey/token"
+	"os"
+	"strings"
 )
 
 const PROMPT = ">> "
 
+// 入力が途中で終わっているときに，続きの入力を促すプロンプト
+const CONTINUATION_PROMPT = ".. "
+
+// REPL のセッションの状態
+// 環境はセッション全体で1つだけ作り，:reset で作り直す
+// マクロは値の環境とは別の環境に定義し，入力を評価する前に展開する
+type session struct {
+	out         io.Writer
+	environment *object.Environment
+	macroEnv    *object.Environment
+}
+
+// REPL を開始する
+// 環境はセッション全体で1つだけ作るので，前の行で let した変数を後の行から参照できる
+// 括弧が閉じていないなど入力が途中で終わっている場合は，続きの行を読んでからまとめて評価する
+// 続きの入力中に空行を2回続けて入力すると，それまでの入力を破棄する
+// コロンで始まる行は :help などのメタコマンドとして扱う
+// 入力が端末の場合は行エディタで読み，履歴と補完を使えるようにする
 func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
+	s := &session{out: out, environment: object.NewEnvironment(), macroEnv: object.NewEnvironment()}
+	evaluator.SetOutput(out)
+
+	var r lineReader
+	if f, ok := in.(*os.File); ok && isTerminal(int(f.Fd())) {
+		r = newTerminalReader(f, out, loadHistory(defaultHistoryPath()), s.completionNames)
+	} else {
+		r = &scanReader{scanner: bufio.NewScanner(in), out: out}
+	}
+
+	// 評価を保留している入力行
+	var pending []string
 
 	for {
-		fmt.Fprintf(out, PROMPT)
-		scanned := scanner.Scan()
+		prompt := PROMPT
+		if len(pending) != 0 {
+			prompt = CONTINUATION_PROMPT
+		}
 
-		if !scanned {
+		line, err := r.readLine(prompt)
+		if err == errInterrupted {
+			pending = nil
+			continue
+		}
+		if err != nil {
+			// 入力が途中のまま終わった場合は，そのまま評価してエラーを表示する
+			if len(pending) != 0 {
+				s.evalInput(strings.Join(pending, "\n"))
+			}
 			return
 		}
 
-		line := scanner.Text()
-		l := lexer.New(line)
-		p := parser.New(l)
+		if len(pending) == 0 && strings.TrimSpace(line) == "" {
+			continue
+		}
+		if len(pending) == 0 && strings.HasPrefix(strings.TrimSpace(line), ":") {
+			if s.runCommand(strings.TrimSpace(line)) {
+				return
+			}
+			continue
+		}
+		if len(pending) != 0 && line == "" && pending[len(pending)-1] == "" {
+			io.WriteString(out, "(input discarded)\n")
+			pending = nil
+			continue
+		}
+
+		pending = append(pending, line)
+		input := strings.Join(pending, "\n")
 
-		program := p.ParseProgram()
-		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+		if isIncomplete(input) {
 			continue
 		}
 
-		evaluated := evaluator.Eval(program)
-		if evaluated != nil {
-			io.WriteString(out, program.String())
-			io.WriteString(out, "\n")
+		pending = nil
+		s.evalInput(input)
+	}
+}
+
+// 補完の候補として，予約語と組み込み関数とセッションで束縛した名前を返す
+func (s *session) completionNames() []string {
+	names := token.Keywords()
+	names = append(names, evaluator.BuiltinNames()...)
+	return append(names, s.environment.Names()...)
+}
+
+// 入力を構文解析して評価し，結果を表示する
+func (s *session) evalInput(input string) {
+	evaluated, ok := s.eval(lexer.New(input))
+
+	// let 文は値を持たない(nil)ので何も表示しない
+	if ok && evaluated != nil {
+		io.WriteString(s.out, evaluated.Inspect())
+		io.WriteString(s.out, "\n")
+	}
+}
+
+// 字句解析器から読んだプログラムのマクロを展開し，セッションの環境で評価する
+// 構文エラーや実行時エラーはその場で表示し，ok として false を返す
+func (s *session) eval(l *lexer.Lexer) (evaluated object.Object, ok bool) {
+	p := parser.New(l)
+
+	program := p.ParseProgram()
+	if len(p.Diagnostics()) != 0 {
+		printParserErrors(s.out, p.Diagnostics())
+		return nil, false
+	}
+
+	evaluator.DefineMacros(program, s.macroEnv)
+	if _, errObj := evaluator.ExpandMacros(program, s.macroEnv); errObj != nil {
+		printRuntimeError(s.out, errObj)
+		return nil, false
+	}
+
+	evaluated = evaluator.Eval(program, s.environment)
+	if errObj, isErr := evaluated.(*object.Error); isErr {
+		printRuntimeError(s.out, errObj)
+		return nil, false
+	}
+	return evaluated, true
+}
+
+// REPL の入力を1行ずつ読む
+type lineReader interface {
+	readLine(prompt string) (string, error)
+}
+
+// 端末でない入力(パイプやファイル)をそのまま1行ずつ読む
+type scanReader struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+}
+
+func (r *scanReader) readLine(prompt string) (string, error) {
+	io.WriteString(r.out, prompt)
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return "", err
 		}
+		return "", io.EOF
+	}
+	return r.scanner.Text(), nil
+}
+
+// 端末からの入力を行エディタで読む
+// 1行を読む間だけ端末を raw モードにするので，評価中の puts の出力などは通常どおり表示される
+type terminalReader struct {
+	fd     int
+	editor *lineEditor
+}
+
+func newTerminalReader(f *os.File, out io.Writer, h *history, complete func() []string) *terminalReader {
+	return &terminalReader{fd: int(f.Fd()), editor: newLineEditor(f, out, h, complete)}
+}
 
+func (r *terminalReader) readLine(prompt string) (string, error) {
+	restore, err := makeRaw(r.fd)
+	if err != nil {
+		return "", err
 	}
+	defer restore()
+	return r.editor.readLine(prompt)
+}
+
+// 入力が途中で終わっているかどうかを判定する
+// 構文解析の最初のエラーが入力の終端で起きている場合(閉じていない括弧，末尾の演算子など)や，
+// 文字列リテラルが閉じていない場合は，続きを入力すれば正しい入力になりうるとみなす
+func isIncomplete(input string) bool {
+	l := lexer.New(input)
+	p := parser.New(l)
+	p.ParseProgram()
+
+	diagnostics := p.Diagnostics()
+	if len(diagnostics) == 0 {
+		return false
+	}
+
+	found := diagnostics[0].Found
+	if found.Type == token.EOF {
+		return true
+	}
+	return found.Type == token.ILLEGAL && strings.HasPrefix(found.Literal, `"`)
 }
 
 const MONKEY_FACE = `            __,__
@@ -54,11 +211,24 @@ const MONKEY_FACE = `            __,__
            '-----'
 `
 
-func printParserErrors(out io.Writer, errors []string) {
+func printParserErrors(out io.Writer, diagnostics []parser.Diagnostic) {
 	io.WriteString(out, MONKEY_FACE)
 	io.WriteString(out, "Woops! We ran into some monkey business here!\n")
 	io.WriteString(out, " parser errors:\n")
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
+	for _, d := range diagnostics {
+		io.WriteString(out, "\t"+d.String()+"\n")
+		if d.Hint != "" {
+			io.WriteString(out, "\t  hint: "+d.Hint+"\n")
+		}
+	}
+}
+
+// 実行時エラーは値と区別できるように，位置とスタックトレースを付けて表示する
+func printRuntimeError(out io.Writer, err *object.Error) {
+	io.WriteString(out, "runtime error: ")
+	if err.Pos.IsValid() {
+		fmt.Fprintf(out, "%s: ", err.Pos)
 	}
+	io.WriteString(out, err.Message+"\n")
+	io.WriteString(out, err.StackTrace())
 }
@@ -0,0 +1,224 @@
+package repl
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStartPrintsEvaluatedValues(t *testing.T) {
+	input := `let x = 5;
+x * 2
+let add = fn(a, b) { a + b };
+add(x, 1)
+"hello"
+[1, 2][0]
+`
+
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+
+	expected := PROMPT + PROMPT + "10\n" + PROMPT + PROMPT + "6\n" + PROMPT + "hello\n" +
+		PROMPT + "1\n" + PROMPT
+	if out.String() != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=     %q", expected, out.String())
+	}
+}
+
+func TestStartPrintsErrorsDistinctly(t *testing.T) {
+	input := `let x = ;
+x
+5 + true
+1
+`
+
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+	output := out.String()
+
+	if !strings.Contains(output, " parser errors:\n\t1:9: no prefix parse function for ; found\n") {
+		t.Errorf("parser error not printed. got=%q", output)
+	}
+	if !strings.Contains(output, "runtime error: 1:1: identifier not found: x\n") {
+		t.Errorf("unbound identifier error not printed. got=%q", output)
+	}
+	if !strings.Contains(output, "runtime error: 1:1: type mismatch: INTEGER + BOOLEAN\n") {
+		t.Errorf("runtime error not printed. got=%q", output)
+	}
+	if strings.Count(output, MONKEY_FACE) != 1 {
+		t.Errorf("monkey face should only be printed for parser errors. got=%q", output)
+	}
+	if !strings.HasSuffix(output, PROMPT+"1\n"+PROMPT) {
+		t.Errorf("session did not continue after errors. got=%q", output)
+	}
+}
+
+func TestStartMultiLineInput(t *testing.T) {
+	input := `let add = fn(a, b) {
+  a + b
+};
+add(1,
+  2)
+[1,
+ 2,
+ 3]
+"multi
+line"
+1 +
+2
+`
+
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+
+	expected := PROMPT + CONTINUATION_PROMPT + CONTINUATION_PROMPT +
+		PROMPT + CONTINUATION_PROMPT + "3\n" +
+		PROMPT + CONTINUATION_PROMPT + CONTINUATION_PROMPT + "[1, 2, 3]\n" +
+		PROMPT + CONTINUATION_PROMPT + "multi\nline\n" +
+		PROMPT + CONTINUATION_PROMPT + "3\n" +
+		PROMPT
+	if out.String() != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=     %q", expected, out.String())
+	}
+}
+
+func TestStartAbortsPendingInput(t *testing.T) {
+	input := "let f = fn() {\n\n\n1\n"
+
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+
+	expected := PROMPT + CONTINUATION_PROMPT + CONTINUATION_PROMPT + "(input discarded)\n" +
+		PROMPT + "1\n" + PROMPT
+	if out.String() != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=     %q", expected, out.String())
+	}
+}
+
+func TestIsIncomplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"1 + 2", false},
+		{"let x = 1;", false},
+		{"fn(x) {", true},
+		{"if (x) { 1 } else", true},
+		{"add(1, ", true},
+		{"[1, 2", true},
+		{`{"a": `, true},
+		{"1 +", true},
+		{"let x =", true},
+		{`"abc`, true},
+		{`"abc\`, true},
+		{"1 + )", false},
+		{"let = 5", false},
+		{"5 @", false},
+	}
+
+	for _, tt := range tests {
+		if got := isIncomplete(tt.input); got != tt.expected {
+			t.Errorf("isIncomplete(%q) wrong. expected=%t, got=%t", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestStartCommands(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			":tokens let x = 5;",
+			"1:1      LET        \"let\"\n" +
+				"1:5      IDENT      \"x\"\n" +
+				"1:7      =          \"=\"\n" +
+				"1:9      INT        \"5\"\n" +
+				"1:10     ;          \";\"\n" +
+				"1:11     EOF        \"\"\n",
+		},
+		{
+			":ast -5",
+			"*ast.Program (1:1-1:3)\n" +
+				"  Statements:\n" +
+				"    0: *ast.ExpressionStatement (1:1-1:3)\n" +
+				"      Expression: *ast.PrefixExpression (1:1-1:3)\n" +
+				"        Operator: \"-\"\n" +
+				"        Right: *ast.IntegerLiteral (1:2-1:3)\n" +
+				"          Value: 5\n",
+		},
+		{
+			"let b = \"x\";\nlet a = fn(x) {\nx\n};\n:env",
+			PROMPT + CONTINUATION_PROMPT + CONTINUATION_PROMPT + PROMPT +
+				"a: FUNCTION = fn(x) { x }\n" +
+				"b: STRING = x\n",
+		},
+		{
+			"let x = 1;\n:reset\nx",
+			PROMPT + "environment cleared\n" + PROMPT +
+				"runtime error: 1:1: identifier not found: x\n",
+		},
+		{
+			"let twice = macro(x) { quote(unquote(x) + unquote(x)) };\n:expand twice(1 * 2)\ntwice(3)",
+			PROMPT + "((1 * 2) + (1 * 2))\n" + PROMPT + "6\n",
+		},
+		{
+			// :expand の入力で定義したマクロはセッションに残らない
+			":expand let m = macro() { quote(1) }; m()\nm",
+			"1\n" + PROMPT + "runtime error: 1:1: identifier not found: m\n",
+		},
+		{
+			"let m = macro() { quote(1) };\n:reset\n:expand m()",
+			PROMPT + "environment cleared\n" + PROMPT + "m()\n",
+		},
+		{
+			":bogus",
+			"unknown command :bogus (type :help for a list of commands)\n",
+		},
+		{
+			":quit\n1 + 1",
+			"",
+		},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		Start(strings.NewReader(tt.input), &out)
+
+		// :quit の後は入力が残っていてもプロンプトを表示せずに終了する
+		expected := PROMPT + tt.expected
+		if !strings.HasPrefix(tt.input, ":quit") {
+			expected += PROMPT
+		}
+		if out.String() != expected {
+			t.Errorf("wrong output for %q.\nexpected=%q\ngot=     %q", tt.input, expected, out.String())
+		}
+	}
+}
+
+func TestStartLoadCommand(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "lib.mk")
+	if err := os.WriteFile(path, []byte("let double = fn(x) { x * 2 };\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	Start(strings.NewReader(":load "+path+"\ndouble(21)\n"), &out)
+
+	expected := PROMPT + "loaded " + path + "\n" + PROMPT + "42\n" + PROMPT
+	if out.String() != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=     %q", expected, out.String())
+	}
+}
+
+func TestStartTimeCommand(t *testing.T) {
+	var out bytes.Buffer
+	Start(strings.NewReader(":time 1 + 2\n"), &out)
+
+	if !strings.HasPrefix(out.String(), PROMPT+"3\ntime: ") {
+		t.Errorf("wrong output. got=%q", out.String())
+	}
+}
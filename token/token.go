@@ -1,19 +1,56 @@
 package token
 
+import (
+	"fmt"
+	"sort"
+)
+
 type TokenType string
 
 type Token struct {
 	Type    TokenType // トークンが数(INT)なのか？変数(IDENT)なのか？キーワード(FUNCTION, LET, etc.)なのか、という種類を示す
 	Literal string    // トークンの内容が入る。数ならその値、変数なら変数名が入る。キーワードはTokenTypeと同じ
+	Pos     Position  // トークンの先頭文字の位置
+	End     Position  // トークンの末尾の次の文字の位置
+}
+
+// ソースコード上の位置
+// Line と Column は1始まりで，0 のときは位置が不明であることを示す
+type Position struct {
+	Filename string // ファイル名 (REPLなどファイルがない場合は空)
+	Offset   int    // 先頭からのバイトオフセット (0始まり)
+	Line     int    // 行 (1始まり)
+	Column   int    // 列 (1始まり)
+}
+
+// 位置が分かっているかどうか
+func (p Position) IsValid() bool { return p.Line > 0 }
+
+// "file:line:col" の形式で位置を返す (ファイル名がなければ "line:col")
+func (p Position) String() string {
+	s := p.Filename
+	if p.IsValid() {
+		if s != "" {
+			s += ":"
+		}
+		s += fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	if s == "" {
+		s = "-"
+	}
+	return s
 }
 
 const (
 	ILLEGAL = "ILLEGAL" // 未知のト－クン・文字であることを意味する
 	EOF     = "EOF"     // ファイル終端
+	COMMENT = "COMMENT" // コメント (lexer.ScanComments を指定したときだけ返す)
 
 	// 識別子(変数名), リテラル
-	IDENT = "IDENT"
-	INT   = "INT"
+	IDENT  = "IDENT"
+	INT    = "INT"
+	FLOAT  = "FLOAT"
+	STRING = "STRING"
 
 	// 演算子
 	ASSIGN   = "="
@@ -22,18 +59,50 @@ const (
 	BANG     = "!"
 	ASTERISK = "*"
 	SLASH    = "/"
+	PERCENT  = "%"
+	POWER    = "**"
+
+	LT    = "<"
+	GT    = ">"
+	LT_EQ = "<="
+	GT_EQ = ">="
+
+	// 論理演算子 (短絡評価する)
+	AND = "&&"
+	OR  = "||"
+
+	// ビット演算子
+	AMPERSAND   = "&"
+	PIPE        = "|"
+	CARET       = "^"
+	TILDE       = "~"
+	SHIFT_LEFT  = "<<"
+	SHIFT_RIGHT = ">>"
 
-	LT = "<"
-	GT = ">"
+	// 複合代入演算子 (x += 1 は x = x + 1 と同じ)
+	PLUS_ASSIGN        = "+="
+	MINUS_ASSIGN       = "-="
+	ASTERISK_ASSIGN    = "*="
+	SLASH_ASSIGN       = "/="
+	PERCENT_ASSIGN     = "%="
+	POWER_ASSIGN       = "**="
+	AMPERSAND_ASSIGN   = "&="
+	PIPE_ASSIGN        = "|="
+	CARET_ASSIGN       = "^="
+	SHIFT_LEFT_ASSIGN  = "<<="
+	SHIFT_RIGHT_ASSIGN = ">>="
 
 	// デリミタ(区切り文字)
 	COMMA     = ","
 	SEMICOLON = ";"
+	COLON     = ":"
 
-	LPAREN = "("
-	RPAREN = ")"
-	LBRACE = "{"
-	RBRACE = "}"
+	LPAREN   = "("
+	RPAREN   = ")"
+	LBRACE   = "{"
+	RBRACE   = "}"
+	LBRACKET = "["
+	RBRACKET = "]"
 
 	// キーワード(予約語)
 	FUNCTION = "FUNCTION"
@@ -43,19 +112,41 @@ const (
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	WHILE    = "WHILE"
+	FOR      = "FOR"
+	IN       = "IN"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
+	MACRO    = "MACRO"
 	EQ       = "=="
 	NOT_EQ   = "!="
 )
 
 // 予約語の定義
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"true":     TRUE,
+	"false":    FALSE,
+	"if":       IF,
+	"else":     ELSE,
+	"return":   RETURN,
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"macro":    MACRO,
+}
+
+// 予約語の一覧を辞書順で返す (REPL の補完などで使う)
+func Keywords() []string {
+	words := make([]string, 0, len(keywords))
+	for word := range keywords {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
 }
 
 // 予約語と識別子（変数名, 関数名, etc.）の識別を行う
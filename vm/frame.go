@@ -0,0 +1,29 @@
+package vm
+
+import (
+	"monkey/code"
+	"monkey/object"
+)
+
+// 関数呼び出し1回分の実行状態
+type Frame struct {
+	cl          *object.Closure
+	ip          int // 実行中の命令の位置 (次の命令を読む前に1つ進める)
+	basePointer int // 局所変数の先頭のスタック位置
+}
+
+func NewFrame(cl *object.Closure, basePointer int) *Frame {
+	return &Frame{cl: cl, ip: -1, basePointer: basePointer}
+}
+
+func (f *Frame) Instructions() code.Instructions {
+	return f.cl.Fn.Instructions
+}
+
+// スタックトレースに表示する関数名
+func (f *Frame) name() string {
+	if f.cl.Fn.Name == "" {
+		return "<anonymous>"
+	}
+	return f.cl.Fn.Name
+}
@@ -0,0 +1,686 @@
+package vm
+
+import (
+	"fmt"
+	"monkey/code"
+	"monkey/compiler"
+	"monkey/evaluator"
+	"monkey/object"
+)
+
+// スタックは必要になった分だけ伸ばし，StackSize を上限とする
+const StackSize = 1 << 20
+const GlobalsSize = 65536
+
+// 呼び出しの深さの上限は評価器と同じにする (プログラム本体のフレームの分だけ多い)
+const MaxFrames = evaluator.MaxCallDepth + 1
+
+// スタックの最初の大きさ
+const initialStackSize = 1 << 10
+
+// 命令に対応する演算子
+// 演算の規則は評価器と共有するので，命令を演算子に戻してから evaluator の関数に渡す
+var infixOperators = map[code.Opcode]string{
+	code.OpAdd:          "+",
+	code.OpSub:          "-",
+	code.OpMul:          "*",
+	code.OpDiv:          "/",
+	code.OpMod:          "%",
+	code.OpPow:          "**",
+	code.OpBitAnd:       "&",
+	code.OpBitOr:        "|",
+	code.OpBitXor:       "^",
+	code.OpShiftLeft:    "<<",
+	code.OpShiftRight:   ">>",
+	code.OpEqual:        "==",
+	code.OpNotEqual:     "!=",
+	code.OpLessThan:     "<",
+	code.OpGreaterThan:  ">",
+	code.OpLessEqual:    "<=",
+	code.OpGreaterEqual: ">=",
+}
+
+var prefixOperators = map[code.Opcode]string{
+	code.OpMinus:  "-",
+	code.OpBang:   "!",
+	code.OpBitNot: "~",
+}
+
+// for文の反復子を局所変数に置くための入れ物
+// Monkey のプログラムからは見えない
+type iterator struct {
+	object.Iterator
+}
+
+func (it *iterator) Type() object.ObjectType { return "ITERATOR" }
+func (it *iterator) Inspect() string         { return "iterator" }
+
+// ループの先頭のスタックの高さを局所変数に置くための入れ物
+// Monkey のプログラムからは見えない
+type stackMark struct {
+	sp int
+}
+
+func (m *stackMark) Type() object.ObjectType { return "STACK_MARK" }
+func (m *stackMark) Inspect() string         { return "stack mark" }
+
+// バイトコードを実行するスタックマシン
+type VM struct {
+	constants []object.Object
+	builtins  []*object.Builtin
+
+	stack []object.Object
+	sp    int // 常に次の空きを指す．スタックの先頭は stack[sp-1]
+
+	globals     []object.Object
+	globalNames []string
+
+	frames      []*Frame
+	framesIndex int
+}
+
+// VM のコンストラクタ
+func New(bytecode *compiler.Bytecode) *VM {
+	mainFn := &object.CompiledFunction{
+		Instructions: bytecode.Instructions,
+		Positions:    bytecode.Positions,
+		NumLocals:    bytecode.NumLocals,
+		LocalNames:   bytecode.LocalNames,
+	}
+	mainClosure := &object.Closure{Fn: mainFn}
+	mainFrame := NewFrame(mainClosure, 0)
+
+	frames := make([]*Frame, 1, 64)
+	frames[0] = mainFrame
+
+	stackSize := initialStackSize
+	if bytecode.NumLocals >= stackSize {
+		stackSize = bytecode.NumLocals + 1
+	}
+
+	// 組み込み関数はコンパイラと同じく evaluator.BuiltinNames の順に並べる
+	names := evaluator.BuiltinNames()
+	builtins := make([]*object.Builtin, len(names))
+	for i, name := range names {
+		builtins[i], _ = evaluator.LookupBuiltin(name)
+	}
+
+	return &VM{
+		constants: bytecode.Constants,
+		builtins:  builtins,
+
+		stack: make([]object.Object, stackSize),
+		sp:    bytecode.NumLocals, // プログラム本体の局所変数 (ブロックの変数) の分を空けておく
+
+		globals:     make([]object.Object, GlobalsSize),
+		globalNames: bytecode.GlobalNames,
+
+		frames:      frames,
+		framesIndex: 1,
+	}
+}
+
+// REPL のように，前回までのグローバル変数を引き継いで実行するためのコンストラクタ
+func NewWithGlobalsStore(bytecode *compiler.Bytecode, s []object.Object) *VM {
+	vm := New(bytecode)
+	vm.globals = s
+	return vm
+}
+
+// グローバル変数の格納先 (添字はコンパイラが割り当てた番号)
+func (vm *VM) Globals() []object.Object {
+	return vm.globals
+}
+
+// 最後に OpPop で取り除いた値 (最後の式文の値)
+func (vm *VM) LastPoppedStackElem() object.Object {
+	return vm.stack[vm.sp]
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(f *Frame) {
+	if vm.framesIndex == len(vm.frames) {
+		vm.frames = append(vm.frames, f)
+	} else {
+		vm.frames[vm.framesIndex] = f
+	}
+	vm.framesIndex++
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	return vm.frames[vm.framesIndex]
+}
+
+// プログラムを最後まで実行する
+// 実行時エラーは位置とスタックトレースを入れた *object.Error として返す
+func (vm *VM) Run() error {
+	var ip int
+	var ins code.Instructions
+	var op code.Opcode
+
+	for vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+		vm.currentFrame().ip++
+
+		ip = vm.currentFrame().ip
+		ins = vm.currentFrame().Instructions()
+		op = code.Opcode(ins[ip])
+
+		var err *object.Error
+
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			err = vm.push(vm.constants[constIndex])
+
+		case code.OpPop:
+			vm.pop()
+
+		case code.OpDup:
+			err = vm.push(vm.stack[vm.sp-1])
+
+		case code.OpDup2:
+			err = vm.push(vm.stack[vm.sp-2])
+			if err == nil {
+				err = vm.push(vm.stack[vm.sp-2])
+			}
+
+		case code.OpTrue:
+			err = vm.push(object.TRUE)
+
+		case code.OpFalse:
+			err = vm.push(object.FALSE)
+
+		case code.OpNull:
+			err = vm.push(object.NULL)
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod, code.OpPow,
+			code.OpBitAnd, code.OpBitOr, code.OpBitXor, code.OpShiftLeft, code.OpShiftRight,
+			code.OpEqual, code.OpNotEqual, code.OpLessThan, code.OpGreaterThan,
+			code.OpLessEqual, code.OpGreaterEqual:
+			right := vm.pop()
+			left := vm.pop()
+
+			err = vm.pushResult(evaluator.InfixOperator(infixOperators[op], left, right))
+
+		case code.OpMinus, code.OpBang, code.OpBitNot:
+			right := vm.pop()
+
+			err = vm.pushResult(evaluator.PrefixOperator(prefixOperators[op], right))
+
+		case code.OpJump:
+			pos := int(code.ReadUint32(ins[ip+1:]))
+			vm.currentFrame().ip = pos - 1
+
+		case code.OpJumpNotTruthy:
+			pos := int(code.ReadUint32(ins[ip+1:]))
+			vm.currentFrame().ip += 4
+
+			condition := vm.pop()
+			if !evaluator.IsTruthy(condition) {
+				vm.currentFrame().ip = pos - 1
+			}
+
+		case code.OpGetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			val := vm.globals[globalIndex]
+			if val == nil {
+				err = unboundError(vm.globalNames, int(globalIndex))
+				break
+			}
+			err = vm.push(val)
+
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			vm.globals[globalIndex] = vm.pop()
+
+		case code.OpAssignGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			if vm.globals[globalIndex] == nil {
+				err = newError(object.UNBOUND_IDENTIFIER, "cannot assign to undeclared identifier: %s",
+					nameAt(vm.globalNames, int(globalIndex)))
+				break
+			}
+			vm.globals[globalIndex] = vm.pop()
+
+		case code.OpGetLocal:
+			localIndex := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			frame := vm.currentFrame()
+			val := vm.stack[frame.basePointer+localIndex]
+			if val == nil {
+				err = unboundError(frame.cl.Fn.LocalNames, localIndex)
+				break
+			}
+			err = vm.push(val)
+
+		case code.OpSetLocal:
+			localIndex := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			frame := vm.currentFrame()
+			vm.stack[frame.basePointer+localIndex] = vm.pop()
+
+		case code.OpGetCell:
+			localIndex := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			// 仮引数のように，まだセルに入れていない値がそのまま置かれていることもある
+			frame := vm.currentFrame()
+			val := vm.stack[frame.basePointer+localIndex]
+			if cell, ok := val.(*object.Cell); ok {
+				val = cell.Value
+			}
+			if val == nil {
+				err = unboundError(frame.cl.Fn.LocalNames, localIndex)
+				break
+			}
+			err = vm.push(val)
+
+		case code.OpSetCell:
+			localIndex := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			slot := &vm.stack[vm.currentFrame().basePointer+localIndex]
+			if cell, ok := (*slot).(*object.Cell); ok {
+				cell.Value = vm.pop()
+			} else {
+				*slot = &object.Cell{Value: vm.pop()}
+			}
+
+		case code.OpLoadCell:
+			localIndex := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			// 局所変数をセルに入れて，その場でセルに置き換える
+			// 以降は関数の中と外で同じセルを読み書きする
+			slot := &vm.stack[vm.currentFrame().basePointer+localIndex]
+			cell, ok := (*slot).(*object.Cell)
+			if !ok {
+				cell = &object.Cell{Value: *slot}
+				*slot = cell
+			}
+			err = vm.push(cell)
+
+		case code.OpClearLocals:
+			first := int(code.ReadUint16(ins[ip+1:]))
+			count := int(code.ReadUint16(ins[ip+3:]))
+			vm.currentFrame().ip += 4
+
+			base := vm.currentFrame().basePointer + first
+			for i := base; i < base+count; i++ {
+				vm.stack[i] = nil
+			}
+
+		case code.OpGetFree:
+			freeIndex := int(code.ReadUint8(ins[ip+1:]))
+			vm.currentFrame().ip += 1
+
+			fn := vm.currentFrame().cl
+			val := fn.Free[freeIndex].Value
+			if val == nil {
+				err = unboundError(fn.Fn.FreeNames, freeIndex)
+				break
+			}
+			err = vm.push(val)
+
+		case code.OpSetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			vm.currentFrame().cl.Free[freeIndex].Value = vm.pop()
+
+		case code.OpLoadFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			err = vm.push(vm.currentFrame().cl.Free[freeIndex])
+
+		case code.OpGetBuiltin:
+			builtinIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			err = vm.push(vm.builtins[builtinIndex])
+
+		case code.OpArray:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			elements := make([]object.Object, numElements)
+			copy(elements, vm.stack[vm.sp-numElements:vm.sp])
+			vm.sp = vm.sp - numElements
+
+			err = vm.push(&object.Array{Elements: elements})
+
+		case code.OpHash:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			hash := vm.buildHash(vm.sp-numElements, vm.sp)
+			vm.sp = vm.sp - numElements
+
+			err = vm.push(hash)
+
+		case code.OpHashKey:
+			if key := vm.stack[vm.sp-1]; !isHashable(key) {
+				err = newError(object.UNHASHABLE_KEY, "unusable as hash key: %s", typeOf(key))
+			}
+
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+
+			err = vm.pushResult(evaluator.IndexOperator(left, index))
+
+		case code.OpSetIndex:
+			value := vm.pop()
+			index := vm.pop()
+			left := vm.pop()
+
+			if err = evaluator.IndexAssign(left, index, value); err != nil {
+				break
+			}
+			err = vm.push(value)
+
+		case code.OpSlice:
+			flags := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			var low, high object.Object
+			if flags&2 != 0 {
+				high = vm.pop()
+			}
+			if flags&1 != 0 {
+				low = vm.pop()
+			}
+			left := vm.pop()
+
+			err = vm.pushResult(evaluator.SliceOperator(left, low, high))
+
+		case code.OpCall:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			err = vm.executeCall(int(numArgs))
+
+		case code.OpReturnValue:
+			returnValue := vm.pop()
+
+			// プログラム本体の return はそこで実行を終える
+			if vm.framesIndex == 1 {
+				return nil
+			}
+
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+
+			err = vm.push(returnValue)
+
+		case code.OpReturn:
+			if vm.framesIndex == 1 {
+				vm.stack[vm.sp] = object.NULL
+				return nil
+			}
+
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+
+			err = vm.push(object.NULL)
+
+		case code.OpClosure:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			numFree := code.ReadUint8(ins[ip+3:])
+			vm.currentFrame().ip += 3
+
+			err = vm.pushClosure(int(constIndex), int(numFree))
+
+		case code.OpIter:
+			localIndex := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			iterable := vm.pop()
+			iter, ok := object.NewIterator(iterable)
+			if !ok {
+				err = newError(object.TYPE_MISMATCH, "cannot iterate over %s", typeOf(iterable))
+				break
+			}
+			vm.stack[vm.currentFrame().basePointer+localIndex] = &iterator{iter}
+
+		case code.OpIterNext:
+			localIndex := int(code.ReadUint16(ins[ip+1:]))
+			pos := int(code.ReadUint32(ins[ip+3:]))
+			vm.currentFrame().ip += 6
+
+			iter := vm.stack[vm.currentFrame().basePointer+localIndex].(*iterator)
+			item, ok := iter.Next()
+			if !ok {
+				vm.currentFrame().ip = pos - 1
+				break
+			}
+			err = vm.push(item)
+
+		case code.OpMarkStack:
+			localIndex := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			vm.stack[vm.currentFrame().basePointer+localIndex] = &stackMark{sp: vm.sp}
+
+		case code.OpUnwindStack:
+			localIndex := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			vm.sp = vm.stack[vm.currentFrame().basePointer+localIndex].(*stackMark).sp
+
+		default:
+			def, lookupErr := code.Lookup(byte(op))
+			if lookupErr != nil {
+				return lookupErr
+			}
+			return fmt.Errorf("opcode %s not implemented", def.Name)
+		}
+
+		if err != nil {
+			return vm.fail(err)
+		}
+	}
+
+	return nil
+}
+
+// 関数を呼び出す
+// スタックには関数，引数の順に積まれている
+func (vm *VM) executeCall(numArgs int) *object.Error {
+	callee := vm.stack[vm.sp-1-numArgs]
+	switch callee := callee.(type) {
+	case *object.Closure:
+		return vm.callClosure(callee, numArgs)
+	case *object.Builtin:
+		return vm.callBuiltin(callee, numArgs)
+	default:
+		return newError(object.TYPE_MISMATCH, "not a function: %s", typeOf(callee))
+	}
+}
+
+// クロージャを呼び出す
+// 引数はそのまま新しいフレームの先頭の局所変数になる
+func (vm *VM) callClosure(cl *object.Closure, numArgs int) *object.Error {
+	if numArgs != cl.Fn.NumParameters {
+		err := newError(object.ARITY_MISMATCH,
+			"wrong number of arguments: want=%d, got=%d", cl.Fn.NumParameters, numArgs)
+		err.Stack = append(err.Stack, (&Frame{cl: cl}).name())
+		return err
+	}
+
+	basePointer := vm.sp - numArgs
+	if vm.framesIndex >= MaxFrames || !vm.growStack(basePointer+cl.Fn.NumLocals) {
+		return newError(object.STACK_OVERFLOW, "maximum call depth exceeded")
+	}
+
+	frame := NewFrame(cl, basePointer)
+	vm.pushFrame(frame)
+
+	// 前の呼び出しの値が残っていると未定義の変数を検出できないので，局所変数を空にしておく
+	for i := basePointer + numArgs; i < basePointer+cl.Fn.NumLocals; i++ {
+		vm.stack[i] = nil
+	}
+	vm.sp = basePointer + cl.Fn.NumLocals
+
+	return nil
+}
+
+// 組み込み関数を呼び出す
+// 評価器と同じく，エラーのスタックトレースには組み込み関数の名前を積む
+func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) *object.Error {
+	args := make([]object.Object, numArgs)
+	copy(args, vm.stack[vm.sp-numArgs:vm.sp])
+
+	result := builtin.Fn(args...)
+	if err, ok := result.(*object.Error); ok {
+		err.Stack = append(err.Stack, builtin.Name)
+		return err
+	}
+	if result == nil {
+		result = object.NULL
+	}
+
+	vm.sp = vm.sp - numArgs - 1
+	return vm.push(result)
+}
+
+// 定数プールの関数と，スタックに積まれた捕捉する変数のセルからクロージャを作る
+func (vm *VM) pushClosure(constIndex int, numFree int) *object.Error {
+	constant := vm.constants[constIndex]
+	function, ok := constant.(*object.CompiledFunction)
+	if !ok {
+		return newError(object.TYPE_MISMATCH, "not a function: %s", typeOf(constant))
+	}
+
+	free := make([]*object.Cell, numFree)
+	for i := 0; i < numFree; i++ {
+		free[i] = vm.stack[vm.sp-numFree+i].(*object.Cell)
+	}
+	vm.sp = vm.sp - numFree
+
+	return vm.push(&object.Closure{Fn: function, Free: free})
+}
+
+// スタックの startIndex から endIndex までに交互に積まれたキーと値からハッシュを作る
+// ハッシュのキーに使える値かどうか
+func isHashable(obj object.Object) bool {
+	_, ok := obj.(object.Hashable)
+	return ok
+}
+
+func (vm *VM) buildHash(startIndex, endIndex int) object.Object {
+	hash := object.NewHash()
+
+	for i := startIndex; i < endIndex; i += 2 {
+		key := vm.stack[i]
+		value := vm.stack[i+1]
+
+		// キーは OpHashKey で検査済み
+		hash.Set(key.(object.Hashable), value)
+	}
+
+	return hash
+}
+
+func (vm *VM) push(o object.Object) *object.Error {
+	if vm.sp >= len(vm.stack) && !vm.growStack(vm.sp+1) {
+		return newError(object.STACK_OVERFLOW, "stack overflow")
+	}
+
+	vm.stack[vm.sp] = o
+	vm.sp++
+
+	return nil
+}
+
+// スタックが少なくとも n 個の値を置ける大きさになるように伸ばす
+// StackSize を超える場合は false を返す
+func (vm *VM) growStack(n int) bool {
+	if n <= len(vm.stack) {
+		return true
+	}
+	if n > StackSize {
+		return false
+	}
+
+	size := len(vm.stack) * 2
+	if size < n {
+		size = n
+	}
+	if size > StackSize {
+		size = StackSize
+	}
+
+	stack := make([]object.Object, size)
+	copy(stack, vm.stack)
+	vm.stack = stack
+	return true
+}
+
+// 演算の結果を積む．結果がエラーならそれを返す
+func (vm *VM) pushResult(result object.Object) *object.Error {
+	if err, ok := result.(*object.Error); ok {
+		return err
+	}
+	return vm.push(result)
+}
+
+func (vm *VM) pop() object.Object {
+	o := vm.stack[vm.sp-1]
+	vm.sp--
+	return o
+}
+
+// 実行時エラーに，実行中の命令に対応するソースコードの位置と，呼び出し中の関数名を入れる
+// 関数名は評価器と同じく内側の呼び出しを先にし，プログラム本体は含めない
+func (vm *VM) fail(err *object.Error) error {
+	frame := vm.currentFrame()
+	if !err.Pos.IsValid() {
+		err.Pos = frame.cl.Fn.Positions.Lookup(frame.ip)
+	}
+
+	for i := vm.framesIndex - 1; i > 0; i-- {
+		err.Stack = append(err.Stack, vm.frames[i].name())
+	}
+
+	return err
+}
+
+func newError(kind object.ErrorKind, format string, a ...interface{}) *object.Error {
+	return &object.Error{Kind: kind, Message: fmt.Sprintf(format, a...)}
+}
+
+// 値が入っていない変数を読んだときのエラー
+func unboundError(names []string, index int) *object.Error {
+	return newError(object.UNBOUND_IDENTIFIER, "identifier not found: %s", nameAt(names, index))
+}
+
+func nameAt(names []string, index int) string {
+	if index < len(names) {
+		return names[index]
+	}
+	return fmt.Sprintf("#%d", index)
+}
+
+// エラーメッセージ用に型名を返す (nil の場合も落ちないようにする)
+func typeOf(obj object.Object) object.ObjectType {
+	if obj == nil {
+		return object.NULL_OBJ
+	}
+	return obj.Type()
+}
@@ -0,0 +1,252 @@
+package vm
+
+import (
+	"monkey/ast"
+	"monkey/compiler"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"testing"
+)
+
+type vmTestCase struct {
+	input    string
+	expected interface{}
+}
+
+// 評価器との振る舞いの一致は conformance パッケージで確かめるので，
+// ここでは仮想マシンに固有の仕組み (セル，フレーム，グローバル変数の引き継ぎ) を確かめる
+
+func TestIntegerArithmetic(t *testing.T) {
+	tests := []vmTestCase{
+		{"1", 1},
+		{"1 + 2", 3},
+		{"50 / 2 * 2 + 10 - 5", 55},
+		{"-5 + 10 % 3", -4},
+		{"2 ** 10", 1024},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestConditionals(t *testing.T) {
+	tests := []vmTestCase{
+		{"if (true) { 10 }", 10},
+		{"if (false) { 10 }", object.NULL},
+		{"if (1 > 2) { 10 } else { 20 }", 20},
+		{"if ((if (false) { 10 })) { 10 } else { 20 }", 20},
+		{"if (true) { let a = 1; }", object.NULL},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestClosuresShareCells(t *testing.T) {
+	tests := []vmTestCase{
+		{
+			// 捕捉した変数への代入は，外側の関数からも見える
+			`
+			let f = fn() {
+				let x = 1;
+				let inc = fn() { x += 1 };
+				inc();
+				inc();
+				x
+			};
+			f()`,
+			3,
+		},
+		{
+			// 呼び出しごとに別のセルを作る
+			`
+			let counter = fn() { let c = 0; fn() { c += 1 } };
+			let a = counter();
+			let b = counter();
+			a(); a(); b();
+			a()`,
+			3,
+		},
+		{
+			// 仮引数も捕捉できる
+			`
+			let adder = fn(x) { fn(y) { x + y } };
+			adder(2)(3)`,
+			5,
+		},
+		{
+			// 局所変数で定義した再帰関数
+			`
+			let f = fn(n) {
+				let fact = fn(n) { if (n == 0) { 1 } else { n * fact(n - 1) } };
+				fact(n)
+			};
+			f(5)`,
+			120,
+		},
+		{
+			// for文では繰り返しのたびに新しい変数を作る
+			`
+			let fs = [];
+			for (i in range(3)) { fs = push(fs, fn() { i }) }
+			fs[0]() + fs[1]() * 10 + fs[2]() * 100`,
+			210,
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestForLoopInsideFunction(t *testing.T) {
+	tests := []vmTestCase{
+		{
+			// ループの途中の return でフレームごと抜ける
+			`
+			let find = fn(arr, x) {
+				for (i in range(len(arr))) {
+					if (arr[i] == x) { return i; }
+				}
+				-1
+			};
+			find([5, 6, 7], 7) * 10 + find([1], 2)`,
+			19,
+		},
+		{
+			`
+			let sum = fn(n) {
+				let s = 0;
+				for (i in range(n)) {
+					for (j in range(i)) { s += j }
+				}
+				s
+			};
+			sum(5)`,
+			10,
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestLoopExitInsideExpression(t *testing.T) {
+	tests := []vmTestCase{
+		{
+			// 積みかけの配列の要素を捨ててから抜けるので，繰り返してもスタックが溢れない
+			`
+			let n = 0;
+			for (i in range(100000)) { n += 1; let a = [1, if (true) { continue; } else { 0 }]; }
+			n`,
+			100000,
+		},
+		{
+			`
+			let f = fn() {
+				let i = 0;
+				while (true) { i += 1; let b = 1 + [i, if (i == 3) { break; } else { 0 }][1]; }
+				i
+			};
+			f() + f()`,
+			6,
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestStackOverflow(t *testing.T) {
+	program := parse("let f = fn() { f() }; f()")
+
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := New(comp.Bytecode())
+	err := vm.Run()
+	errObj, ok := err.(*object.Error)
+	if !ok {
+		t.Fatalf("expected *object.Error. got=%T (%v)", err, err)
+	}
+	if errObj.Kind != object.STACK_OVERFLOW {
+		t.Errorf("wrong error kind. want=%q, got=%q", object.STACK_OVERFLOW, errObj.Kind)
+	}
+	if len(errObj.Stack) != MaxFrames-1 {
+		t.Errorf("wrong stack length. want=%d, got=%d", MaxFrames-1, len(errObj.Stack))
+	}
+}
+
+func TestGlobalsStore(t *testing.T) {
+	symbolTable := compiler.NewGlobalSymbolTable()
+	constants := []object.Object{}
+	globals := make([]object.Object, GlobalsSize)
+
+	inputs := []string{"let a = 1;", "let f = fn() { a + 1 };", "a = f(); a * 10"}
+
+	var last object.Object
+	for _, input := range inputs {
+		comp := compiler.NewWithState(symbolTable, constants)
+		if err := comp.Compile(parse(input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		bytecode := comp.Bytecode()
+		constants = bytecode.Constants
+
+		vm := NewWithGlobalsStore(bytecode, globals)
+		if err := vm.Run(); err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+		last = vm.LastPoppedStackElem()
+	}
+
+	testExpectedObject(t, "globals store", 20, last)
+}
+
+func runVmTests(t *testing.T, tests []vmTestCase) {
+	t.Helper()
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err != nil {
+			t.Fatalf("vm error for %q: %s", tt.input, err)
+		}
+
+		stackElem := vm.LastPoppedStackElem()
+
+		testExpectedObject(t, tt.input, tt.expected, stackElem)
+	}
+}
+
+func parse(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}
+
+func testExpectedObject(t *testing.T, input string, expected interface{}, actual object.Object) {
+	t.Helper()
+
+	switch expected := expected.(type) {
+	case int:
+		result, ok := actual.(*object.Integer)
+		if !ok {
+			t.Errorf("%q: object is not Integer. got=%T (%+v)", input, actual, actual)
+			return
+		}
+		if result.Value != int64(expected) {
+			t.Errorf("%q: object has wrong value. got=%d, want=%d", input, result.Value, expected)
+		}
+
+	case *object.Null:
+		if actual != object.NULL {
+			t.Errorf("%q: object is not Null: %T (%+v)", input, actual, actual)
+		}
+	}
+}